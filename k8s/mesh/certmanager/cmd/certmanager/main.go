@@ -29,7 +29,7 @@ func main() {
 		logger.Fatalf("build token reviewer: %v", err)
 	}
 
-	signer, err := cryptoadapter.NewSignerFromFiles(cfg.RootCACertFile, cfg.RootCAKeyFile, cfg.LeafTTL)
+	signer, err := cryptoadapter.NewSignerFromFiles(cfg.RootCACertFile, cfg.RootCAKeyFile, cfg.LeafTTL, cfg.TrustDomain)
 	if err != nil {
 		logger.Fatalf("build signer: %v", err)
 	}
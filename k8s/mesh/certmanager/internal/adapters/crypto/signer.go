@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net/url"
 	"os"
 	"time"
 
@@ -16,13 +17,14 @@ import (
 )
 
 type Signer struct {
-	caCert  *x509.Certificate
-	caKey   crypto.PrivateKey
-	caPEM   []byte
-	leafTTL time.Duration
+	caCert      *x509.Certificate
+	caKey       crypto.PrivateKey
+	caPEM       []byte
+	leafTTL     time.Duration
+	trustDomain string
 }
 
-func NewSignerFromFiles(caCertFile string, caKeyFile string, leafTTL time.Duration) (*Signer, error) {
+func NewSignerFromFiles(caCertFile string, caKeyFile string, leafTTL time.Duration, trustDomain string) (*Signer, error) {
 	caCertPEM, err := os.ReadFile(caCertFile)
 	if err != nil {
 		return nil, fmt.Errorf("read CA cert file: %w", err)
@@ -47,11 +49,16 @@ func NewSignerFromFiles(caCertFile string, caKeyFile string, leafTTL time.Durati
 		return nil, fmt.Errorf("leaf TTL must be positive")
 	}
 
+	if trustDomain == "" {
+		return nil, fmt.Errorf("trust domain must not be empty")
+	}
+
 	return &Signer{
-		caCert:  caCert,
-		caKey:   caKey,
-		caPEM:   caCertPEM,
-		leafTTL: leafTTL,
+		caCert:      caCert,
+		caKey:       caKey,
+		caPEM:       caCertPEM,
+		leafTTL:     leafTTL,
+		trustDomain: trustDomain,
 	}, nil
 }
 
@@ -83,6 +90,7 @@ func (s *Signer) SignCSR(csrPEM []byte, identity domain.Identity) ([]byte, []byt
 			CommonName: identity.String(),
 		},
 		DNSNames:              []string{identity.DNSName()},
+		URIs:                  []*url.URL{identity.SPIFFEID(s.trustDomain)},
 		NotBefore:             notBefore,
 		NotAfter:              notAfter,
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
@@ -49,6 +49,10 @@ func TestSignerIssuesCertificate(t *testing.T) {
 		t.Fatalf("unexpected DNS SANs: %#v", leaf.DNSNames)
 	}
 
+	if len(leaf.URIs) != 1 || leaf.URIs[0].String() != "spiffe://cluster.local/ns/default/sa/reviews" {
+		t.Fatalf("unexpected URI SANs: %#v", leaf.URIs)
+	}
+
 	if !leaf.NotAfter.Equal(expiresAt.Truncate(time.Second)) {
 		t.Fatalf("expiresAt mismatch: cert=%s response=%s", leaf.NotAfter, expiresAt)
 	}
@@ -96,10 +100,11 @@ func mustNewTestSigner(t *testing.T) *Signer {
 	}
 
 	return &Signer{
-		caCert:  caCert,
-		caKey:   caKey,
-		caPEM:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
-		leafTTL: time.Hour,
+		caCert:      caCert,
+		caKey:       caKey,
+		caPEM:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		leafTTL:     time.Hour,
+		trustDomain: "cluster.local",
 	}
 }
 
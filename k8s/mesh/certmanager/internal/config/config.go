@@ -13,6 +13,7 @@ type Config struct {
 	RootCACertFile    string
 	RootCAKeyFile     string
 	LeafTTL           time.Duration
+	TrustDomain       string
 	MaxRequestBytes   int64
 	RateLimitRPS      float64
 	RateLimitBurst    int
@@ -34,6 +35,7 @@ func LoadFromEnv() (Config, error) {
 		RootCACertFile:    envString("/etc/mesh/ca/tls.crt", "ROOT_CA_CERT_FILE"),
 		RootCAKeyFile:     envString("/etc/mesh/ca/tls.key", "ROOT_CA_KEY_FILE"),
 		LeafTTL:           envDuration(8760*time.Hour, "LEAF_TTL"),
+		TrustDomain:       envString("cluster.local", "TRUST_DOMAIN"),
 		MaxRequestBytes:   envInt64(1<<20, "MAX_REQUEST_BYTES"),
 		RateLimitRPS:      envFloat64(0, "RATE_LIMIT_RPS"),
 		RateLimitBurst:    envInt(0, "RATE_LIMIT_BURST"),
@@ -63,6 +65,10 @@ func (c Config) Validate() error {
 		return fmt.Errorf("LEAF_TTL must be positive")
 	}
 
+	if c.TrustDomain == "" || strings.ContainsAny(c.TrustDomain, "/:") {
+		return fmt.Errorf("TRUST_DOMAIN must be a bare host name")
+	}
+
 	if c.MaxRequestBytes <= 0 {
 		return fmt.Errorf("MAX_REQUEST_BYTES must be positive")
 	}
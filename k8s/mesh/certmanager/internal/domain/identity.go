@@ -1,6 +1,9 @@
 package domain
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+)
 
 type Identity struct {
 	Namespace      string
@@ -14,3 +17,11 @@ func (i Identity) String() string {
 func (i Identity) DNSName() string {
 	return fmt.Sprintf("%s.%s.svc.cluster.local", i.ServiceAccount, i.Namespace)
 }
+
+func (i Identity) SPIFFEID(trustDomain string) *url.URL {
+	return &url.URL{
+		Scheme: "spiffe",
+		Host:   trustDomain,
+		Path:   fmt.Sprintf("/ns/%s/sa/%s", i.Namespace, i.ServiceAccount),
+	}
+}
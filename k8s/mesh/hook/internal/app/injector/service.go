@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/LLIEPJIOK/service-mesh/hook/internal/config"
 )
@@ -19,6 +21,8 @@ const (
 	annotationInject           = "sidecar.mesh.io/inject"
 	annotationInjected         = "sidecar.mesh.io/injected"
 	annotationVersion          = "sidecar.mesh.io/version"
+	annotationMTLSMode         = "sidecar.mesh.io/mtls-mode"
+	annotationHoldApplication  = "sidecar.mesh.io/hold-application-until-proxy-starts"
 	annotationPrometheusScrape = "prometheus.io/scrape"
 	annotationPrometheusPort   = "prometheus.io/port"
 	annotationPrometheusPath   = "prometheus.io/path"
@@ -26,6 +30,9 @@ const (
 	containerNameIptables = "iptables-init"
 	containerNameSidecar  = "sidecar"
 	volumeNameMeshCA      = "mesh-ca"
+
+	sidecarReadinessPath = "/healthz/ready"
+	appHealthPath        = "/app-health/"
 )
 
 type Service struct {
@@ -39,6 +46,24 @@ type Decision struct {
 	SkipReason string
 }
 
+// appProbe is the original application probe handed to the sidecar in
+// APP_PROBES, with a named port resolved to a number.
+type appProbe struct {
+	HTTPGet   *appHTTPProbe `json:"httpGet,omitempty"`
+	TCPSocket *appTCPProbe  `json:"tcpSocket,omitempty"`
+}
+
+type appHTTPProbe struct {
+	Path    string              `json:"path,omitempty"`
+	Port    int32               `json:"port"`
+	Scheme  string              `json:"scheme,omitempty"`
+	Headers []corev1.HTTPHeader `json:"httpHeaders,omitempty"`
+}
+
+type appTCPProbe struct {
+	Port int32 `json:"port"`
+}
+
 type patchOperation struct {
 	Op    string `json:"op"`
 	Path  string `json:"path"`
@@ -174,21 +199,49 @@ func (s *Service) BuildPatch(request *admissionv1.AdmissionRequest, pod *corev1.
 	}
 
 	if !hasContainerByName(pod.Spec.Containers, containerNameSidecar) {
-		sidecar := s.buildSidecarContainer(serviceAccountName, uid, appTargetAddr)
+		// Probe rewrites go first: the sidecar may be inserted at index 0 and shift the containers.
+		probeOperations, probes := s.rewriteAppProbes(namespace, pod)
+		operations = append(operations, probeOperations...)
+
+		var appProbes string
+		if len(probes) > 0 {
+			encoded, err := json.Marshal(probes)
+			if err != nil {
+				return Decision{}, fmt.Errorf("marshal app probes: %w", err)
+			}
+			appProbes = string(encoded)
+		}
 
-		if len(pod.Spec.Containers) == 0 {
+		holdApplication := s.holdApplication(namespace, pod)
+		sidecar := s.buildSidecarContainer(serviceAccountName, uid, appTargetAddr, inboundPorts, s.workloadMTLSMode(namespace, pod), holdApplication, appProbes)
+
+		switch {
+		case len(pod.Spec.Containers) == 0:
 			operations = append(operations, patchOperation{
 				Op:    "add",
 				Path:  "/spec/containers",
 				Value: []corev1.Container{sidecar},
 			})
-		} else {
+		case holdApplication:
+			// The kubelet starts containers in order and waits for each postStart hook,
+			// so a sidecar in front keeps the application from starting until it is ready.
+			operations = append(operations, patchOperation{
+				Op:    "add",
+				Path:  "/spec/containers/0",
+				Value: sidecar,
+			})
+		default:
 			operations = append(operations, patchOperation{
 				Op:    "add",
 				Path:  "/spec/containers/-",
 				Value: sidecar,
 			})
 		}
+
+		// Only with a new sidecar: a pod the user shortened after injection is left alone.
+		if operation, ok := s.terminationGracePeriodPatch(pod); ok {
+			operations = append(operations, operation)
+		}
 	}
 
 	if len(operations) == 0 {
@@ -232,6 +285,167 @@ func isOptOutPod(pod *corev1.Pod) bool {
 	return strings.EqualFold(strings.TrimSpace(value), "false")
 }
 
+func (s *Service) workloadMTLSMode(namespace string, pod *corev1.Pod) string {
+	if pod.Annotations == nil {
+		return ""
+	}
+
+	value, exists := pod.Annotations[annotationMTLSMode]
+	if !exists {
+		return ""
+	}
+
+	mode := strings.ToUpper(strings.TrimSpace(value))
+	switch {
+	case mode == "PERMISSIVE":
+		return mode
+	case mode == "STRICT" && s.cfg.MTLSEnabled:
+		return mode
+	case mode == "STRICT":
+		s.logger.Printf("ignore %s=%q for pod %q/%q because mTLS is disabled", annotationMTLSMode, value, namespace, pod.Name)
+		return ""
+	default:
+		s.logger.Printf("ignore unsupported %s=%q for pod %q/%q", annotationMTLSMode, value, namespace, pod.Name)
+		return ""
+	}
+}
+
+func (s *Service) holdApplication(namespace string, pod *corev1.Pod) bool {
+	value, exists := pod.Annotations[annotationHoldApplication]
+	if !exists {
+		return s.cfg.HoldApplicationUntilProxyStarts
+	}
+
+	hold, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		s.logger.Printf("ignore unsupported %s=%q for pod %q/%q", annotationHoldApplication, value, namespace, pod.Name)
+		return s.cfg.HoldApplicationUntilProxyStarts
+	}
+
+	return hold
+}
+
+// rewriteAppProbes points httpGet and tcpSocket probes of the application
+// containers at the sidecar status port. The kubelet probes from the node IP,
+// which STRICT mTLS would reject on the application ports.
+func (s *Service) rewriteAppProbes(namespace string, pod *corev1.Pod) ([]patchOperation, map[string]appProbe) {
+	var operations []patchOperation
+	probes := make(map[string]appProbe)
+
+	for index, container := range pod.Spec.Containers {
+		if container.Name == containerNameSidecar {
+			continue
+		}
+
+		for _, target := range []struct {
+			field string
+			name  string
+			probe *corev1.Probe
+		}{
+			{field: "livenessProbe", name: "livez", probe: container.LivenessProbe},
+			{field: "readinessProbe", name: "readyz", probe: container.ReadinessProbe},
+			{field: "startupProbe", name: "startupz", probe: container.StartupProbe},
+		} {
+			if target.probe == nil {
+				continue
+			}
+
+			original, ok := resolveAppProbe(container, target.probe.ProbeHandler)
+			if !ok {
+				if target.probe.HTTPGet != nil || target.probe.TCPSocket != nil {
+					s.logger.Printf("keep %s of container %q in pod %q/%q as is", target.field, container.Name, namespace, pod.Name)
+				}
+				continue
+			}
+
+			path := appHealthPath + container.Name + "/" + target.name
+			probes[path] = original
+
+			rewritten := target.probe.DeepCopy()
+			rewritten.ProbeHandler = corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path:   path,
+					Port:   intstr.FromInt32(int32(s.cfg.StatusPort)),
+					Scheme: corev1.URISchemeHTTP,
+				},
+			}
+			operations = append(operations, patchOperation{
+				Op:    "replace",
+				Path:  fmt.Sprintf("/spec/containers/%d/%s", index, target.field),
+				Value: rewritten,
+			})
+		}
+	}
+
+	return operations, probes
+}
+
+// resolveAppProbe reports false for exec and gRPC probes, which do not cross
+// the sidecar, and for probes aimed at another host or an unknown named port.
+func resolveAppProbe(container corev1.Container, handler corev1.ProbeHandler) (appProbe, bool) {
+	switch {
+	case handler.HTTPGet != nil:
+		if handler.HTTPGet.Host != "" {
+			return appProbe{}, false
+		}
+
+		port, ok := resolveContainerPort(container, handler.HTTPGet.Port)
+		if !ok {
+			return appProbe{}, false
+		}
+
+		return appProbe{HTTPGet: &appHTTPProbe{
+			Path:    handler.HTTPGet.Path,
+			Port:    port,
+			Scheme:  string(handler.HTTPGet.Scheme),
+			Headers: handler.HTTPGet.HTTPHeaders,
+		}}, true
+	case handler.TCPSocket != nil:
+		if handler.TCPSocket.Host != "" {
+			return appProbe{}, false
+		}
+
+		port, ok := resolveContainerPort(container, handler.TCPSocket.Port)
+		if !ok {
+			return appProbe{}, false
+		}
+
+		return appProbe{TCPSocket: &appTCPProbe{Port: port}}, true
+	default:
+		return appProbe{}, false
+	}
+}
+
+func resolveContainerPort(container corev1.Container, port intstr.IntOrString) (int32, bool) {
+	if port.Type == intstr.Int {
+		return port.IntVal, port.IntVal > 0
+	}
+
+	for _, containerPort := range container.Ports {
+		if containerPort.Name == port.StrVal {
+			return containerPort.ContainerPort, true
+		}
+	}
+
+	return 0, false
+}
+
+// terminationGracePeriodPatch leaves room for the sidecar drain and shutdown
+// when the pod grace period is shorter.
+func (s *Service) terminationGracePeriodPatch(pod *corev1.Pod) (patchOperation, bool) {
+	required := int64(math.Ceil((s.cfg.DrainDuration + s.cfg.SidecarShutdownTimeout).Seconds()))
+	current := pod.Spec.TerminationGracePeriodSeconds
+
+	switch {
+	case current == nil:
+		return patchOperation{Op: "add", Path: "/spec/terminationGracePeriodSeconds", Value: required}, true
+	case *current < required:
+		return patchOperation{Op: "replace", Path: "/spec/terminationGracePeriodSeconds", Value: required}, true
+	default:
+		return patchOperation{}, false
+	}
+}
+
 func deriveServiceAccountName(pod *corev1.Pod) string {
 	if pod == nil {
 		return "default"
@@ -382,18 +596,127 @@ func (s *Service) buildIptablesContainer(inboundPorts string, uid string) corev1
 	}
 }
 
-func (s *Service) buildSidecarContainer(serviceAccountName string, uid int64, appTargetAddr string) corev1.Container {
+func (s *Service) buildSidecarContainer(
+	serviceAccountName string,
+	uid int64,
+	appTargetAddr string,
+	inboundPorts string,
+	workloadMTLSMode string,
+	holdApplication bool,
+	appProbes string,
+) corev1.Container {
 	runAsNonRoot := true
 	ports := []corev1.ContainerPort{
 		{Name: "mesh-outbound", ContainerPort: int32(s.cfg.OutboundPort)},
 		{Name: "mesh-inbound", ContainerPort: int32(s.cfg.InboundPlainPort)},
 		{Name: "mesh-metrics", ContainerPort: int32(s.cfg.MetricsPort), Protocol: corev1.ProtocolTCP},
+		{Name: "mesh-status", ContainerPort: int32(s.cfg.StatusPort), Protocol: corev1.ProtocolTCP},
 	}
 
 	if s.cfg.MTLSEnabled && s.cfg.InboundMTLSPort > 0 {
 		ports = append([]corev1.ContainerPort{{Name: "mesh-mtls", ContainerPort: int32(s.cfg.InboundMTLSPort)}}, ports...)
 	}
 
+	env := []corev1.EnvVar{
+		{
+			Name: "POD_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		},
+		{
+			Name: "POD_NAMESPACE",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+			},
+		},
+		{
+			Name: "NODE_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+			},
+		},
+		{Name: "SERVICE_ACCOUNT", Value: serviceAccountName},
+		{Name: "TRUST_DOMAIN", Value: s.cfg.TrustDomain},
+		{Name: "APP_TARGET_ADDR", Value: appTargetAddr},
+		{Name: "INBOUND_PORTS", Value: inboundPorts},
+		{Name: "INBOUND_PLAIN_PORT", Value: strconv.Itoa(s.cfg.InboundPlainPort)},
+		{Name: "OUTBOUND_PORT", Value: strconv.Itoa(s.cfg.OutboundPort)},
+		{Name: "INBOUND_MTLS_PORT", Value: strconv.Itoa(s.cfg.InboundMTLSPort)},
+		{Name: "MTLS_ENABLED", Value: strconv.FormatBool(s.cfg.MTLSEnabled)},
+		{Name: "MTLS_MODE", Value: s.cfg.MTLSMode},
+		{Name: "METRICS_PORT", Value: strconv.Itoa(s.cfg.MetricsPort)},
+		{Name: "METRICS_DROP_LABELS", Value: s.cfg.MetricsDropLabels},
+		{Name: "ADMIN_PORT", Value: strconv.Itoa(s.cfg.AdminPort)},
+		{Name: "STATUS_PORT", Value: strconv.Itoa(s.cfg.StatusPort)},
+		{Name: "ADMIN_PPROF_ENABLED", Value: strconv.FormatBool(s.cfg.AdminPprofEnabled)},
+		{Name: "LOG_LEVEL", Value: s.cfg.LogLevel},
+		{Name: "DRAIN_DURATION", Value: s.cfg.DrainDuration.String()},
+		{Name: "SHUTDOWN_TIMEOUT", Value: s.cfg.SidecarShutdownTimeout.String()},
+		{Name: "CONFIG_MAP_NAMESPACE", Value: s.cfg.SidecarConfigMapNamespace},
+		{Name: "BOOTSTRAP_CERTIFICATES", Value: strconv.FormatBool(s.cfg.MTLSEnabled)},
+		{Name: "CERT_FILE", Value: "/etc/mesh/certs/tls.crt"},
+		{Name: "KEY_FILE", Value: "/etc/mesh/certs/tls.key"},
+		{Name: "CA_FILE", Value: "/etc/mesh/ca/ca.crt"},
+		{Name: "LOAD_BALANCER_ALGORITHM", Value: s.cfg.LoadBalancerAlgorithm},
+		{Name: "COPY_MODE", Value: s.cfg.CopyMode},
+		{Name: "RETRY_ATTEMPTS", Value: strconv.Itoa(s.cfg.RetryAttempts)},
+		{Name: "TIMEOUT", Value: s.cfg.ConnectTimeout.String()},
+		{Name: "CIRCUIT_BREAKER_FAILURE_THRESHOLD", Value: strconv.Itoa(s.cfg.CircuitBreakerFailureThreshold)},
+		{Name: "CIRCUIT_BREAKER_RECOVERY_TIME", Value: s.cfg.CircuitBreakerRecoveryTime.String()},
+		{Name: "LOCALITY_ROUTING_ENABLED", Value: strconv.FormatBool(s.cfg.LocalityRoutingEnabled)},
+		{Name: "LOCALITY_SPILLOVER_THRESHOLD", Value: strconv.FormatFloat(s.cfg.LocalitySpilloverThreshold, 'f', -1, 64)},
+		{Name: "OUTLIER_CONSECUTIVE_CONNECT_FAILURES", Value: strconv.Itoa(s.cfg.OutlierConsecutiveConnectFailures)},
+		{Name: "OUTLIER_CONSECUTIVE_5XX", Value: strconv.Itoa(s.cfg.OutlierConsecutive5xx)},
+		{Name: "OUTLIER_BASE_EJECTION_TIME", Value: s.cfg.OutlierBaseEjectionTime.String()},
+		{Name: "OUTLIER_MAX_EJECTION_TIME", Value: s.cfg.OutlierMaxEjectionTime.String()},
+		{Name: "OUTLIER_MAX_EJECTION_PERCENT", Value: strconv.Itoa(s.cfg.OutlierMaxEjectionPercent)},
+		{Name: "HEALTH_CHECK_TYPE", Value: s.cfg.HealthCheckType},
+		{Name: "HEALTH_CHECK_PATH", Value: s.cfg.HealthCheckPath},
+		{Name: "HEALTH_CHECK_INTERVAL", Value: s.cfg.HealthCheckInterval.String()},
+		{Name: "HEALTH_CHECK_TIMEOUT", Value: s.cfg.HealthCheckTimeout.String()},
+		{Name: "HEALTH_CHECK_JITTER", Value: strconv.FormatFloat(s.cfg.HealthCheckJitter, 'f', -1, 64)},
+		{Name: "HEALTH_CHECK_HEALTHY_THRESHOLD", Value: strconv.Itoa(s.cfg.HealthCheckHealthyThreshold)},
+		{Name: "HEALTH_CHECK_UNHEALTHY_THRESHOLD", Value: strconv.Itoa(s.cfg.HealthCheckUnhealthyThreshold)},
+		{Name: "TRACING_ENDPOINT", Value: s.cfg.TracingEndpoint},
+		{Name: "TRACING_SAMPLING_RATE", Value: strconv.FormatFloat(s.cfg.TracingSamplingRate, 'f', -1, 64)},
+		{Name: "ACCESS_LOG_ENABLED", Value: strconv.FormatBool(s.cfg.AccessLogEnabled)},
+		{Name: "ACCESS_LOG_FORMAT", Value: s.cfg.AccessLogFormat},
+		{Name: "ACCESS_LOG_TEMPLATE", Value: s.cfg.AccessLogTemplate},
+		{Name: "ACCESS_LOG_PATH", Value: s.cfg.AccessLogPath},
+		{Name: "ACCESS_LOG_MAX_SIZE_MB", Value: strconv.Itoa(s.cfg.AccessLogMaxSizeMB)},
+		{Name: "ACCESS_LOG_MAX_BACKUPS", Value: strconv.Itoa(s.cfg.AccessLogMaxBackups)},
+		{Name: "ACCESS_LOG_SAMPLING_RATE", Value: strconv.FormatFloat(s.cfg.AccessLogSamplingRate, 'f', -1, 64)},
+		{Name: "ACCESS_LOG_SERVICES", Value: s.cfg.AccessLogServices},
+	}
+
+	if workloadMTLSMode != "" {
+		env = append(env, corev1.EnvVar{Name: "WORKLOAD_MTLS_MODE", Value: workloadMTLSMode})
+	}
+
+	if appProbes != "" {
+		env = append(env, corev1.EnvVar{Name: "APP_PROBES", Value: appProbes})
+	}
+
+	readinessHandler := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path: sidecarReadinessPath,
+			Port: intstr.FromInt32(int32(s.cfg.StatusPort)),
+		},
+	}
+
+	// preStop runs before SIGTERM, so clients move away while the listeners still serve.
+	lifecycle := &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{Command: []string{"/sidecar", "drain"}},
+		},
+	}
+	if holdApplication {
+		lifecycle.PostStart = &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{Command: []string{"/sidecar", "wait"}},
+		}
+	}
+
 	return corev1.Container{
 		Name:            containerNameSidecar,
 		Image:           s.cfg.SidecarImage,
@@ -413,40 +736,23 @@ func (s *Service) buildSidecarContainer(serviceAccountName string, uid int64, ap
 			},
 		},
 		Ports: ports,
-		Env: []corev1.EnvVar{
-			{
-				Name: "POD_NAME",
-				ValueFrom: &corev1.EnvVarSource{
-					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
-				},
-			},
-			{
-				Name: "POD_NAMESPACE",
-				ValueFrom: &corev1.EnvVarSource{
-					FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
-				},
-			},
-			{Name: "SERVICE_ACCOUNT", Value: serviceAccountName},
-			{Name: "APP_TARGET_ADDR", Value: appTargetAddr},
-			{Name: "INBOUND_PLAIN_PORT", Value: strconv.Itoa(s.cfg.InboundPlainPort)},
-			{Name: "OUTBOUND_PORT", Value: strconv.Itoa(s.cfg.OutboundPort)},
-			{Name: "INBOUND_MTLS_PORT", Value: strconv.Itoa(s.cfg.InboundMTLSPort)},
-			{Name: "MTLS_ENABLED", Value: strconv.FormatBool(s.cfg.MTLSEnabled)},
-			{Name: "METRICS_PORT", Value: strconv.Itoa(s.cfg.MetricsPort)},
-			{Name: "BOOTSTRAP_CERTIFICATES", Value: strconv.FormatBool(s.cfg.MTLSEnabled)},
-			{Name: "CERT_FILE", Value: "/etc/mesh/certs/tls.crt"},
-			{Name: "KEY_FILE", Value: "/etc/mesh/certs/tls.key"},
-			{Name: "CA_FILE", Value: "/etc/mesh/ca/ca.crt"},
-			{Name: "LOAD_BALANCER_ALGORITHM", Value: s.cfg.LoadBalancerAlgorithm},
-			{Name: "COPY_MODE", Value: s.cfg.CopyMode},
-			{Name: "RETRY_ATTEMPTS", Value: strconv.Itoa(s.cfg.RetryAttempts)},
-			{Name: "TIMEOUT", Value: s.cfg.ConnectTimeout.String()},
-			{Name: "CIRCUIT_BREAKER_FAILURE_THRESHOLD", Value: strconv.Itoa(s.cfg.CircuitBreakerFailureThreshold)},
-			{Name: "CIRCUIT_BREAKER_RECOVERY_TIME", Value: s.cfg.CircuitBreakerRecoveryTime.String()},
-		},
+		Env:   env,
 		VolumeMounts: []corev1.VolumeMount{
 			{Name: volumeNameMeshCA, MountPath: "/etc/mesh/ca", ReadOnly: true},
 		},
+		StartupProbe: &corev1.Probe{
+			ProbeHandler:     readinessHandler,
+			PeriodSeconds:    1,
+			TimeoutSeconds:   3,
+			FailureThreshold: 600,
+		},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler:     readinessHandler,
+			PeriodSeconds:    5,
+			TimeoutSeconds:   3,
+			FailureThreshold: 3,
+		},
+		Lifecycle: lifecycle,
 	}
 }
 
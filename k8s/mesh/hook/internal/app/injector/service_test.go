@@ -1,6 +1,7 @@
 package injector
 
 import (
+	"encoding/json"
 	"io"
 	"log"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/LLIEPJIOK/service-mesh/hook/internal/config"
 )
@@ -108,7 +110,274 @@ func TestBuildPatchInjectsExpectedResources(t *testing.T) {
 	}
 }
 
+func TestBuildPatchPropagatesWorkloadMTLSMode(t *testing.T) {
+	svc := newTestService()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "demo-app",
+			Namespace:   "default",
+			Annotations: map[string]string{annotationMTLSMode: "strict"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "demo:v1"}},
+		},
+	}
+
+	decision, err := svc.BuildPatch(&admissionv1.AdmissionRequest{Namespace: "default"}, pod)
+	if err != nil {
+		t.Fatalf("BuildPatch() error = %v", err)
+	}
+
+	patch := string(decision.Patch)
+	if !strings.Contains(patch, `{"name":"WORKLOAD_MTLS_MODE","value":"STRICT"}`) {
+		t.Fatalf("patch does not contain workload mtls mode: %s", patch)
+	}
+
+	if !strings.Contains(patch, `{"name":"MTLS_MODE","value":"PERMISSIVE"}`) {
+		t.Fatalf("patch does not contain mesh-wide mtls mode: %s", patch)
+	}
+}
+
+func TestBuildPatchHoldsApplicationUntilProxyIsReady(t *testing.T) {
+	svc := newTestService()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "demo-app",
+			Namespace:   "default",
+			Annotations: map[string]string{annotationHoldApplication: "true"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: "demo:v1"}},
+		},
+	}
+
+	decision, err := svc.BuildPatch(&admissionv1.AdmissionRequest{Namespace: "default"}, pod)
+	if err != nil {
+		t.Fatalf("BuildPatch() error = %v", err)
+	}
+
+	var operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(decision.Patch, &operations); err != nil {
+		t.Fatalf("unmarshal patch: %v", err)
+	}
+
+	var sidecar *corev1.Container
+	for _, operation := range operations {
+		if operation.Path != "/spec/containers/0" {
+			continue
+		}
+
+		sidecar = &corev1.Container{}
+		if err := json.Unmarshal(operation.Value, sidecar); err != nil {
+			t.Fatalf("unmarshal sidecar container: %v", err)
+		}
+	}
+
+	if sidecar == nil || sidecar.Name != containerNameSidecar {
+		t.Fatalf("sidecar is not injected in front of the application: %s", decision.Patch)
+	}
+
+	if sidecar.Lifecycle == nil || sidecar.Lifecycle.PostStart == nil || sidecar.Lifecycle.PostStart.Exec == nil {
+		t.Fatalf("sidecar has no postStart hook: %+v", sidecar.Lifecycle)
+	}
+
+	for name, probe := range map[string]*corev1.Probe{"startup": sidecar.StartupProbe, "readiness": sidecar.ReadinessProbe} {
+		if probe == nil || probe.HTTPGet == nil {
+			t.Fatalf("%s probe is missing", name)
+		}
+		if probe.HTTPGet.Path != "/healthz/ready" || probe.HTTPGet.Port.IntValue() != 15021 {
+			t.Fatalf("%s probe = %s:%s, want /healthz/ready:15021", name, probe.HTTPGet.Path, probe.HTTPGet.Port.String())
+		}
+	}
+}
+
+func TestBuildPatchLeavesRoomForSidecarDrain(t *testing.T) {
+	svc := newTestService()
+
+	gracePeriod := int64(10)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-app", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			TerminationGracePeriodSeconds: &gracePeriod,
+			Containers:                    []corev1.Container{{Name: "app", Image: "demo:v1"}},
+		},
+	}
+
+	decision, err := svc.BuildPatch(&admissionv1.AdmissionRequest{Namespace: "default"}, pod)
+	if err != nil {
+		t.Fatalf("BuildPatch() error = %v", err)
+	}
+
+	var operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(decision.Patch, &operations); err != nil {
+		t.Fatalf("unmarshal patch: %v", err)
+	}
+
+	var sidecar *corev1.Container
+	var gracePeriodOp string
+	var gracePeriodValue string
+	for _, operation := range operations {
+		switch operation.Path {
+		case "/spec/containers/-":
+			sidecar = &corev1.Container{}
+			if err := json.Unmarshal(operation.Value, sidecar); err != nil {
+				t.Fatalf("unmarshal sidecar container: %v", err)
+			}
+		case "/spec/terminationGracePeriodSeconds":
+			gracePeriodOp = operation.Op
+			gracePeriodValue = string(operation.Value)
+		}
+	}
+
+	if gracePeriodOp != "replace" || gracePeriodValue != "35" {
+		t.Fatalf("terminationGracePeriodSeconds op=%q value=%s, want replace 35", gracePeriodOp, gracePeriodValue)
+	}
+
+	if sidecar == nil || sidecar.Lifecycle == nil || sidecar.Lifecycle.PreStop == nil || sidecar.Lifecycle.PreStop.Exec == nil {
+		t.Fatalf("sidecar has no preStop hook: %s", decision.Patch)
+	}
+	if got := strings.Join(sidecar.Lifecycle.PreStop.Exec.Command, " "); got != "/sidecar drain" {
+		t.Fatalf("preStop command = %q, want /sidecar drain", got)
+	}
+	if sidecar.Lifecycle.PostStart != nil {
+		t.Fatalf("sidecar has a postStart hook without hold-application: %+v", sidecar.Lifecycle.PostStart)
+	}
+}
+
+func TestBuildPatchMovesAppProbesToStatusPort(t *testing.T) {
+	svc := newTestService()
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "reviews-v1",
+			Namespace:   "default",
+			Annotations: map[string]string{annotationHoldApplication: "true"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "reviews",
+				Image: "reviews:v1",
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 9080}},
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{
+						Path:   "/ready",
+						Port:   intstr.FromString("http"),
+						Scheme: corev1.URISchemeHTTPS,
+					}},
+					PeriodSeconds: 7,
+				},
+				LivenessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(9080)}},
+				},
+				StartupProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}},
+				},
+			}},
+		},
+	}
+
+	decision, err := svc.BuildPatch(&admissionv1.AdmissionRequest{Namespace: "default"}, pod)
+	if err != nil {
+		t.Fatalf("BuildPatch() error = %v", err)
+	}
+
+	var operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(decision.Patch, &operations); err != nil {
+		t.Fatalf("unmarshal patch: %v", err)
+	}
+
+	probes := make(map[string]corev1.Probe)
+	var sidecar *corev1.Container
+	for _, operation := range operations {
+		switch operation.Path {
+		case "/spec/containers/0":
+			sidecar = &corev1.Container{}
+			if err := json.Unmarshal(operation.Value, sidecar); err != nil {
+				t.Fatalf("unmarshal sidecar container: %v", err)
+			}
+		case "/spec/containers/0/readinessProbe", "/spec/containers/0/livenessProbe", "/spec/containers/0/startupProbe":
+			if sidecar != nil {
+				t.Fatalf("probe %s is patched after the sidecar shifted the containers", operation.Path)
+			}
+
+			var probe corev1.Probe
+			if err := json.Unmarshal(operation.Value, &probe); err != nil {
+				t.Fatalf("unmarshal probe: %v", err)
+			}
+			probes[operation.Path] = probe
+		}
+	}
+
+	if _, ok := probes["/spec/containers/0/startupProbe"]; ok {
+		t.Fatal("exec startup probe is rewritten")
+	}
+
+	for path, want := range map[string]string{
+		"/spec/containers/0/readinessProbe": "/app-health/reviews/readyz",
+		"/spec/containers/0/livenessProbe":  "/app-health/reviews/livez",
+	} {
+		probe, ok := probes[path]
+		if !ok || probe.HTTPGet == nil {
+			t.Fatalf("%s is not rewritten: %s", path, decision.Patch)
+		}
+		if probe.HTTPGet.Path != want || probe.HTTPGet.Port.IntValue() != 15021 || probe.HTTPGet.Scheme != corev1.URISchemeHTTP {
+			t.Fatalf("%s = %s %s:%s, want HTTP %s:15021", path, probe.HTTPGet.Scheme, probe.HTTPGet.Path, probe.HTTPGet.Port.String(), want)
+		}
+	}
+	if got := probes["/spec/containers/0/readinessProbe"].PeriodSeconds; got != 7 {
+		t.Fatalf("readiness periodSeconds = %d, want 7", got)
+	}
+
+	if sidecar == nil {
+		t.Fatalf("sidecar is not injected: %s", decision.Patch)
+	}
+
+	var appProbes string
+	for _, env := range sidecar.Env {
+		if env.Name == "APP_PROBES" {
+			appProbes = env.Value
+		}
+	}
+
+	want := `{"/app-health/reviews/livez":{"tcpSocket":{"port":9080}},"/app-health/reviews/readyz":{"httpGet":{"path":"/ready","port":9080,"scheme":"HTTPS"}}}`
+	if appProbes != want {
+		t.Fatalf("APP_PROBES = %s, want %s", appProbes, want)
+	}
+}
+
 func TestBuildPatchIsIdempotent(t *testing.T) {
+	shortGracePeriod := int64(10)
+	gracePeriod := int64(35)
+
+	for _, tt := range []struct {
+		name        string
+		gracePeriod *int64
+	}{
+		{name: "sufficient grace period", gracePeriod: &gracePeriod},
+		{name: "short grace period", gracePeriod: &shortGracePeriod},
+		{name: "default grace period", gracePeriod: nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			testBuildPatchIsIdempotent(t, tt.gracePeriod)
+		})
+	}
+}
+
+func testBuildPatchIsIdempotent(t *testing.T, gracePeriod *int64) {
 	svc := newTestService()
 
 	uid := int64(1337)
@@ -127,7 +396,8 @@ func TestBuildPatchIsIdempotent(t *testing.T) {
 			},
 		},
 		Spec: corev1.PodSpec{
-			ServiceAccountName: "demo-app",
+			ServiceAccountName:            "demo-app",
+			TerminationGracePeriodSeconds: gracePeriod,
 			InitContainers: []corev1.Container{{
 				Name: containerNameIptables,
 			}},
@@ -218,12 +488,17 @@ func newTestService() *Service {
 		IptablesImage:                  "mesh/iptables-init:latest",
 		SidecarImage:                   "mesh/sidecar:latest",
 		MeshVersion:                    "v0.1.0",
+		TrustDomain:                    "cluster.local",
 		MonitoringEnabled:              true,
 		MetricsPort:                    9090,
+		StatusPort:                     15021,
+		DrainDuration:                  5 * time.Second,
+		SidecarShutdownTimeout:         30 * time.Second,
 		InboundPlainPort:               15006,
 		OutboundPort:                   15002,
 		InboundMTLSPort:                15001,
 		MTLSEnabled:                    true,
+		MTLSMode:                       "PERMISSIVE",
 		ExcludeInbound:                 "9090",
 		ExcludeOutbound:                "169.254.169.254/32",
 		SidecarUID:                     1337,
@@ -21,24 +21,65 @@ type Config struct {
 	IptablesImage string
 	SidecarImage  string
 	MeshVersion   string
+	TrustDomain   string
 
 	MonitoringEnabled bool
 	MetricsPort       int
+	MetricsDropLabels string
+
+	AdminPort         int
+	StatusPort        int
+	AdminPprofEnabled bool
+	LogLevel          string
 
 	InboundPlainPort int
 	OutboundPort     int
 	InboundMTLSPort  int
 	MTLSEnabled      bool
+	MTLSMode         string
 	ExcludeInbound   string
 	ExcludeOutbound  string
 	SidecarUID       int64
 
+	HoldApplicationUntilProxyStarts bool
+	DrainDuration                   time.Duration
+	SidecarShutdownTimeout          time.Duration
+	SidecarConfigMapNamespace       string
+
 	LoadBalancerAlgorithm          string
 	CopyMode                       string
 	RetryAttempts                  int
 	ConnectTimeout                 time.Duration
 	CircuitBreakerFailureThreshold int
 	CircuitBreakerRecoveryTime     time.Duration
+	LocalityRoutingEnabled         bool
+	LocalitySpilloverThreshold     float64
+
+	OutlierConsecutiveConnectFailures int
+	OutlierConsecutive5xx             int
+	OutlierBaseEjectionTime           time.Duration
+	OutlierMaxEjectionTime            time.Duration
+	OutlierMaxEjectionPercent         int
+
+	HealthCheckType               string
+	HealthCheckPath               string
+	HealthCheckInterval           time.Duration
+	HealthCheckTimeout            time.Duration
+	HealthCheckJitter             float64
+	HealthCheckHealthyThreshold   int
+	HealthCheckUnhealthyThreshold int
+
+	TracingEndpoint     string
+	TracingSamplingRate float64
+
+	AccessLogEnabled      bool
+	AccessLogFormat       string
+	AccessLogTemplate     string
+	AccessLogPath         string
+	AccessLogMaxSizeMB    int
+	AccessLogMaxBackups   int
+	AccessLogSamplingRate float64
+	AccessLogServices     string
 }
 
 func LoadFromEnv() (Config, error) {
@@ -61,24 +102,65 @@ func LoadFromEnv() (Config, error) {
 		IptablesImage: envString("mesh/iptables-init:latest", "IPTABLES_IMAGE"),
 		SidecarImage:  envString("mesh/sidecar:latest", "SIDECAR_IMAGE"),
 		MeshVersion:   envString("v0.1.0", "MESH_VERSION"),
+		TrustDomain:   envString("cluster.local", "TRUST_DOMAIN"),
 
 		MonitoringEnabled: envBool(true, "MONITORING_ENABLED"),
 		MetricsPort:       envInt(9090, "METRICS_PORT"),
+		MetricsDropLabels: envString("", "METRICS_DROP_LABELS"),
+
+		AdminPort:         envInt(15000, "ADMIN_PORT"),
+		StatusPort:        envInt(15021, "STATUS_PORT"),
+		AdminPprofEnabled: envBool(false, "ADMIN_PPROF_ENABLED"),
+		LogLevel:          envString("info", "LOG_LEVEL"),
 
 		InboundPlainPort: envInt(15006, "INBOUND_PLAIN_PORT"),
 		OutboundPort:     envInt(15002, "OUTBOUND_PORT"),
 		InboundMTLSPort:  envInt(15001, "INBOUND_MTLS_PORT"),
 		MTLSEnabled:      envBool(true, "MTLS_ENABLED"),
+		MTLSMode:         strings.ToUpper(envString("PERMISSIVE", "MTLS_MODE")),
 		ExcludeInbound:   envString("9090", "EXCLUDE_INBOUND_PORTS"),
 		ExcludeOutbound:  envString("169.254.169.254/32", "EXCLUDE_OUTBOUND_IPS"),
 		SidecarUID:       envInt64(1337, "SIDECAR_UID"),
 
+		HoldApplicationUntilProxyStarts: envBool(false, "HOLD_APPLICATION_UNTIL_PROXY_STARTS"),
+		DrainDuration:                   envDuration(5*time.Second, "DRAIN_DURATION"),
+		SidecarShutdownTimeout:          envDuration(30*time.Second, "SIDECAR_SHUTDOWN_TIMEOUT"),
+		SidecarConfigMapNamespace:       envString("mesh-system", "SIDECAR_CONFIG_MAP_NAMESPACE"),
+
 		LoadBalancerAlgorithm:          envString("roundRobin", "LOAD_BALANCER_ALGORITHM"),
 		CopyMode:                       envString("buffered", "COPY_MODE"),
 		RetryAttempts:                  envInt(3, "RETRY_ATTEMPTS"),
 		ConnectTimeout:                 envDuration(5*time.Second, "TIMEOUT"),
 		CircuitBreakerFailureThreshold: envInt(5, "CIRCUIT_BREAKER_FAILURE_THRESHOLD"),
 		CircuitBreakerRecoveryTime:     envDuration(30*time.Second, "CIRCUIT_BREAKER_RECOVERY_TIME"),
+		LocalityRoutingEnabled:         envBool(true, "LOCALITY_ROUTING_ENABLED"),
+		LocalitySpilloverThreshold:     envFloat64(0.2, "LOCALITY_SPILLOVER_THRESHOLD"),
+
+		OutlierConsecutiveConnectFailures: envInt(5, "OUTLIER_CONSECUTIVE_CONNECT_FAILURES"),
+		OutlierConsecutive5xx:             envInt(5, "OUTLIER_CONSECUTIVE_5XX"),
+		OutlierBaseEjectionTime:           envDuration(30*time.Second, "OUTLIER_BASE_EJECTION_TIME"),
+		OutlierMaxEjectionTime:            envDuration(300*time.Second, "OUTLIER_MAX_EJECTION_TIME"),
+		OutlierMaxEjectionPercent:         envInt(10, "OUTLIER_MAX_EJECTION_PERCENT"),
+
+		HealthCheckType:               envString("none", "HEALTH_CHECK_TYPE"),
+		HealthCheckPath:               envString("/healthz", "HEALTH_CHECK_PATH"),
+		HealthCheckInterval:           envDuration(10*time.Second, "HEALTH_CHECK_INTERVAL"),
+		HealthCheckTimeout:            envDuration(time.Second, "HEALTH_CHECK_TIMEOUT"),
+		HealthCheckJitter:             envFloat64(0.2, "HEALTH_CHECK_JITTER"),
+		HealthCheckHealthyThreshold:   envInt(2, "HEALTH_CHECK_HEALTHY_THRESHOLD"),
+		HealthCheckUnhealthyThreshold: envInt(3, "HEALTH_CHECK_UNHEALTHY_THRESHOLD"),
+
+		TracingEndpoint:     envString("", "TRACING_ENDPOINT"),
+		TracingSamplingRate: envFloat64(0.01, "TRACING_SAMPLING_RATE"),
+
+		AccessLogEnabled:      envBool(false, "ACCESS_LOG_ENABLED"),
+		AccessLogFormat:       envString("json", "ACCESS_LOG_FORMAT"),
+		AccessLogTemplate:     envString("", "ACCESS_LOG_TEMPLATE"),
+		AccessLogPath:         envString("/dev/stdout", "ACCESS_LOG_PATH"),
+		AccessLogMaxSizeMB:    envInt(100, "ACCESS_LOG_MAX_SIZE_MB"),
+		AccessLogMaxBackups:   envInt(3, "ACCESS_LOG_MAX_BACKUPS"),
+		AccessLogSamplingRate: envFloat64(1, "ACCESS_LOG_SAMPLING_RATE"),
+		AccessLogServices:     envString("", "ACCESS_LOG_SERVICES"),
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -113,6 +195,10 @@ func (c Config) Validate() error {
 		return fmt.Errorf("MESH_VERSION must not be empty")
 	}
 
+	if c.TrustDomain == "" || strings.ContainsAny(c.TrustDomain, "/:") {
+		return fmt.Errorf("TRUST_DOMAIN must be a bare host name")
+	}
+
 	if c.MetricsPort <= 0 || c.InboundPlainPort <= 0 || c.OutboundPort <= 0 {
 		return fmt.Errorf("metrics, inbound plain and outbound ports must be positive")
 	}
@@ -121,14 +207,46 @@ func (c Config) Validate() error {
 		return fmt.Errorf("INBOUND_MTLS_PORT must be non-negative")
 	}
 
+	if c.StatusPort <= 0 {
+		return fmt.Errorf("STATUS_PORT must be positive")
+	}
+
+	if c.AdminPort < 0 {
+		return fmt.Errorf("ADMIN_PORT must be non-negative")
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("LOG_LEVEL must be one of debug, info, warn or error")
+	}
+
 	if c.MTLSEnabled && c.InboundMTLSPort <= 0 {
 		return fmt.Errorf("INBOUND_MTLS_PORT must be positive when MTLS_ENABLED=true")
 	}
 
+	switch c.MTLSMode {
+	case "PERMISSIVE":
+	case "STRICT":
+		if !c.MTLSEnabled {
+			return fmt.Errorf("MTLS_MODE=STRICT requires MTLS_ENABLED=true")
+		}
+	default:
+		return fmt.Errorf("MTLS_MODE must be either STRICT or PERMISSIVE")
+	}
+
 	if c.SidecarUID <= 0 {
 		return fmt.Errorf("SIDECAR_UID must be positive")
 	}
 
+	if c.DrainDuration < 0 {
+		return fmt.Errorf("DRAIN_DURATION must be non-negative")
+	}
+
+	if c.SidecarShutdownTimeout <= 0 {
+		return fmt.Errorf("SIDECAR_SHUTDOWN_TIMEOUT must be positive")
+	}
+
 	if c.RetryAttempts < 0 {
 		return fmt.Errorf("RETRY_ATTEMPTS must be non-negative")
 	}
@@ -143,6 +261,12 @@ func (c Config) Validate() error {
 		return fmt.Errorf("COPY_MODE must be either buffered or zero-copy")
 	}
 
+	switch c.LoadBalancerAlgorithm {
+	case "none", "roundRobin", "random", "leastRequest", "leastConnection":
+	default:
+		return fmt.Errorf("LOAD_BALANCER_ALGORITHM must be one of none, roundRobin, random, leastRequest or leastConnection")
+	}
+
 	if c.CircuitBreakerFailureThreshold < 0 {
 		return fmt.Errorf("CIRCUIT_BREAKER_FAILURE_THRESHOLD must be non-negative")
 	}
@@ -151,6 +275,58 @@ func (c Config) Validate() error {
 		return fmt.Errorf("CIRCUIT_BREAKER_RECOVERY_TIME must be positive")
 	}
 
+	if c.LocalitySpilloverThreshold < 0 || c.LocalitySpilloverThreshold > 1 {
+		return fmt.Errorf("LOCALITY_SPILLOVER_THRESHOLD must be within [0, 1]")
+	}
+
+	if c.OutlierConsecutiveConnectFailures < 0 || c.OutlierConsecutive5xx < 0 {
+		return fmt.Errorf("OUTLIER_CONSECUTIVE_CONNECT_FAILURES and OUTLIER_CONSECUTIVE_5XX must be non-negative")
+	}
+
+	if c.OutlierBaseEjectionTime <= 0 || c.OutlierMaxEjectionTime < c.OutlierBaseEjectionTime {
+		return fmt.Errorf("OUTLIER_BASE_EJECTION_TIME must be positive and not exceed OUTLIER_MAX_EJECTION_TIME")
+	}
+
+	if c.OutlierMaxEjectionPercent < 0 || c.OutlierMaxEjectionPercent > 100 {
+		return fmt.Errorf("OUTLIER_MAX_EJECTION_PERCENT must be within [0, 100]")
+	}
+
+	switch c.HealthCheckType {
+	case "none", "tcp", "http", "grpc":
+	default:
+		return fmt.Errorf("HEALTH_CHECK_TYPE must be one of none, tcp, http or grpc")
+	}
+
+	if c.HealthCheckInterval <= 0 || c.HealthCheckTimeout <= 0 {
+		return fmt.Errorf("HEALTH_CHECK_INTERVAL and HEALTH_CHECK_TIMEOUT must be positive")
+	}
+
+	if c.HealthCheckJitter < 0 || c.HealthCheckJitter >= 1 {
+		return fmt.Errorf("HEALTH_CHECK_JITTER must be within [0, 1)")
+	}
+
+	if c.HealthCheckHealthyThreshold <= 0 || c.HealthCheckUnhealthyThreshold <= 0 {
+		return fmt.Errorf("HEALTH_CHECK_HEALTHY_THRESHOLD and HEALTH_CHECK_UNHEALTHY_THRESHOLD must be positive")
+	}
+
+	if c.TracingSamplingRate < 0 || c.TracingSamplingRate > 1 {
+		return fmt.Errorf("TRACING_SAMPLING_RATE must be within [0, 1]")
+	}
+
+	switch c.AccessLogFormat {
+	case "json", "text":
+	default:
+		return fmt.Errorf("ACCESS_LOG_FORMAT must be one of json or text")
+	}
+
+	if c.AccessLogSamplingRate <= 0 || c.AccessLogSamplingRate > 1 {
+		return fmt.Errorf("ACCESS_LOG_SAMPLING_RATE must be within (0, 1]")
+	}
+
+	if c.AccessLogMaxSizeMB <= 0 || c.AccessLogMaxBackups < 0 {
+		return fmt.Errorf("ACCESS_LOG_MAX_SIZE_MB must be positive and ACCESS_LOG_MAX_BACKUPS non-negative")
+	}
+
 	return nil
 }
 
@@ -205,6 +381,20 @@ func envDuration(fallback time.Duration, key string) time.Duration {
 	return parsed
 }
 
+func envFloat64(fallback float64, key string) float64 {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fallback
+	}
+
+	return parsed
+}
+
 func envBool(fallback bool, key string) bool {
 	value := strings.TrimSpace(os.Getenv(key))
 	if value == "" {
@@ -15,6 +15,7 @@ import (
 	"math/big"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,6 +28,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	intstr "k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -43,6 +45,7 @@ const (
 	certManagerDeploymentName      = "mesh-cert-manager"
 	certManagerServiceName         = "mesh-cert-manager"
 	sidecarConfigMapName           = "mesh-sidecar-config"
+	sidecarConfigReaderName        = "mesh-sidecar-config-reader"
 	webhookServiceAccountName      = "mesh-webhook"
 	webhookDeploymentName          = "mesh-webhook"
 	webhookServiceName             = "mesh-webhook"
@@ -53,6 +56,7 @@ const (
 
 type Client struct {
 	clientset *kubernetes.Clientset
+	dynamic   dynamic.Interface
 	logger    *log.Logger
 }
 
@@ -71,7 +75,12 @@ func NewClient(kubeconfigPath string, logger *log.Logger) (*Client, error) {
 		return nil, fmt.Errorf("create kubernetes client: %w", err)
 	}
 
-	return &Client{clientset: clientset, logger: logger}, nil
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes dynamic client: %w", err)
+	}
+
+	return &Client{clientset: clientset, dynamic: dynamicClient, logger: logger}, nil
 }
 
 func buildRESTConfig(kubeconfigPath string) (*rest.Config, string, error) {
@@ -183,11 +192,12 @@ func (c *Client) ApplyWebhookTLSSecret(ctx context.Context, namespace string, ce
 
 func (c *Client) ApplySidecarConfigMap(ctx context.Context, cfg config.MeshConfig, namespace string, dryRun bool) error {
 	sidecarYAML := fmt.Sprintf(
-		"inboundPlainPort: %d\noutboundPort: %d\ninboundMTLSPort: %d\nmtlsEnabled: %t\nmetricsPort: %d\nmonitoringEnabled: %t\nloadBalancerAlgorithm: %s\ncopyMode: %s\nretryPolicy:\n  attempts: %d\n  backoff:\n    type: %s\n    baseInterval: %s\ntimeout: %s\ncircuitBreakerPolicy:\n  failureThreshold: %d\n  recoveryTime: %s\nexcludeInboundPorts: %s\nexcludeOutboundIPs: %s\n",
+		"inboundPlainPort: %d\noutboundPort: %d\ninboundMTLSPort: %d\nmtlsEnabled: %t\nmtlsMode: %s\nmetricsPort: %d\nmonitoringEnabled: %t\nloadBalancerAlgorithm: %s\ncopyMode: %s\nretryPolicy:\n  attempts: %d\n  backoff:\n    type: %s\n    baseInterval: %s\n  retryOn: [%s]\n  retriableStatusCodes: [%s]\n  perTryTimeout: %s\n  bodyBufferLimit: %d\n  budget:\n    percent: %g\n    minRetriesPerSecond: %d\n    window: %s\ntimeout: %s\ncircuitBreakerPolicy:\n  failureThreshold: %d\n  recoveryTime: %s\nexcludeInboundPorts: %s\nexcludeOutboundIPs: %s\n",
 		cfg.Spec.Sidecar.InboundPlainPort,
 		cfg.Spec.Sidecar.OutboundPort,
 		cfg.Spec.Sidecar.InboundMTLSPort,
 		cfg.Spec.Sidecar.MTLSEnabledValue(),
+		cfg.Spec.Sidecar.MTLSMode,
 		cfg.Spec.Sidecar.MetricsPort,
 		cfg.Spec.Sidecar.MonitoringEnabled,
 		cfg.Spec.Sidecar.LoadBalancerAlgorithm,
@@ -195,6 +205,13 @@ func (c *Client) ApplySidecarConfigMap(ctx context.Context, cfg config.MeshConfi
 		cfg.Spec.Sidecar.RetryPolicy.Attempts,
 		cfg.Spec.Sidecar.RetryPolicy.Backoff.Type,
 		cfg.Spec.Sidecar.RetryPolicy.Backoff.BaseInterval,
+		strings.Join(cfg.Spec.Sidecar.RetryPolicy.RetryOn, ", "),
+		joinInts(cfg.Spec.Sidecar.RetryPolicy.RetriableStatusCodes),
+		cfg.Spec.Sidecar.RetryPolicy.PerTryTimeout,
+		cfg.Spec.Sidecar.RetryPolicy.BodyBufferLimit,
+		cfg.Spec.Sidecar.RetryPolicy.Budget.Percent,
+		cfg.Spec.Sidecar.RetryPolicy.Budget.MinRetriesPerSecond,
+		cfg.Spec.Sidecar.RetryPolicy.Budget.Window,
 		cfg.Spec.Sidecar.Timeout,
 		cfg.Spec.Sidecar.CircuitBreakerPolicy.FailureThreshold,
 		cfg.Spec.Sidecar.CircuitBreakerPolicy.RecoveryTime,
@@ -213,7 +230,44 @@ func (c *Client) ApplySidecarConfigMap(ctx context.Context, cfg config.MeshConfi
 		},
 	}
 
-	return c.upsertConfigMap(ctx, desired, dryRun)
+	if err := c.upsertConfigMap(ctx, desired, dryRun); err != nil {
+		return err
+	}
+
+	// Sidecars run under the workload service accounts and watch this ConfigMap for hot reload.
+	if err := c.upsertRole(ctx, &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sidecarConfigReaderName,
+			Namespace: namespace,
+			Labels:    labels("mesh-sidecar-config"),
+		},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: []string{sidecarConfigMapName},
+			Verbs:         []string{"get", "list", "watch"},
+		}},
+	}, dryRun); err != nil {
+		return err
+	}
+
+	return c.upsertRoleBinding(ctx, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sidecarConfigReaderName,
+			Namespace: namespace,
+			Labels:    labels("mesh-sidecar-config"),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     sidecarConfigReaderName,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:     "Group",
+			APIGroup: "rbac.authorization.k8s.io",
+			Name:     "system:serviceaccounts",
+		}},
+	}, dryRun)
 }
 
 func (c *Client) ApplyCertManagerResources(ctx context.Context, cfg config.MeshConfig, namespace string, dryRun bool) error {
@@ -276,7 +330,7 @@ func (c *Client) ApplyCertManagerResources(ctx context.Context, cfg config.MeshC
 						Image:           resolveImage(cfg.Spec.Images.CertManager, "mesh/cert-manager", cfg.Spec.Version),
 						ImagePullPolicy: corev1.PullIfNotPresent,
 						Ports:           []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
-						Env:             []corev1.EnvVar{{Name: "HTTP_ADDR", Value: ":8080"}, {Name: "ROOT_CA_CERT_FILE", Value: "/etc/mesh/ca/tls.crt"}, {Name: "ROOT_CA_KEY_FILE", Value: "/etc/mesh/ca/tls.key"}, {Name: "LEAF_TTL", Value: cfg.Spec.Certificates.Validity}},
+						Env:             []corev1.EnvVar{{Name: "HTTP_ADDR", Value: ":8080"}, {Name: "ROOT_CA_CERT_FILE", Value: "/etc/mesh/ca/tls.crt"}, {Name: "ROOT_CA_KEY_FILE", Value: "/etc/mesh/ca/tls.key"}, {Name: "LEAF_TTL", Value: cfg.Spec.Certificates.Validity}, {Name: "TRUST_DOMAIN", Value: cfg.Spec.Certificates.TrustDomain}},
 						VolumeMounts:    []corev1.VolumeMount{{Name: "mesh-root-ca", MountPath: "/etc/mesh/ca", ReadOnly: true}},
 						StartupProbe:    &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString("http")}}, PeriodSeconds: 5, TimeoutSeconds: 3, FailureThreshold: 30},
 						ReadinessProbe:  &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString("http")}}, InitialDelaySeconds: 5, PeriodSeconds: 5, TimeoutSeconds: 3, FailureThreshold: 6},
@@ -348,6 +402,7 @@ func (c *Client) ApplyWebhookResources(ctx context.Context, cfg config.MeshConfi
 							{Name: "TLS_CERT_FILE", Value: "/tls/tls.crt"},
 							{Name: "TLS_KEY_FILE", Value: "/tls/tls.key"},
 							{Name: "MESH_VERSION", Value: cfg.Spec.Version},
+							{Name: "TRUST_DOMAIN", Value: cfg.Spec.Certificates.TrustDomain},
 							{Name: "SIDECAR_IMAGE", Value: resolveImage(cfg.Spec.Images.Sidecar, "mesh/sidecar", cfg.Spec.Version)},
 							{Name: "IPTABLES_IMAGE", Value: resolveImage(cfg.Spec.Images.IptablesInit, "mesh/iptables-init", cfg.Spec.Version)},
 							{Name: "MONITORING_ENABLED", Value: boolToString(cfg.Spec.Sidecar.MonitoringEnabled)},
@@ -355,7 +410,17 @@ func (c *Client) ApplyWebhookResources(ctx context.Context, cfg config.MeshConfi
 							{Name: "OUTBOUND_PORT", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.OutboundPort)},
 							{Name: "INBOUND_MTLS_PORT", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.InboundMTLSPort)},
 							{Name: "MTLS_ENABLED", Value: boolToString(cfg.Spec.Sidecar.MTLSEnabledValue())},
+							{Name: "MTLS_MODE", Value: cfg.Spec.Sidecar.MTLSMode},
 							{Name: "METRICS_PORT", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.MetricsPort)},
+							{Name: "METRICS_DROP_LABELS", Value: cfg.Spec.Sidecar.MetricsDropLabels},
+							{Name: "ADMIN_PORT", Value: fmt.Sprintf("%d", *cfg.Spec.Sidecar.Admin.Port)},
+							{Name: "STATUS_PORT", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.StatusPort)},
+							{Name: "HOLD_APPLICATION_UNTIL_PROXY_STARTS", Value: boolToString(cfg.Spec.Sidecar.HoldApplicationUntilProxyStarts)},
+							{Name: "DRAIN_DURATION", Value: cfg.Spec.Sidecar.DrainDuration},
+							{Name: "SIDECAR_SHUTDOWN_TIMEOUT", Value: cfg.Spec.Sidecar.ShutdownTimeout},
+							{Name: "SIDECAR_CONFIG_MAP_NAMESPACE", Value: namespace},
+							{Name: "ADMIN_PPROF_ENABLED", Value: boolToString(cfg.Spec.Sidecar.Admin.PprofEnabled)},
+							{Name: "LOG_LEVEL", Value: cfg.Spec.Sidecar.LogLevel},
 							{Name: "EXCLUDE_INBOUND_PORTS", Value: cfg.Spec.Sidecar.ExcludeInboundPorts},
 							{Name: "EXCLUDE_OUTBOUND_IPS", Value: cfg.Spec.Sidecar.ExcludeOutboundIPs},
 							{Name: "SIDECAR_UID", Value: "1337"},
@@ -365,6 +430,30 @@ func (c *Client) ApplyWebhookResources(ctx context.Context, cfg config.MeshConfi
 							{Name: "TIMEOUT", Value: cfg.Spec.Sidecar.Timeout},
 							{Name: "CIRCUIT_BREAKER_FAILURE_THRESHOLD", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.CircuitBreakerPolicy.FailureThreshold)},
 							{Name: "CIRCUIT_BREAKER_RECOVERY_TIME", Value: cfg.Spec.Sidecar.CircuitBreakerPolicy.RecoveryTime},
+							{Name: "LOCALITY_ROUTING_ENABLED", Value: boolToString(*cfg.Spec.Sidecar.LocalityRouting.Enabled)},
+							{Name: "LOCALITY_SPILLOVER_THRESHOLD", Value: strconv.FormatFloat(*cfg.Spec.Sidecar.LocalityRouting.SpilloverThreshold, 'f', -1, 64)},
+							{Name: "OUTLIER_CONSECUTIVE_CONNECT_FAILURES", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.OutlierDetection.ConsecutiveConnectFailures)},
+							{Name: "OUTLIER_CONSECUTIVE_5XX", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.OutlierDetection.Consecutive5xx)},
+							{Name: "OUTLIER_BASE_EJECTION_TIME", Value: cfg.Spec.Sidecar.OutlierDetection.BaseEjectionTime},
+							{Name: "OUTLIER_MAX_EJECTION_TIME", Value: cfg.Spec.Sidecar.OutlierDetection.MaxEjectionTime},
+							{Name: "OUTLIER_MAX_EJECTION_PERCENT", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.OutlierDetection.MaxEjectionPercent)},
+							{Name: "HEALTH_CHECK_TYPE", Value: cfg.Spec.Sidecar.HealthCheck.Type},
+							{Name: "HEALTH_CHECK_PATH", Value: cfg.Spec.Sidecar.HealthCheck.Path},
+							{Name: "HEALTH_CHECK_INTERVAL", Value: cfg.Spec.Sidecar.HealthCheck.Interval},
+							{Name: "HEALTH_CHECK_TIMEOUT", Value: cfg.Spec.Sidecar.HealthCheck.Timeout},
+							{Name: "HEALTH_CHECK_JITTER", Value: strconv.FormatFloat(*cfg.Spec.Sidecar.HealthCheck.Jitter, 'f', -1, 64)},
+							{Name: "HEALTH_CHECK_HEALTHY_THRESHOLD", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.HealthCheck.HealthyThreshold)},
+							{Name: "HEALTH_CHECK_UNHEALTHY_THRESHOLD", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.HealthCheck.UnhealthyThreshold)},
+							{Name: "TRACING_ENDPOINT", Value: cfg.Spec.Sidecar.Tracing.Endpoint},
+							{Name: "TRACING_SAMPLING_RATE", Value: strconv.FormatFloat(*cfg.Spec.Sidecar.Tracing.SamplingRate, 'f', -1, 64)},
+							{Name: "ACCESS_LOG_ENABLED", Value: boolToString(cfg.Spec.Sidecar.AccessLog.Enabled)},
+							{Name: "ACCESS_LOG_FORMAT", Value: cfg.Spec.Sidecar.AccessLog.Format},
+							{Name: "ACCESS_LOG_TEMPLATE", Value: cfg.Spec.Sidecar.AccessLog.Template},
+							{Name: "ACCESS_LOG_PATH", Value: cfg.Spec.Sidecar.AccessLog.Path},
+							{Name: "ACCESS_LOG_MAX_SIZE_MB", Value: fmt.Sprintf("%d", cfg.Spec.Sidecar.AccessLog.MaxSizeMB)},
+							{Name: "ACCESS_LOG_MAX_BACKUPS", Value: fmt.Sprintf("%d", *cfg.Spec.Sidecar.AccessLog.MaxBackups)},
+							{Name: "ACCESS_LOG_SAMPLING_RATE", Value: strconv.FormatFloat(*cfg.Spec.Sidecar.AccessLog.SamplingRate, 'f', -1, 64)},
+							{Name: "ACCESS_LOG_SERVICES", Value: cfg.Spec.Sidecar.AccessLog.Services},
 						},
 						VolumeMounts:   []corev1.VolumeMount{{Name: "webhook-tls", MountPath: "/tls", ReadOnly: true}},
 						StartupProbe:   &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Scheme: corev1.URISchemeHTTPS, Path: "/healthz", Port: intstr.FromString("https")}}, PeriodSeconds: 5, TimeoutSeconds: 3, FailureThreshold: 30},
@@ -508,6 +597,18 @@ func (c *Client) DeleteRootCASecret(ctx context.Context, namespace string, dryRu
 }
 
 func (c *Client) DeleteSidecarConfigMap(ctx context.Context, namespace string, dryRun bool) error {
+	if err := c.deleteIgnoreNotFound(func() error {
+		return c.clientset.RbacV1().RoleBindings(namespace).Delete(ctx, sidecarConfigReaderName, deleteOptions(dryRun))
+	}, "delete sidecar config role binding"); err != nil {
+		return err
+	}
+
+	if err := c.deleteIgnoreNotFound(func() error {
+		return c.clientset.RbacV1().Roles(namespace).Delete(ctx, sidecarConfigReaderName, deleteOptions(dryRun))
+	}, "delete sidecar config role"); err != nil {
+		return err
+	}
+
 	return c.deleteIgnoreNotFound(func() error {
 		return c.clientset.CoreV1().ConfigMaps(namespace).Delete(ctx, sidecarConfigMapName, deleteOptions(dryRun))
 	}, "delete sidecar config map")
@@ -746,6 +847,50 @@ func (c *Client) upsertClusterRoleBinding(ctx context.Context, desired *rbacv1.C
 	return nil
 }
 
+func (c *Client) upsertRole(ctx context.Context, desired *rbacv1.Role, dryRun bool) error {
+	roles := c.clientset.RbacV1().Roles(desired.Namespace)
+	existing, err := roles.Get(ctx, desired.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("get role %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		_, err := roles.Create(ctx, desired, createOptions(dryRun))
+		if err != nil {
+			return fmt.Errorf("create role %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		return nil
+	}
+
+	desired.ResourceVersion = existing.ResourceVersion
+	_, err = roles.Update(ctx, desired, updateOptions(dryRun))
+	if err != nil {
+		return fmt.Errorf("update role %s/%s: %w", desired.Namespace, desired.Name, err)
+	}
+	return nil
+}
+
+func (c *Client) upsertRoleBinding(ctx context.Context, desired *rbacv1.RoleBinding, dryRun bool) error {
+	bindings := c.clientset.RbacV1().RoleBindings(desired.Namespace)
+	existing, err := bindings.Get(ctx, desired.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("get rolebinding %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		_, err := bindings.Create(ctx, desired, createOptions(dryRun))
+		if err != nil {
+			return fmt.Errorf("create rolebinding %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+		return nil
+	}
+
+	desired.ResourceVersion = existing.ResourceVersion
+	_, err = bindings.Update(ctx, desired, updateOptions(dryRun))
+	if err != nil {
+		return fmt.Errorf("update rolebinding %s/%s: %w", desired.Namespace, desired.Name, err)
+	}
+	return nil
+}
+
 func (c *Client) upsertDeployment(ctx context.Context, desired *appsv1.Deployment, dryRun bool) error {
 	deployments := c.clientset.AppsV1().Deployments(desired.Namespace)
 	existing, err := deployments.Get(ctx, desired.Name, metav1.GetOptions{})
@@ -888,6 +1033,14 @@ func boolToString(value bool) string {
 	return "false"
 }
 
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, strconv.Itoa(value))
+	}
+	return strings.Join(parts, ", ")
+}
+
 func ParseCertificateValidity(value string) (time.Duration, error) {
 	duration, err := time.ParseDuration(strings.TrimSpace(value))
 	if err != nil {
@@ -0,0 +1,227 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const meshAPIGroup = "mesh.io"
+
+var customResourceDefinitionResource = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+func (c *Client) ApplyCustomResourceDefinitions(ctx context.Context, dryRun bool) error {
+	for _, desired := range meshCustomResourceDefinitions() {
+		if err := c.upsertCustomResourceDefinition(ctx, desired, dryRun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func meshCustomResourceDefinitions() []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		namespacedCRD("AuthorizationPolicy", "authorizationpolicies", "authorizationpolicy", authorizationPolicySchema()),
+		namespacedCRD("PeerAuthentication", "peerauthentications", "peerauthentication", peerAuthenticationSchema()),
+		namespacedCRD("TrafficPolicy", "trafficpolicies", "trafficpolicy", trafficPolicySchema()),
+	}
+}
+
+func namespacedCRD(kind string, plural string, singular string, specSchema map[string]any) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata": map[string]any{
+			"name":   plural + "." + meshAPIGroup,
+			"labels": stringMapToAny(labels("mesh-crds")),
+		},
+		"spec": map[string]any{
+			"group": meshAPIGroup,
+			"scope": "Namespaced",
+			"names": map[string]any{
+				"kind":     kind,
+				"listKind": kind + "List",
+				"plural":   plural,
+				"singular": singular,
+			},
+			"versions": []any{map[string]any{
+				"name":    "v1alpha1",
+				"served":  true,
+				"storage": true,
+				"schema": map[string]any{
+					"openAPIV3Schema": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"spec": specSchema,
+						},
+					},
+				},
+			}},
+		},
+	}}
+}
+
+func authorizationPolicySchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"selector": workloadSelectorSchema(),
+			"action": map[string]any{
+				"type": "string",
+				"enum": []any{"ALLOW", "DENY"},
+			},
+			"rules": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"principals": stringArraySchema(),
+						"ports": map[string]any{
+							"type":  "array",
+							"items": map[string]any{"type": "integer", "minimum": int64(1), "maximum": int64(65535)},
+						},
+						"methods": stringArraySchema(),
+						"paths":   stringArraySchema(),
+					},
+				},
+			},
+		},
+	}
+}
+
+func peerAuthenticationSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"selector": workloadSelectorSchema(),
+			"mtls": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"mode": map[string]any{
+						"type": "string",
+						"enum": []any{"STRICT", "PERMISSIVE"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func trafficPolicySchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []any{"host"},
+		"properties": map[string]any{
+			"host": map[string]any{"type": "string"},
+			"port": map[string]any{"type": "integer", "minimum": int64(1), "maximum": int64(65535)},
+			"loadBalancerAlgorithm": map[string]any{
+				"type": "string",
+				"enum": []any{"none", "roundRobin", "random", "leastRequest", "leastConnection", "ringHash"},
+			},
+			"loadBalancerHashKey": map[string]any{"type": "string"},
+			"retryPolicy": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"attempts": map[string]any{"type": "integer", "minimum": int64(0)},
+					"backoff": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"type": map[string]any{
+								"type": "string",
+								"enum": []any{"linear", "exponential"},
+							},
+							"baseInterval": map[string]any{"type": "string"},
+						},
+					},
+					"retryOn": map[string]any{
+						"type": "array",
+						"items": map[string]any{
+							"type": "string",
+							"enum": []any{"5xx", "gateway-error", "reset", "retriable-status-codes", "unavailable"},
+						},
+					},
+					"retriableStatusCodes": map[string]any{
+						"type":  "array",
+						"items": map[string]any{"type": "integer", "minimum": int64(100), "maximum": int64(599)},
+					},
+					"perTryTimeout":   map[string]any{"type": "string"},
+					"bodyBufferLimit": map[string]any{"type": "integer", "minimum": int64(0)},
+					"budget": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"percent":             map[string]any{"type": "number", "minimum": int64(0)},
+							"minRetriesPerSecond": map[string]any{"type": "integer", "minimum": int64(0)},
+							"window":              map[string]any{"type": "string"},
+						},
+					},
+				},
+			},
+			"timeout": map[string]any{"type": "string"},
+			"circuitBreakerPolicy": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"failureThreshold": map[string]any{"type": "integer", "minimum": int64(0)},
+					"recoveryTime":     map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+}
+
+func workloadSelectorSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"matchLabels": map[string]any{
+				"type":                 "object",
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+		},
+	}
+}
+
+func stringArraySchema() map[string]any {
+	return map[string]any{
+		"type":  "array",
+		"items": map[string]any{"type": "string"},
+	}
+}
+
+func stringMapToAny(values map[string]string) map[string]any {
+	result := make(map[string]any, len(values))
+	for key, value := range values {
+		result[key] = value
+	}
+	return result
+}
+
+func (c *Client) upsertCustomResourceDefinition(ctx context.Context, desired *unstructured.Unstructured, dryRun bool) error {
+	definitions := c.dynamic.Resource(customResourceDefinitionResource)
+	existing, err := definitions.Get(ctx, desired.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("get customresourcedefinition %s: %w", desired.GetName(), err)
+		}
+		_, err := definitions.Create(ctx, desired, createOptions(dryRun))
+		if err != nil {
+			return fmt.Errorf("create customresourcedefinition %s: %w", desired.GetName(), err)
+		}
+		return nil
+	}
+
+	desired.SetResourceVersion(existing.GetResourceVersion())
+	_, err = definitions.Update(ctx, desired, updateOptions(dryRun))
+	if err != nil {
+		return fmt.Errorf("update customresourcedefinition %s: %w", desired.GetName(), err)
+	}
+	return nil
+}
@@ -24,7 +24,7 @@ func NewService(kubeClient *kube.Client, logger *log.Logger) *Service {
 func BuildPlan(namespace string) []string {
 	return []string{
 		"1) create namespace " + namespace,
-		"2) apply CRDs (MVP: no-op)",
+		"2) apply CRDs (AuthorizationPolicy, PeerAuthentication, TrafficPolicy)",
 		"3) create root CA secret mesh-root-ca",
 		"4) install cert-manager (ServiceAccount, ClusterRole, ClusterRoleBinding, Deployment, Service)",
 		"5) apply sidecar default ConfigMap mesh-sidecar-config",
@@ -59,7 +59,9 @@ func (s *Service) Install(ctx context.Context, cfg config.MeshConfig, opts domai
 		return err
 	}
 
-	s.logger.Printf("CRD phase: no resources defined in MVP, skipping")
+	if err := s.kubeClient.ApplyCustomResourceDefinitions(ctx, false); err != nil {
+		return err
+	}
 
 	rootCert := []byte(strings.TrimSpace(cfg.Spec.Certificates.RootCA.Cert) + "\n")
 	rootKey := []byte(strings.TrimSpace(cfg.Spec.Certificates.RootCA.Key) + "\n")
@@ -36,8 +36,9 @@ type Images struct {
 }
 
 type Certificates struct {
-	RootCA   RootCA `yaml:"rootCA"`
-	Validity string `yaml:"validity"`
+	RootCA      RootCA `yaml:"rootCA"`
+	Validity    string `yaml:"validity"`
+	TrustDomain string `yaml:"trustDomain"`
 }
 
 type RootCA struct {
@@ -46,24 +47,58 @@ type RootCA struct {
 }
 
 type SidecarConfig struct {
-	InboundPlainPort      int            `yaml:"inboundPlainPort"`
-	OutboundPort          int            `yaml:"outboundPort"`
-	InboundMTLSPort       int            `yaml:"inboundMTLSPort"`
-	MTLSEnabled           *bool          `yaml:"mtlsEnabled,omitempty"`
-	MetricsPort           int            `yaml:"metricsPort"`
-	MonitoringEnabled     bool           `yaml:"monitoringEnabled"`
-	LoadBalancerAlgorithm string         `yaml:"loadBalancerAlgorithm"`
-	CopyMode              string         `yaml:"copyMode"`
-	RetryPolicy           RetryPolicy    `yaml:"retryPolicy"`
-	Timeout               string         `yaml:"timeout"`
-	CircuitBreakerPolicy  CircuitBreaker `yaml:"circuitBreakerPolicy"`
-	ExcludeInboundPorts   string         `yaml:"excludeInboundPorts"`
-	ExcludeOutboundIPs    string         `yaml:"excludeOutboundIPs"`
+	InboundPlainPort                int              `yaml:"inboundPlainPort"`
+	OutboundPort                    int              `yaml:"outboundPort"`
+	InboundMTLSPort                 int              `yaml:"inboundMTLSPort"`
+	MTLSEnabled                     *bool            `yaml:"mtlsEnabled,omitempty"`
+	MTLSMode                        string           `yaml:"mtlsMode"`
+	MetricsPort                     int              `yaml:"metricsPort"`
+	MetricsDropLabels               string           `yaml:"metricsDropLabels"`
+	MonitoringEnabled               bool             `yaml:"monitoringEnabled"`
+	Admin                           Admin            `yaml:"admin"`
+	StatusPort                      int              `yaml:"statusPort"`
+	HoldApplicationUntilProxyStarts bool             `yaml:"holdApplicationUntilProxyStarts"`
+	DrainDuration                   string           `yaml:"drainDuration"`
+	ShutdownTimeout                 string           `yaml:"shutdownTimeout"`
+	LogLevel                        string           `yaml:"logLevel"`
+	LoadBalancerAlgorithm           string           `yaml:"loadBalancerAlgorithm"`
+	LocalityRouting                 LocalityRouting  `yaml:"localityRouting"`
+	CopyMode                        string           `yaml:"copyMode"`
+	RetryPolicy                     RetryPolicy      `yaml:"retryPolicy"`
+	Timeout                         string           `yaml:"timeout"`
+	CircuitBreakerPolicy            CircuitBreaker   `yaml:"circuitBreakerPolicy"`
+	OutlierDetection                OutlierDetection `yaml:"outlierDetection"`
+	HealthCheck                     HealthCheck      `yaml:"healthCheck"`
+	Tracing                         Tracing          `yaml:"tracing"`
+	AccessLog                       AccessLog        `yaml:"accessLog"`
+	ExcludeInboundPorts             string           `yaml:"excludeInboundPorts"`
+	ExcludeOutboundIPs              string           `yaml:"excludeOutboundIPs"`
+}
+
+type Admin struct {
+	Port         *int `yaml:"port,omitempty"`
+	PprofEnabled bool `yaml:"pprofEnabled"`
+}
+
+type LocalityRouting struct {
+	Enabled            *bool    `yaml:"enabled,omitempty"`
+	SpilloverThreshold *float64 `yaml:"spilloverThreshold,omitempty"`
 }
 
 type RetryPolicy struct {
-	Attempts int     `yaml:"attempts"`
-	Backoff  Backoff `yaml:"backoff"`
+	Attempts             int         `yaml:"attempts"`
+	Backoff              Backoff     `yaml:"backoff"`
+	RetryOn              []string    `yaml:"retryOn"`
+	RetriableStatusCodes []int       `yaml:"retriableStatusCodes"`
+	PerTryTimeout        string      `yaml:"perTryTimeout"`
+	BodyBufferLimit      int         `yaml:"bodyBufferLimit"`
+	Budget               RetryBudget `yaml:"budget"`
+}
+
+type RetryBudget struct {
+	Percent             float64 `yaml:"percent"`
+	MinRetriesPerSecond int     `yaml:"minRetriesPerSecond"`
+	Window              string  `yaml:"window"`
 }
 
 type Backoff struct {
@@ -76,6 +111,40 @@ type CircuitBreaker struct {
 	RecoveryTime     string `yaml:"recoveryTime"`
 }
 
+type OutlierDetection struct {
+	ConsecutiveConnectFailures int    `yaml:"consecutiveConnectFailures"`
+	Consecutive5xx             int    `yaml:"consecutive5xx"`
+	BaseEjectionTime           string `yaml:"baseEjectionTime"`
+	MaxEjectionTime            string `yaml:"maxEjectionTime"`
+	MaxEjectionPercent         int    `yaml:"maxEjectionPercent"`
+}
+
+type HealthCheck struct {
+	Type               string   `yaml:"type"`
+	Path               string   `yaml:"path"`
+	Interval           string   `yaml:"interval"`
+	Timeout            string   `yaml:"timeout"`
+	Jitter             *float64 `yaml:"jitter,omitempty"`
+	HealthyThreshold   int      `yaml:"healthyThreshold"`
+	UnhealthyThreshold int      `yaml:"unhealthyThreshold"`
+}
+
+type Tracing struct {
+	Endpoint     string   `yaml:"endpoint"`
+	SamplingRate *float64 `yaml:"samplingRate,omitempty"`
+}
+
+type AccessLog struct {
+	Enabled      bool     `yaml:"enabled"`
+	Format       string   `yaml:"format"`
+	Template     string   `yaml:"template"`
+	Path         string   `yaml:"path"`
+	MaxSizeMB    int      `yaml:"maxSizeMB"`
+	MaxBackups   *int     `yaml:"maxBackups,omitempty"`
+	SamplingRate *float64 `yaml:"samplingRate,omitempty"`
+	Services     string   `yaml:"services"`
+}
+
 type InjectionConfig struct {
 	NamespaceSelector NamespaceSelector `yaml:"namespaceSelector"`
 }
@@ -128,12 +197,35 @@ func (c *MeshConfig) ApplyDefaults() {
 	if c.Spec.Sidecar.MetricsPort == 0 {
 		c.Spec.Sidecar.MetricsPort = 9090
 	}
+	if c.Spec.Sidecar.StatusPort == 0 {
+		c.Spec.Sidecar.StatusPort = 15021
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.DrainDuration) == "" {
+		c.Spec.Sidecar.DrainDuration = "5s"
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.ShutdownTimeout) == "" {
+		c.Spec.Sidecar.ShutdownTimeout = "30s"
+	}
+	if c.Spec.Sidecar.Admin.Port == nil {
+		port := 15000
+		c.Spec.Sidecar.Admin.Port = &port
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.LogLevel) == "" {
+		c.Spec.Sidecar.LogLevel = "info"
+	}
 	if strings.TrimSpace(c.Spec.Sidecar.LoadBalancerAlgorithm) == "" {
 		c.Spec.Sidecar.LoadBalancerAlgorithm = "roundRobin"
 	}
 	if strings.TrimSpace(c.Spec.Sidecar.CopyMode) == "" {
 		c.Spec.Sidecar.CopyMode = "buffered"
 	}
+	if c.Spec.Sidecar.LocalityRouting.Enabled == nil {
+		c.Spec.Sidecar.LocalityRouting.Enabled = boolPtr(true)
+	}
+	if c.Spec.Sidecar.LocalityRouting.SpilloverThreshold == nil {
+		threshold := 0.2
+		c.Spec.Sidecar.LocalityRouting.SpilloverThreshold = &threshold
+	}
 
 	if c.Spec.Sidecar.MTLSEnabled == nil {
 		if c.Spec.Sidecar.InboundMTLSPort == 0 {
@@ -152,18 +244,98 @@ func (c *MeshConfig) ApplyDefaults() {
 		c.Spec.Sidecar.InboundMTLSPort = 0
 	}
 
+	if strings.TrimSpace(c.Spec.Sidecar.MTLSMode) == "" {
+		c.Spec.Sidecar.MTLSMode = "PERMISSIVE"
+	}
+
 	if strings.TrimSpace(c.Spec.Sidecar.Timeout) == "" {
 		c.Spec.Sidecar.Timeout = "5s"
 	}
 	if c.Spec.Sidecar.RetryPolicy.Attempts == 0 {
 		c.Spec.Sidecar.RetryPolicy.Attempts = 3
 	}
+	if len(c.Spec.Sidecar.RetryPolicy.RetryOn) == 0 {
+		c.Spec.Sidecar.RetryPolicy.RetryOn = []string{"gateway-error", "reset", "unavailable"}
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.RetryPolicy.PerTryTimeout) == "" {
+		c.Spec.Sidecar.RetryPolicy.PerTryTimeout = "0s"
+	}
+	if c.Spec.Sidecar.RetryPolicy.BodyBufferLimit == 0 {
+		c.Spec.Sidecar.RetryPolicy.BodyBufferLimit = 64 * 1024
+	}
+	if c.Spec.Sidecar.RetryPolicy.Budget.Percent == 0 {
+		c.Spec.Sidecar.RetryPolicy.Budget.Percent = 20
+	}
+	if c.Spec.Sidecar.RetryPolicy.Budget.MinRetriesPerSecond == 0 {
+		c.Spec.Sidecar.RetryPolicy.Budget.MinRetriesPerSecond = 10
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.RetryPolicy.Budget.Window) == "" {
+		c.Spec.Sidecar.RetryPolicy.Budget.Window = "10s"
+	}
 	if strings.TrimSpace(c.Spec.Sidecar.CircuitBreakerPolicy.RecoveryTime) == "" {
 		c.Spec.Sidecar.CircuitBreakerPolicy.RecoveryTime = "30s"
 	}
 	if c.Spec.Sidecar.CircuitBreakerPolicy.FailureThreshold == 0 {
 		c.Spec.Sidecar.CircuitBreakerPolicy.FailureThreshold = 5
 	}
+	if c.Spec.Sidecar.OutlierDetection.ConsecutiveConnectFailures == 0 {
+		c.Spec.Sidecar.OutlierDetection.ConsecutiveConnectFailures = 5
+	}
+	if c.Spec.Sidecar.OutlierDetection.Consecutive5xx == 0 {
+		c.Spec.Sidecar.OutlierDetection.Consecutive5xx = 5
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.OutlierDetection.BaseEjectionTime) == "" {
+		c.Spec.Sidecar.OutlierDetection.BaseEjectionTime = "30s"
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.OutlierDetection.MaxEjectionTime) == "" {
+		c.Spec.Sidecar.OutlierDetection.MaxEjectionTime = "300s"
+	}
+	if c.Spec.Sidecar.OutlierDetection.MaxEjectionPercent == 0 {
+		c.Spec.Sidecar.OutlierDetection.MaxEjectionPercent = 10
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.HealthCheck.Type) == "" {
+		c.Spec.Sidecar.HealthCheck.Type = "none"
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.HealthCheck.Path) == "" {
+		c.Spec.Sidecar.HealthCheck.Path = "/healthz"
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.HealthCheck.Interval) == "" {
+		c.Spec.Sidecar.HealthCheck.Interval = "10s"
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.HealthCheck.Timeout) == "" {
+		c.Spec.Sidecar.HealthCheck.Timeout = "1s"
+	}
+	if c.Spec.Sidecar.HealthCheck.Jitter == nil {
+		jitter := 0.2
+		c.Spec.Sidecar.HealthCheck.Jitter = &jitter
+	}
+	if c.Spec.Sidecar.HealthCheck.HealthyThreshold == 0 {
+		c.Spec.Sidecar.HealthCheck.HealthyThreshold = 2
+	}
+	if c.Spec.Sidecar.HealthCheck.UnhealthyThreshold == 0 {
+		c.Spec.Sidecar.HealthCheck.UnhealthyThreshold = 3
+	}
+	if c.Spec.Sidecar.Tracing.SamplingRate == nil {
+		rate := 0.01
+		c.Spec.Sidecar.Tracing.SamplingRate = &rate
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.AccessLog.Format) == "" {
+		c.Spec.Sidecar.AccessLog.Format = "json"
+	}
+	if strings.TrimSpace(c.Spec.Sidecar.AccessLog.Path) == "" {
+		c.Spec.Sidecar.AccessLog.Path = "/dev/stdout"
+	}
+	if c.Spec.Sidecar.AccessLog.MaxSizeMB == 0 {
+		c.Spec.Sidecar.AccessLog.MaxSizeMB = 100
+	}
+	if c.Spec.Sidecar.AccessLog.MaxBackups == nil {
+		backups := 3
+		c.Spec.Sidecar.AccessLog.MaxBackups = &backups
+	}
+	if c.Spec.Sidecar.AccessLog.SamplingRate == nil {
+		rate := 1.0
+		c.Spec.Sidecar.AccessLog.SamplingRate = &rate
+	}
 	if strings.TrimSpace(c.Spec.Sidecar.ExcludeInboundPorts) == "" {
 		c.Spec.Sidecar.ExcludeInboundPorts = "9090"
 	}
@@ -174,6 +346,9 @@ func (c *MeshConfig) ApplyDefaults() {
 	if strings.TrimSpace(c.Spec.Certificates.Validity) == "" {
 		c.Spec.Certificates.Validity = "8760h"
 	}
+	if strings.TrimSpace(c.Spec.Certificates.TrustDomain) == "" {
+		c.Spec.Certificates.TrustDomain = "cluster.local"
+	}
 
 	if len(c.Spec.Injection.NamespaceSelector.MatchLabels) == 0 {
 		c.Spec.Injection.NamespaceSelector.MatchLabels = map[string]string{"mesh-injection": "enabled"}
@@ -212,12 +387,82 @@ func (c MeshConfig) Validate() error {
 		return fmt.Errorf("spec.sidecar.inboundMTLSPort must be positive when spec.sidecar.mtlsEnabled=true")
 	}
 
+	switch c.Spec.Sidecar.MTLSMode {
+	case "PERMISSIVE":
+	case "STRICT":
+		if !c.Spec.Sidecar.MTLSEnabledValue() {
+			return fmt.Errorf("spec.sidecar.mtlsMode=STRICT requires spec.sidecar.mtlsEnabled=true")
+		}
+	default:
+		return fmt.Errorf("spec.sidecar.mtlsMode must be either STRICT or PERMISSIVE")
+	}
+
 	switch c.Spec.Sidecar.CopyMode {
 	case "buffered", "zero-copy":
 	default:
 		return fmt.Errorf("spec.sidecar.copyMode must be either buffered or zero-copy")
 	}
 
+	if threshold := *c.Spec.Sidecar.LocalityRouting.SpilloverThreshold; threshold < 0 || threshold > 1 {
+		return fmt.Errorf("spec.sidecar.localityRouting.spilloverThreshold must be within [0, 1]")
+	}
+
+	if percent := c.Spec.Sidecar.OutlierDetection.MaxEjectionPercent; percent < 0 || percent > 100 {
+		return fmt.Errorf("spec.sidecar.outlierDetection.maxEjectionPercent must be within [0, 100]")
+	}
+
+	switch c.Spec.Sidecar.HealthCheck.Type {
+	case "none", "tcp", "http", "grpc":
+	default:
+		return fmt.Errorf("spec.sidecar.healthCheck.type must be one of none, tcp, http or grpc")
+	}
+
+	if jitter := *c.Spec.Sidecar.HealthCheck.Jitter; jitter < 0 || jitter >= 1 {
+		return fmt.Errorf("spec.sidecar.healthCheck.jitter must be within [0, 1)")
+	}
+
+	if rate := *c.Spec.Sidecar.Tracing.SamplingRate; rate < 0 || rate > 1 {
+		return fmt.Errorf("spec.sidecar.tracing.samplingRate must be within [0, 1]")
+	}
+
+	if port := c.Spec.Sidecar.StatusPort; port < 1 || port > 65535 {
+		return fmt.Errorf("spec.sidecar.statusPort must be within [1, 65535]")
+	}
+
+	if port := *c.Spec.Sidecar.Admin.Port; port < 0 || port > 65535 {
+		return fmt.Errorf("spec.sidecar.admin.port must be within [0, 65535]")
+	}
+
+	switch strings.ToLower(c.Spec.Sidecar.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("spec.sidecar.logLevel must be one of debug, info, warn or error")
+	}
+
+	switch c.Spec.Sidecar.AccessLog.Format {
+	case "json", "text":
+	default:
+		return fmt.Errorf("spec.sidecar.accessLog.format must be one of json or text")
+	}
+
+	if rate := *c.Spec.Sidecar.AccessLog.SamplingRate; rate <= 0 || rate > 1 {
+		return fmt.Errorf("spec.sidecar.accessLog.samplingRate must be within (0, 1]")
+	}
+
+	if c.Spec.Sidecar.AccessLog.MaxSizeMB < 0 || *c.Spec.Sidecar.AccessLog.MaxBackups < 0 {
+		return fmt.Errorf("spec.sidecar.accessLog.maxSizeMB and maxBackups must be non-negative")
+	}
+
+	switch c.Spec.Sidecar.LoadBalancerAlgorithm {
+	case "none", "roundRobin", "random", "leastRequest", "leastConnection":
+	default:
+		return fmt.Errorf("spec.sidecar.loadBalancerAlgorithm must be one of none, roundRobin, random, leastRequest or leastConnection")
+	}
+
+	if strings.ContainsAny(c.Spec.Certificates.TrustDomain, "/:") {
+		return fmt.Errorf("spec.certificates.trustDomain must be a bare host name")
+	}
+
 	if strings.TrimSpace(c.Spec.Certificates.RootCA.Cert) == "" || strings.TrimSpace(c.Spec.Certificates.RootCA.Key) == "" {
 		return fmt.Errorf("spec.certificates.rootCA.cert and spec.certificates.rootCA.key are required")
 	}
@@ -7,12 +7,18 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/LLIEPJIOK/sidecar/internal/app/sidecar"
 	"github.com/LLIEPJIOK/sidecar/internal/config"
 )
 
-const exitCodeError = 1
+const (
+	exitCodeError = 1
+
+	waitTimeout  = 2 * time.Minute
+	waitInterval = 500 * time.Millisecond
+)
 
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -24,7 +30,41 @@ func main() {
 		os.Exit(exitCodeError)
 	}
 
-	service, err := sidecar.New(cfg)
+	// "sidecar wait" runs as the postStart hook that holds application containers.
+	if len(os.Args) > 1 && os.Args[1] == "wait" {
+		waitCtx, cancel := context.WithTimeout(ctx, waitTimeout)
+		defer cancel()
+
+		if err := sidecar.WaitReady(waitCtx, cfg.StatusPort, waitInterval); err != nil {
+			slog.Error("sidecar did not become ready", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		return
+	}
+
+	// "sidecar drain" runs as the preStop hook.
+	if len(os.Args) > 1 && os.Args[1] == "drain" {
+		drainCtx, cancel := context.WithTimeout(ctx, cfg.DrainDuration+waitInterval)
+		defer cancel()
+
+		if err := sidecar.Drain(drainCtx, cfg.StatusPort, cfg.DrainDuration); err != nil {
+			slog.Error("sidecar drain failed", slog.Any("error", err))
+			os.Exit(exitCodeError)
+		}
+		return
+	}
+
+	level, err := cfg.SlogLevel()
+	if err != nil {
+		slog.Error("failed to parse log level", slog.Any("error", err))
+		os.Exit(exitCodeError)
+	}
+
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level)
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+
+	service, err := sidecar.New(cfg, logLevel)
 	if err != nil {
 		slog.Error("failed to create sidecar service", slog.Any("error", err))
 		os.Exit(exitCodeError)
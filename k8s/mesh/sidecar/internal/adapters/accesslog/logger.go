@@ -0,0 +1,169 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	DefaultTemplate = `[{{.StartTime}}] {{.Type}} {{.Direction}} {{.Listener}} ` +
+		`"{{.Method}} {{.Path}} {{.Protocol}}" {{.ResponseCode}} {{.ErrorKind}} ` +
+		`{{.BytesReceived}} {{.BytesSent}} {{.DurationMillis}}ms {{.RetryAttempts}} ` +
+		`{{.Service}} {{.Upstream}} {{.OriginalDst}} {{.PeerIdentity}}`
+)
+
+type Entry struct {
+	StartTime      string `json:"start_time"`
+	Type           string `json:"type"`
+	Listener       string `json:"listener"`
+	Direction      string `json:"direction"`
+	OriginalDst    string `json:"original_dst"`
+	Upstream       string `json:"upstream"`
+	Service        string `json:"service"`
+	PeerIdentity   string `json:"peer_identity"`
+	Protocol       string `json:"protocol,omitempty"`
+	Method         string `json:"method,omitempty"`
+	Path           string `json:"path,omitempty"`
+	ResponseCode   string `json:"response_code,omitempty"`
+	GRPCStatus     string `json:"grpc_status,omitempty"`
+	BytesReceived  int    `json:"bytes_received"`
+	BytesSent      int    `json:"bytes_sent"`
+	DurationMillis int64  `json:"duration_ms"`
+	ErrorKind      string `json:"error_kind,omitempty"`
+	RetryAttempts  int    `json:"retry_attempts"`
+}
+
+func NewEntry(start time.Time) Entry {
+	return Entry{
+		StartTime:      start.UTC().Format(time.RFC3339Nano),
+		DurationMillis: time.Since(start).Milliseconds(),
+	}
+}
+
+type Options struct {
+	Format       string
+	Template     string
+	Path         string
+	MaxSizeMB    int
+	MaxBackups   int
+	SamplingRate float64
+	Services     []string
+}
+
+type Logger struct {
+	template     *template.Template
+	samplingRate float64
+	services     []string
+
+	mu     sync.Mutex
+	output io.WriteCloser
+}
+
+func NewLogger(options Options) (*Logger, error) {
+	logger := &Logger{
+		samplingRate: options.SamplingRate,
+		services:     options.Services,
+	}
+
+	switch options.Format {
+	case FormatJSON:
+	case FormatText:
+		text := options.Template
+		if text == "" {
+			text = DefaultTemplate
+		}
+
+		parsed, err := template.New("access_log").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parse access log template: %w", err)
+		}
+		logger.template = parsed
+	default:
+		return nil, fmt.Errorf("unsupported access log format %q", options.Format)
+	}
+
+	switch options.Path {
+	case "", "/dev/stdout":
+		logger.output = nopCloser{Writer: os.Stdout}
+	case "/dev/stderr":
+		logger.output = nopCloser{Writer: os.Stderr}
+	default:
+		output, err := newRotatingFile(options.Path, int64(options.MaxSizeMB)<<20, options.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		logger.output = output
+	}
+
+	return logger, nil
+}
+
+func (l *Logger) Enabled(service string) bool {
+	if len(l.services) == 0 {
+		return true
+	}
+
+	name, _, _ := strings.Cut(service, ".")
+	return slices.Contains(l.services, service) || slices.Contains(l.services, name)
+}
+
+func (l *Logger) Log(entry Entry) {
+	// Failures are always kept: they are what an access log is read for.
+	if entry.ErrorKind == "" && l.samplingRate < 1 && rand.Float64() >= l.samplingRate {
+		return
+	}
+
+	line, err := l.format(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.output.Write(line)
+}
+
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.output.Close()
+}
+
+func (l *Logger) format(entry Entry) ([]byte, error) {
+	if l.template == nil {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		return append(line, '\n'), nil
+	}
+
+	var buffer bytes.Buffer
+	if err := l.template.Execute(&buffer, entry); err != nil {
+		return nil, err
+	}
+	buffer.WriteByte('\n')
+
+	return buffer.Bytes(), nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
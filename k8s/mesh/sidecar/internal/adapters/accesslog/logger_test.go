@@ -0,0 +1,101 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoggerWritesJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger, err := NewLogger(Options{Format: FormatJSON, Path: path, MaxSizeMB: 1, SamplingRate: 1})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+
+	logger.Log(Entry{Type: "http", Service: "reviews.bookinfo.svc.cluster.local", ResponseCode: "503", ErrorKind: "dial", RetryAttempts: 2})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("unmarshal %q: %v", data, err)
+	}
+	if entry.ResponseCode != "503" || entry.ErrorKind != "dial" || entry.RetryAttempts != 2 {
+		t.Fatalf("entry = %+v, want response code 503, error kind dial and 2 retries", entry)
+	}
+}
+
+func TestLoggerFormatsTemplate(t *testing.T) {
+	logger, err := NewLogger(Options{Format: FormatText, Template: "{{.Method}} {{.Path}} {{.ResponseCode}}", SamplingRate: 1})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+
+	line, err := logger.format(Entry{Method: "GET", Path: "/reviews/1", ResponseCode: "200"})
+	if err != nil {
+		t.Fatalf("format() error = %v", err)
+	}
+	if string(line) != "GET /reviews/1 200\n" {
+		t.Fatalf("format() = %q, want %q", line, "GET /reviews/1 200\n")
+	}
+
+	if _, err := NewLogger(Options{Format: FormatText, Template: "{{.Method"}); err == nil {
+		t.Fatal("NewLogger() with a broken template error = nil, want error")
+	}
+}
+
+func TestLoggerFiltersServices(t *testing.T) {
+	logger := &Logger{services: []string{"reviews", "external"}}
+
+	tests := map[string]bool{
+		"reviews.bookinfo.svc.cluster.local": true,
+		"external":                           true,
+		"ratings.bookinfo.svc.cluster.local": false,
+		"local-app":                          false,
+	}
+	for service, want := range tests {
+		if got := logger.Enabled(service); got != want {
+			t.Fatalf("Enabled(%q) = %t, want %t", service, got, want)
+		}
+	}
+}
+
+func TestRotatingFileKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	output, err := newRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("newRotatingFile() error = %v", err)
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := output.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := output.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Fatalf("%s = %q, want %q", file, data, content)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("%s.3 exists, want at most 2 backups", path)
+	}
+}
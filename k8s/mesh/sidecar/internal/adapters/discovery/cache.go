@@ -1,6 +1,10 @@
 package discovery
 
 import (
+	"net"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
@@ -15,18 +19,36 @@ type CachedService struct {
 	ClusterKey   string
 	ServiceLabel string
 	Endpoints    []domain.Endpoint
+	LoadBalancer domain.LoadBalancerPolicy
+	HealthCheck  domain.HealthCheckPolicy
+	Protocol     domain.Protocol
 }
 
 type ServiceCache struct {
-	mu       sync.RWMutex
-	byKey    map[string][]domain.Endpoint
-	observer EndpointsObserver
+	mu            sync.RWMutex
+	byKey         map[string][]domain.Endpoint
+	loadBalancers map[string]domain.LoadBalancerPolicy
+	healthChecks  map[string]domain.HealthCheckPolicy
+	protocols     map[string]domain.Protocol
+	// endpointProtocols is keyed by endpoint address, so inbound traffic can
+	// learn the protocol a Service declares for a local port.
+	endpointProtocols map[string]domain.Protocol
+	podWorkloads      map[string]domain.Workload
+	ipWorkloads       map[string]domain.Workload
+	observer          EndpointsObserver
+	replaceListeners  []func(map[string][]domain.Endpoint)
 }
 
 func NewServiceCache(observer EndpointsObserver) *ServiceCache {
 	return &ServiceCache{
-		byKey:    make(map[string][]domain.Endpoint),
-		observer: observer,
+		byKey:             make(map[string][]domain.Endpoint),
+		loadBalancers:     make(map[string]domain.LoadBalancerPolicy),
+		healthChecks:      make(map[string]domain.HealthCheckPolicy),
+		protocols:         make(map[string]domain.Protocol),
+		endpointProtocols: make(map[string]domain.Protocol),
+		podWorkloads:      make(map[string]domain.Workload),
+		ipWorkloads:       make(map[string]domain.Workload),
+		observer:          observer,
 	}
 }
 
@@ -44,8 +66,63 @@ func (c *ServiceCache) GetEndpoints(key string) []domain.Endpoint {
 	return cloned
 }
 
+func (c *ServiceCache) GetLoadBalancer(key string) domain.LoadBalancerPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.loadBalancers[key]
+}
+
+func (c *ServiceCache) GetProtocol(key string) domain.Protocol {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.protocols[key]
+}
+
+func (c *ServiceCache) GetEndpointProtocol(addr string) domain.Protocol {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.endpointProtocols[addr]
+}
+
+func (c *ServiceCache) WorkloadByPod(podName string) (domain.Workload, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	workload, ok := c.podWorkloads[podName]
+	return workload, ok
+}
+
+func (c *ServiceCache) WorkloadByIP(ip string) (domain.Workload, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	workload, ok := c.ipWorkloads[ip]
+	return workload, ok
+}
+
+func (c *ServiceCache) HealthCheckTargets() []domain.HealthCheckTarget {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var targets []domain.HealthCheckTarget
+	for serviceKey, policy := range c.healthChecks {
+		for _, endpoint := range c.byKey[serviceKey] {
+			targets = append(targets, domain.HealthCheckTarget{Endpoint: endpoint, Policy: policy})
+		}
+	}
+
+	return targets
+}
+
 func (c *ServiceCache) Replace(services []CachedService) {
 	next := make(map[string][]domain.Endpoint)
+	nextLoadBalancers := make(map[string]domain.LoadBalancerPolicy)
+	nextHealthChecks := make(map[string]domain.HealthCheckPolicy, len(services))
+	nextProtocols := make(map[string]domain.Protocol)
+	nextEndpointProtocols := make(map[string]domain.Protocol)
 
 	for _, service := range services {
 		cloned := make([]domain.Endpoint, len(service.Endpoints))
@@ -56,6 +133,26 @@ func (c *ServiceCache) Replace(services []CachedService) {
 			next[service.ClusterKey] = cloned
 		}
 
+		if service.LoadBalancer.Algorithm != "" {
+			nextLoadBalancers[service.ServiceKey] = service.LoadBalancer
+			if service.ClusterKey != "" {
+				nextLoadBalancers[service.ClusterKey] = service.LoadBalancer
+			}
+		}
+
+		if service.Protocol != "" {
+			nextProtocols[service.ServiceKey] = service.Protocol
+			if service.ClusterKey != "" {
+				nextProtocols[service.ClusterKey] = service.Protocol
+			}
+			for _, endpoint := range cloned {
+				nextEndpointProtocols[net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))] = service.Protocol
+			}
+		}
+
+		// Keyed by service name only so that the cluster IP alias is not checked twice.
+		nextHealthChecks[service.ServiceKey] = service.HealthCheck
+
 		if c.observer != nil {
 			c.observer.SetEndpointsReady(service.ServiceLabel, len(cloned))
 		}
@@ -63,5 +160,59 @@ func (c *ServiceCache) Replace(services []CachedService) {
 
 	c.mu.Lock()
 	c.byKey = next
+	c.loadBalancers = nextLoadBalancers
+	c.healthChecks = nextHealthChecks
+	c.protocols = nextProtocols
+	c.endpointProtocols = nextEndpointProtocols
+	listeners := c.replaceListeners
 	c.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(next)
+	}
+}
+
+// OnReplace registers listener to be called with the endpoints of every
+// service key after each Replace. The listener must not modify the map.
+func (c *ServiceCache) OnReplace(listener func(map[string][]domain.Endpoint)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.replaceListeners = append(c.replaceListeners, listener)
+}
+
+func (c *ServiceCache) ReplaceWorkloads(byPod map[string]domain.Workload, byIP map[string]domain.Workload) {
+	c.mu.Lock()
+	c.podWorkloads = byPod
+	c.ipWorkloads = byIP
+	c.mu.Unlock()
+}
+
+type ServiceSnapshot struct {
+	Key          string
+	Endpoints    []domain.Endpoint
+	LoadBalancer domain.LoadBalancerPolicy
+	HealthCheck  domain.HealthCheckPolicy
+	Protocol     domain.Protocol
+}
+
+func (c *ServiceCache) Snapshot() []ServiceSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	snapshots := make([]ServiceSnapshot, 0, len(c.byKey))
+	for key, endpoints := range c.byKey {
+		snapshots = append(snapshots, ServiceSnapshot{
+			Key:          key,
+			Endpoints:    slices.Clone(endpoints),
+			LoadBalancer: c.loadBalancers[key],
+			HealthCheck:  c.healthChecks[key],
+			Protocol:     c.protocols[key],
+		})
+	}
+	slices.SortFunc(snapshots, func(a, b ServiceSnapshot) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return snapshots
 }
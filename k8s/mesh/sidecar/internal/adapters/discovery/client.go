@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -53,3 +54,17 @@ func loadRESTConfig(kubeConfigPath string) (*rest.Config, error) {
 
 	return nil, fmt.Errorf("kubernetes configuration not found")
 }
+
+func NewDynamicClient(kubeConfigPath string) (dynamic.Interface, error) {
+	config, err := loadRESTConfig(kubeConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("create kubernetes dynamic client: %w", err)
+	}
+
+	return client, nil
+}
@@ -3,10 +3,13 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -16,7 +19,18 @@ import (
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
 
-const relistInterval = 5 * time.Second
+const (
+	relistInterval         = 5 * time.Second
+	annotationInjected     = "sidecar.mesh.io/injected"
+	annotationLoadBalancer = "sidecar.mesh.io/load-balancer"
+	annotationHashKey      = "sidecar.mesh.io/hash-key"
+
+	annotationHealthCheck            = "sidecar.mesh.io/health-check"
+	annotationHealthCheckPath        = "sidecar.mesh.io/health-check-path"
+	annotationHealthCheckPort        = "sidecar.mesh.io/health-check-port"
+	annotationHealthCheckInterval    = "sidecar.mesh.io/health-check-interval"
+	annotationHealthCheckGRPCService = "sidecar.mesh.io/health-check-grpc-service"
+)
 
 type Controller struct {
 	clientset kubernetes.Interface
@@ -27,6 +41,16 @@ type Controller struct {
 type serviceMeta struct {
 	clusterKey   string
 	serviceLabel string
+	loadBalancer domain.LoadBalancerPolicy
+	healthCheck  domain.HealthCheckPolicy
+	protocol     domain.Protocol
+}
+
+type podIndex struct {
+	meshed        map[string]bool
+	identities    map[string]string
+	workloads     map[string]domain.Workload
+	workloadsByIP map[string]domain.Workload
 }
 
 func NewController(clientset kubernetes.Interface, namespace string, cache *ServiceCache) *Controller {
@@ -132,6 +156,24 @@ func (c *Controller) relist(ctx context.Context) error {
 			continue
 		}
 
+		loadBalancer, err := parseLoadBalancerPolicy(service.Annotations)
+		if err != nil {
+			slog.Warn(
+				"ignoring invalid service load balancer annotations",
+				slog.String("service", service.Name),
+				slog.Any("error", err),
+			)
+		}
+
+		healthCheck, err := parseHealthCheckPolicy(service.Annotations)
+		if err != nil {
+			slog.Warn(
+				"ignoring invalid service health check annotations",
+				slog.String("service", service.Name),
+				slog.Any("error", err),
+			)
+		}
+
 		for _, servicePort := range service.Spec.Ports {
 			serviceKey := buildServiceKey(service.Name, int(servicePort.Port))
 			clusterKey := net.JoinHostPort(service.Spec.ClusterIP, strconv.Itoa(int(servicePort.Port)))
@@ -139,6 +181,9 @@ func (c *Controller) relist(ctx context.Context) error {
 			serviceMap[serviceKey] = serviceMeta{
 				clusterKey:   clusterKey,
 				serviceLabel: buildServiceFQDN(service.Name, service.Namespace),
+				loadBalancer: loadBalancer,
+				healthCheck:  healthCheck,
+				protocol:     parseServicePortProtocol(servicePort),
 			}
 		}
 	}
@@ -148,6 +193,11 @@ func (c *Controller) relist(ctx context.Context) error {
 		return domain.Wrap(domain.ErrorKindDiscovery, fmt.Errorf("list endpointslices: %w", err))
 	}
 
+	pods, err := c.listPods(ctx)
+	if err != nil {
+		return err
+	}
+
 	aggregated := make(map[string][]domain.Endpoint)
 	for _, slice := range slices.Items {
 		serviceName, ok := slice.Labels[discoveryv1.LabelServiceName]
@@ -174,11 +224,40 @@ func (c *Controller) relist(ctx context.Context) error {
 					continue
 				}
 
+				meshed := endpoint.TargetRef != nil &&
+					endpoint.TargetRef.Kind == "Pod" &&
+					pods.meshed[endpoint.TargetRef.Name]
+
+				var identity string
+				if meshed {
+					identity = pods.identities[endpoint.TargetRef.Name]
+				}
+
+				var zone, nodeName string
+				if endpoint.Zone != nil {
+					zone = *endpoint.Zone
+				}
+				if endpoint.NodeName != nil {
+					nodeName = *endpoint.NodeName
+				}
+
+				var forZones []string
+				if endpoint.Hints != nil {
+					for _, hint := range endpoint.Hints.ForZones {
+						forZones = append(forZones, hint.Name)
+					}
+				}
+
 				for _, address := range endpoint.Addresses {
 					aggregated[serviceKey] = append(aggregated[serviceKey], domain.Endpoint{
 						IP:          address,
 						Port:        int(*port.Port),
 						ServiceName: buildServiceFQDN(serviceName, c.namespace),
+						Meshed:      meshed,
+						Identity:    identity,
+						Zone:        zone,
+						NodeName:    nodeName,
+						ForZones:    forZones,
 					})
 				}
 			}
@@ -192,13 +271,172 @@ func (c *Controller) relist(ctx context.Context) error {
 			ClusterKey:   meta.clusterKey,
 			ServiceLabel: meta.serviceLabel,
 			Endpoints:    dedupeEndpoints(aggregated[serviceKey]),
+			LoadBalancer: meta.loadBalancer,
+			HealthCheck:  meta.healthCheck,
+			Protocol:     meta.protocol,
 		})
 	}
 
 	c.cache.Replace(cacheEntries)
+	c.cache.ReplaceWorkloads(pods.workloads, pods.workloadsByIP)
 	return nil
 }
 
+func (c *Controller) NodeZone(ctx context.Context, nodeName string) (string, error) {
+	node, err := c.clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return "", domain.Wrap(domain.ErrorKindDiscovery, fmt.Errorf("get node %s: %w", nodeName, err))
+	}
+
+	return node.Labels[corev1.LabelTopologyZone], nil
+}
+
+func (c *Controller) listPods(ctx context.Context) (podIndex, error) {
+	pods, err := c.clientset.CoreV1().Pods(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return podIndex{}, domain.Wrap(domain.ErrorKindDiscovery, fmt.Errorf("list pods: %w", err))
+	}
+
+	index := podIndex{
+		meshed:        make(map[string]bool, len(pods.Items)),
+		identities:    make(map[string]string, len(pods.Items)),
+		workloads:     make(map[string]domain.Workload, len(pods.Items)),
+		workloadsByIP: make(map[string]domain.Workload, len(pods.Items)),
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if strings.EqualFold(pod.Annotations[annotationInjected], "true") {
+			index.meshed[pod.Name] = true
+		}
+
+		serviceAccount := pod.Spec.ServiceAccountName
+		if serviceAccount == "" {
+			serviceAccount = "default"
+		}
+		index.identities[pod.Name] = "/ns/" + pod.Namespace + "/sa/" + serviceAccount
+
+		workload := domain.Workload{Name: workloadName(pod), Namespace: pod.Namespace}
+		index.workloads[pod.Name] = workload
+
+		// Finished pods and host network pods do not own their IP, another pod may use it.
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			index.workloadsByIP[podIP.IP] = workload
+		}
+		if pod.Status.PodIP != "" {
+			index.workloadsByIP[pod.Status.PodIP] = workload
+		}
+	}
+
+	return index, nil
+}
+
+func workloadName(pod *corev1.Pod) string {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return pod.Name
+	}
+
+	if owner.Kind == "ReplicaSet" {
+		if hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; hash != "" {
+			return strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	}
+
+	return owner.Name
+}
+
+func parseLoadBalancerPolicy(annotations map[string]string) (domain.LoadBalancerPolicy, error) {
+	algorithm := strings.TrimSpace(annotations[annotationLoadBalancer])
+	switch algorithm {
+	case "":
+		return domain.LoadBalancerPolicy{}, nil
+	case "none", "roundRobin", "random", "leastRequest", "leastConnection", domain.LoadBalancerRingHash:
+	default:
+		return domain.LoadBalancerPolicy{}, fmt.Errorf("unsupported load balancer algorithm %q", algorithm)
+	}
+
+	policy := domain.LoadBalancerPolicy{Algorithm: algorithm}
+	if algorithm != domain.LoadBalancerRingHash {
+		return policy, nil
+	}
+
+	hash, err := domain.ParseHashKey(annotations[annotationHashKey])
+	if err != nil {
+		return domain.LoadBalancerPolicy{}, err
+	}
+	policy.Hash = hash
+
+	return policy, nil
+}
+
+func parseHealthCheckPolicy(annotations map[string]string) (domain.HealthCheckPolicy, error) {
+	var policy domain.HealthCheckPolicy
+
+	switch checkType := domain.HealthCheckType(strings.TrimSpace(annotations[annotationHealthCheck])); checkType {
+	case "", domain.HealthCheckNone, domain.HealthCheckTCP, domain.HealthCheckHTTP, domain.HealthCheckGRPC:
+		policy.Type = checkType
+	default:
+		return domain.HealthCheckPolicy{}, fmt.Errorf("unsupported health check type %q", checkType)
+	}
+
+	policy.Path = strings.TrimSpace(annotations[annotationHealthCheckPath])
+	if policy.Path != "" && !strings.HasPrefix(policy.Path, "/") {
+		return domain.HealthCheckPolicy{}, fmt.Errorf("health check path %q must start with /", policy.Path)
+	}
+
+	policy.GRPCService = strings.TrimSpace(annotations[annotationHealthCheckGRPCService])
+
+	if rawPort := strings.TrimSpace(annotations[annotationHealthCheckPort]); rawPort != "" {
+		port, err := strconv.Atoi(rawPort)
+		if err != nil || port <= 0 || port > 65535 {
+			return domain.HealthCheckPolicy{}, fmt.Errorf("invalid health check port %q", rawPort)
+		}
+		policy.Port = port
+	}
+
+	if rawInterval := strings.TrimSpace(annotations[annotationHealthCheckInterval]); rawInterval != "" {
+		interval, err := time.ParseDuration(rawInterval)
+		if err != nil || interval <= 0 {
+			return domain.HealthCheckPolicy{}, fmt.Errorf("invalid health check interval %q", rawInterval)
+		}
+		policy.Interval = interval
+	}
+
+	return policy, nil
+}
+
+func parseServicePortProtocol(port corev1.ServicePort) domain.Protocol {
+	if port.AppProtocol != nil {
+		if protocol, ok := parseProtocolName(*port.AppProtocol); ok {
+			return protocol
+		}
+	}
+
+	// Istio-style port names: <protocol>[-<suffix>].
+	prefix, _, _ := strings.Cut(port.Name, "-")
+	protocol, _ := parseProtocolName(prefix)
+	return protocol
+}
+
+func parseProtocolName(name string) (domain.Protocol, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "http":
+		return domain.ProtocolHTTP, true
+	case "http2", "grpc", "h2c", "kubernetes.io/h2c":
+		return domain.ProtocolHTTP2, true
+	case "tcp", "kubernetes.io/ws":
+		// The forwarder does not handle Upgrade, so WebSocket stays opaque.
+		return domain.ProtocolTCP, true
+	case "tls", "https", "kubernetes.io/wss":
+		return domain.ProtocolTLS, true
+	default:
+		return "", false
+	}
+}
+
 func buildServiceKey(serviceName string, port int) string {
 	return serviceName + ":" + strconv.Itoa(port)
 }
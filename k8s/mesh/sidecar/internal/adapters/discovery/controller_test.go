@@ -0,0 +1,156 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func TestRelistMarksEndpointsOfInjectedPodsAsMeshed(t *testing.T) {
+	port := int32(9080)
+	clientset := fake.NewClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "bookinfo"},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: "10.96.0.10",
+				Ports:     []corev1.ServicePort{{Port: port}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "reviews-v1",
+				Namespace:   "bookinfo",
+				Annotations: map[string]string{annotationInjected: "true"},
+			},
+			Spec: corev1.PodSpec{ServiceAccountName: "bookinfo-reviews"},
+		},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        "reviews-v2",
+			Namespace:   "bookinfo",
+			Annotations: map[string]string{"sidecar.mesh.io/inject": "false"},
+		}},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "reviews-abc",
+				Namespace: "bookinfo",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "reviews"},
+			},
+			Ports: []discoveryv1.EndpointPort{{Port: &port}},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"10.0.0.1"}, TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "reviews-v1"}},
+				{Addresses: []string{"10.0.0.2"}, TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "reviews-v2"}},
+				{Addresses: []string{"10.0.0.3"}},
+			},
+		},
+	)
+
+	cache := NewServiceCache(nil)
+	if err := NewController(clientset, "bookinfo", cache).InitialSync(context.Background()); err != nil {
+		t.Fatalf("InitialSync() error = %v", err)
+	}
+
+	meshed := make(map[string]bool)
+	for _, endpoint := range cache.GetEndpoints("10.96.0.10:9080") {
+		meshed[endpoint.IP] = endpoint.Meshed
+		if endpoint.IP == "10.0.0.1" && endpoint.Identity != "/ns/bookinfo/sa/bookinfo-reviews" {
+			t.Fatalf("endpoint 10.0.0.1 identity = %q, want its service account", endpoint.Identity)
+		}
+	}
+
+	want := map[string]bool{"10.0.0.1": true, "10.0.0.2": false, "10.0.0.3": false}
+	if len(meshed) != len(want) {
+		t.Fatalf("endpoints = %v, want %v", meshed, want)
+	}
+	for ip, wantMeshed := range want {
+		if meshed[ip] != wantMeshed {
+			t.Fatalf("endpoint %s meshed = %t, want %t", ip, meshed[ip], wantMeshed)
+		}
+	}
+}
+
+func TestParseServicePortProtocol(t *testing.T) {
+	appProtocol := func(value string) *string { return &value }
+
+	tests := []struct {
+		name string
+		port corev1.ServicePort
+		want domain.Protocol
+	}{
+		{name: "app protocol", port: corev1.ServicePort{Name: "web", AppProtocol: appProtocol("kubernetes.io/h2c")}, want: domain.ProtocolHTTP2},
+		{name: "app protocol wins over name", port: corev1.ServicePort{Name: "http-web", AppProtocol: appProtocol("tcp")}, want: domain.ProtocolTCP},
+		{name: "unknown app protocol falls back to name", port: corev1.ServicePort{Name: "grpc-api", AppProtocol: appProtocol("example.com/custom")}, want: domain.ProtocolHTTP2},
+		{name: "http prefix", port: corev1.ServicePort{Name: "http-web"}, want: domain.ProtocolHTTP},
+		{name: "bare name", port: corev1.ServicePort{Name: "http"}, want: domain.ProtocolHTTP},
+		{name: "tls prefix", port: corev1.ServicePort{Name: "tls-db"}, want: domain.ProtocolTLS},
+		{name: "tcp prefix", port: corev1.ServicePort{Name: "tcp-mysql"}, want: domain.ProtocolTCP},
+		{name: "websocket stays opaque", port: corev1.ServicePort{Name: "web", AppProtocol: appProtocol("kubernetes.io/ws")}, want: domain.ProtocolTCP},
+		{name: "undeclared", port: corev1.ServicePort{Name: "mysql"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseServicePortProtocol(tt.port); got != tt.want {
+				t.Fatalf("parseServicePortProtocol() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRelistIndexesPodWorkloads(t *testing.T) {
+	controller := true
+	clientset := fake.NewClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "reviews-v1-7d9c8b6f5-x2k4p",
+				Namespace: "bookinfo",
+				Labels:    map[string]string{"pod-template-hash": "7d9c8b6f5"},
+				OwnerReferences: []metav1.OwnerReference{{
+					Kind:       "ReplicaSet",
+					Name:       "reviews-v1-7d9c8b6f5",
+					Controller: &controller,
+				}},
+			},
+			Status: corev1.PodStatus{PodIP: "10.0.0.1", PodIPs: []corev1.PodIP{{IP: "10.0.0.1"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "ratings-0",
+				Namespace: "bookinfo",
+				OwnerReferences: []metav1.OwnerReference{{
+					Kind:       "StatefulSet",
+					Name:       "ratings",
+					Controller: &controller,
+				}},
+			},
+			Status: corev1.PodStatus{PodIP: "10.0.0.2"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "bookinfo"},
+			Status:     corev1.PodStatus{PodIP: "10.0.0.3", Phase: corev1.PodSucceeded},
+		},
+	)
+
+	cache := NewServiceCache(nil)
+	if err := NewController(clientset, "bookinfo", cache).InitialSync(context.Background()); err != nil {
+		t.Fatalf("InitialSync() error = %v", err)
+	}
+
+	if got, _ := cache.WorkloadByIP("10.0.0.1"); got != (domain.Workload{Name: "reviews-v1", Namespace: "bookinfo"}) {
+		t.Fatalf("WorkloadByIP(10.0.0.1) = %+v, want reviews-v1", got)
+	}
+	if got, _ := cache.WorkloadByIP("10.0.0.2"); got.Name != "ratings" {
+		t.Fatalf("WorkloadByIP(10.0.0.2) = %+v, want ratings", got)
+	}
+	if _, ok := cache.WorkloadByIP("10.0.0.3"); ok {
+		t.Fatal("WorkloadByIP(10.0.0.3) found a finished pod")
+	}
+	if got, _ := cache.WorkloadByPod("debug"); got.Name != "debug" {
+		t.Fatalf("WorkloadByPod(debug) = %+v, want debug", got)
+	}
+}
@@ -0,0 +1,120 @@
+package meshconfig
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+const retryInterval = 30 * time.Second
+
+// ApplyFunc receives the data of every new ConfigMap version.
+type ApplyFunc func(version string, data map[string]string)
+
+// Watcher follows a single ConfigMap and hands its data to apply. Deleting
+// the ConfigMap does not reset anything: the last applied data stays in use.
+type Watcher struct {
+	clientset kubernetes.Interface
+	namespace string
+	name      string
+	apply     ApplyFunc
+
+	version string
+}
+
+func NewWatcher(clientset kubernetes.Interface, namespace string, name string, apply ApplyFunc) *Watcher {
+	return &Watcher{
+		clientset: clientset,
+		namespace: namespace,
+		name:      name,
+		apply:     apply,
+	}
+}
+
+func (w *Watcher) Sync(ctx context.Context) error {
+	configMap, err := w.clientset.CoreV1().ConfigMaps(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		return fmt.Errorf("get configmap %s/%s: %w", w.namespace, w.name, err)
+	}
+
+	w.observe(configMap)
+	return nil
+}
+
+func (w *Watcher) Run(ctx context.Context) error {
+	for {
+		if err := w.watchLoop(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			slog.Debug("sidecar config watch interrupted", slog.String("configmap", w.namespace+"/"+w.name), slog.Any("error", err))
+		}
+
+		timer := time.NewTimer(retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (w *Watcher) watchLoop(ctx context.Context) error {
+	// A resync before every watch catches updates missed while disconnected.
+	if err := w.Sync(ctx); err != nil {
+		return err
+	}
+
+	watcher, err := w.clientset.CoreV1().ConfigMaps(w.namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", w.name).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("watch configmap %s/%s: %w", w.namespace, w.name, err)
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return fmt.Errorf("configmap watch channel closed")
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				if configMap, ok := event.Object.(*corev1.ConfigMap); ok {
+					w.observe(configMap)
+				}
+			case watch.Deleted:
+				slog.Warn("sidecar config deleted, keeping the last applied version", slog.String("configmap", w.namespace+"/"+w.name))
+			case watch.Error:
+				return fmt.Errorf("configmap watch stream returned error event")
+			}
+		}
+	}
+}
+
+func (w *Watcher) observe(configMap *corev1.ConfigMap) {
+	if configMap.ResourceVersion == w.version {
+		return
+	}
+
+	w.version = configMap.ResourceVersion
+	w.apply(configMap.ResourceVersion, configMap.Data)
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const (
+	metricRequestsTotal    = "mesh_requests_total"
+	metricRequestDuration  = "mesh_request_duration_seconds"
+	metricTCPConnections   = "mesh_tcp_connections_total"
+	metricTCPReceivedBytes = "mesh_tcp_received_bytes_total"
+	metricTCPSentBytes     = "mesh_tcp_sent_bytes_total"
+
+	allMetrics   = "*"
+	unknownLabel = "unknown"
+)
+
+var peerLabelNames = []string{
+	"source_workload",
+	"source_namespace",
+	"source_principal",
+	"destination_workload",
+	"destination_namespace",
+	"destination_principal",
+}
+
+type Peers struct {
+	SourceWorkload       string
+	SourceNamespace      string
+	SourcePrincipal      string
+	DestinationWorkload  string
+	DestinationNamespace string
+	DestinationPrincipal string
+}
+
+func (p Peers) values() []string {
+	values := []string{
+		p.SourceWorkload,
+		p.SourceNamespace,
+		p.SourcePrincipal,
+		p.DestinationWorkload,
+		p.DestinationNamespace,
+		p.DestinationPrincipal,
+	}
+	for i, value := range values {
+		if value == "" {
+			values[i] = unknownLabel
+		}
+	}
+
+	return values
+}
+
+type labelSet struct {
+	names []string
+	keep  []int
+}
+
+func newLabelSet(names []string, dropped []string) labelSet {
+	set := labelSet{}
+	for i, name := range names {
+		if slices.Contains(dropped, name) {
+			continue
+		}
+		set.names = append(set.names, name)
+		set.keep = append(set.keep, i)
+	}
+
+	return set
+}
+
+func (s labelSet) values(values ...string) []string {
+	kept := make([]string, len(s.keep))
+	for i, index := range s.keep {
+		kept[i] = values[index]
+	}
+
+	return kept
+}
+
+func workloadMetricLabels() map[string][]string {
+	return map[string][]string{
+		metricRequestsTotal:    append([]string{"service", "status_code", "direction", "mtls", "method", "route"}, peerLabelNames...),
+		metricRequestDuration:  append([]string{"service", "direction", "method", "route"}, peerLabelNames...),
+		metricTCPConnections:   append([]string{"service", "direction", "mtls"}, peerLabelNames...),
+		metricTCPReceivedBytes: append([]string{"service", "direction"}, peerLabelNames...),
+		metricTCPSentBytes:     append([]string{"service", "direction"}, peerLabelNames...),
+	}
+}
+
+func newMetricLabels(dropped map[string][]string) (map[string]labelSet, error) {
+	all := workloadMetricLabels()
+
+	metrics := make([]string, 0, len(dropped))
+	for metric := range dropped {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	for _, metric := range metrics {
+		if _, ok := all[metric]; !ok && metric != allMetrics {
+			return nil, fmt.Errorf("labels of metric %q cannot be dropped", metric)
+		}
+		if len(dropped[metric]) == 0 {
+			return nil, fmt.Errorf("no labels to drop for metric %q", metric)
+		}
+
+		for _, label := range dropped[metric] {
+			if !hasLabel(all, metric, label) {
+				return nil, fmt.Errorf("metric %q has no label %q", metric, label)
+			}
+		}
+	}
+
+	sets := make(map[string]labelSet, len(all))
+	for metric, names := range all {
+		sets[metric] = newLabelSet(names, append(slices.Clone(dropped[allMetrics]), dropped[metric]...))
+	}
+
+	return sets, nil
+}
+
+func hasLabel(all map[string][]string, metric string, label string) bool {
+	if metric != allMetrics {
+		return slices.Contains(all[metric], label)
+	}
+
+	for _, names := range all {
+		if slices.Contains(names, label) {
+			return true
+		}
+	}
+
+	return false
+}
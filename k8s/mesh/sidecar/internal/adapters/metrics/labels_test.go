@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRecorderDropsConfiguredLabels(t *testing.T) {
+	recorder, err := NewRecorderWithDroppedLabels(map[string][]string{
+		"*":                   {"source_principal", "destination_principal"},
+		"mesh_requests_total": {"route"},
+	})
+	if err != nil {
+		t.Fatalf("NewRecorderWithDroppedLabels() error = %v", err)
+	}
+
+	peers := Peers{SourceWorkload: "productpage-v1", SourceNamespace: "bookinfo", DestinationWorkload: "reviews-v1"}
+	recorder.ObserveRequest("reviews", "200", "outbound", true, "GET", "/reviews/1", peers, time.Millisecond)
+	recorder.ObserveRequest("reviews", "200", "outbound", true, "GET", "/reviews/2", peers, time.Millisecond)
+
+	families, err := recorder.registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != "mesh_requests_total" {
+			continue
+		}
+
+		if len(family.GetMetric()) != 1 {
+			t.Fatalf("mesh_requests_total has %d series, want 1", len(family.GetMetric()))
+		}
+
+		labels := make(map[string]string)
+		for _, pair := range family.GetMetric()[0].GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		for _, dropped := range []string{"route", "source_principal", "destination_principal"} {
+			if _, ok := labels[dropped]; ok {
+				t.Fatalf("label %q was not dropped: %v", dropped, labels)
+			}
+		}
+		if labels["source_workload"] != "productpage-v1" || labels["destination_namespace"] != "unknown" {
+			t.Fatalf("labels = %v, want source_workload=productpage-v1 and destination_namespace=unknown", labels)
+		}
+		return
+	}
+
+	t.Fatal("mesh_requests_total was not gathered")
+}
+
+func TestNewRecorderRejectsUnknownLabels(t *testing.T) {
+	tests := []map[string][]string{
+		{"mesh_request_errors_total": {"service"}},
+		{"mesh_tcp_sent_bytes_total": {"route"}},
+		{"*": {"pod"}},
+		{"mesh_requests_total": nil},
+	}
+
+	for _, dropped := range tests {
+		if _, err := NewRecorderWithDroppedLabels(dropped); err == nil {
+			t.Fatalf("NewRecorderWithDroppedLabels(%v) error = nil, want error", dropped)
+		}
+	}
+}
+
+func TestLabelSetKeepsOrder(t *testing.T) {
+	set := newLabelSet([]string{"a", "b", "c"}, []string{"b"})
+
+	if !slices.Equal(set.names, []string{"a", "c"}) {
+		t.Fatalf("names = %v, want [a c]", set.names)
+	}
+	if got := set.values("1", "2", "3"); !slices.Equal(got, []string{"1", "3"}) {
+		t.Fatalf("values() = %v, want [1 3]", got)
+	}
+}
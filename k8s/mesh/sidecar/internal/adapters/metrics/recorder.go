@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,15 +14,43 @@ import (
 type Recorder struct {
 	registry *prometheus.Registry
 
-	requestsTotal       *prometheus.CounterVec
-	requestDuration     *prometheus.HistogramVec
-	requestErrors       *prometheus.CounterVec
-	retryAttempts       *prometheus.CounterVec
-	circuitBreakerState *prometheus.GaugeVec
-	endpointsReady      *prometheus.GaugeVec
+	requestsTotal        *prometheus.CounterVec
+	requestDuration      *prometheus.HistogramVec
+	requestErrors        *prometheus.CounterVec
+	retryAttempts        *prometheus.CounterVec
+	retryBudgetExhausted *prometheus.CounterVec
+	circuitBreakerState  *prometheus.GaugeVec
+	endpointsReady       *prometheus.GaugeVec
+	certRotations        *prometheus.CounterVec
+	certRotationStatus   prometheus.Gauge
+	certExpiry           prometheus.GaugeFunc
+	authorizationDenied  *prometheus.CounterVec
+	outlierEjections     *prometheus.CounterVec
+	outlierEjected       *prometheus.GaugeVec
+	healthChecks         *prometheus.CounterVec
+	endpointsUnhealthy   *prometheus.GaugeVec
+	tcpConnections       *prometheus.CounterVec
+	tcpReceivedBytes     *prometheus.CounterVec
+	tcpSentBytes         *prometheus.CounterVec
+	configReloads        *prometheus.CounterVec
+	configVersion        *prometheus.GaugeVec
+
+	labels        map[string]labelSet
+	routes        *routeSet
+	certExpiresAt atomic.Int64
 }
 
 func NewRecorder() *Recorder {
+	recorder, _ := NewRecorderWithDroppedLabels(nil)
+	return recorder
+}
+
+func NewRecorderWithDroppedLabels(dropped map[string][]string) (*Recorder, error) {
+	labels, err := newMetricLabels(dropped)
+	if err != nil {
+		return nil, err
+	}
+
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(
 		collectors.NewGoCollector(),
@@ -30,20 +60,22 @@ func NewRecorder() *Recorder {
 
 	recorder := &Recorder{
 		registry: registry,
+		labels:   labels,
+		routes:   newRouteSet(),
 		requestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "mesh_requests_total",
-				Help: "Total proxied requests grouped by service, status code and direction.",
+				Help: "Total proxied HTTP requests grouped by service, status code, direction, mTLS, method, route and source and destination workloads.",
 			},
-			[]string{"service", "status_code", "direction"},
+			labels[metricRequestsTotal].names,
 		),
 		requestDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "mesh_request_duration_seconds",
-				Help:    "Request duration in seconds grouped by service and direction.",
+				Help:    "HTTP request duration in seconds grouped by service, direction, method, route and source and destination workloads.",
 				Buckets: prometheus.DefBuckets,
 			},
-			[]string{"service", "direction"},
+			labels[metricRequestDuration].names,
 		),
 		requestErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -59,6 +91,13 @@ func NewRecorder() *Recorder {
 			},
 			[]string{"service"},
 		),
+		retryBudgetExhausted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_retry_budget_exhausted_total",
+				Help: "Retries skipped because the retry budget of the service was exhausted.",
+			},
+			[]string{"service"},
+		),
 		circuitBreakerState: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "mesh_circuit_breaker_state",
@@ -73,27 +112,181 @@ func NewRecorder() *Recorder {
 			},
 			[]string{"service"},
 		),
+		certRotations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_certificate_rotations_total",
+				Help: "Total workload certificate rotation attempts grouped by result.",
+			},
+			[]string{"result"},
+		),
+		certRotationStatus: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "mesh_certificate_rotation_status",
+				Help: "Current workload certificate rotation status (0 healthy, 1 failing).",
+			},
+		),
+		authorizationDenied: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_authorization_denied_total",
+				Help: "Total inbound requests denied by authorization policies grouped by policy.",
+			},
+			[]string{"policy"},
+		),
+		outlierEjections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_outlier_ejections_total",
+				Help: "Total endpoint ejections by outlier detection grouped by service and reason.",
+			},
+			[]string{"service", "reason"},
+		),
+		outlierEjected: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mesh_outlier_ejected_endpoints",
+				Help: "Number of endpoints currently ejected by outlier detection grouped by service.",
+			},
+			[]string{"service"},
+		),
+		healthChecks: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_health_checks_total",
+				Help: "Total active health check probes grouped by service and result.",
+			},
+			[]string{"service", "result"},
+		),
+		endpointsUnhealthy: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mesh_endpoints_unhealthy",
+				Help: "Number of endpoints failing active health checks grouped by service.",
+			},
+			[]string{"service"},
+		),
+		tcpConnections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_tcp_connections_total",
+				Help: "Total closed opaque TCP connections grouped by service, direction, whether the hop used mTLS and source and destination workloads.",
+			},
+			labels[metricTCPConnections].names,
+		),
+		tcpReceivedBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_tcp_received_bytes_total",
+				Help: "Total bytes received from the client of opaque TCP connections grouped by service, direction and source and destination workloads.",
+			},
+			labels[metricTCPReceivedBytes].names,
+		),
+		tcpSentBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_tcp_sent_bytes_total",
+				Help: "Total bytes sent to the client of opaque TCP connections grouped by service, direction and source and destination workloads.",
+			},
+			labels[metricTCPSentBytes].names,
+		),
+		configReloads: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mesh_config_reloads_total",
+				Help: "Total sidecar config reloads from the mesh-sidecar-config ConfigMap grouped by result.",
+			},
+			[]string{"result"},
+		),
+		configVersion: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mesh_config_version",
+				Help: "Resource version of the applied mesh-sidecar-config ConfigMap; the series of the applied version is 1.",
+			},
+			[]string{"version"},
+		),
 	}
 
+	recorder.certExpiry = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "mesh_certificate_expiry_seconds",
+			Help: "Seconds until the current workload certificate expires.",
+		},
+		func() float64 {
+			expiresAt := recorder.certExpiresAt.Load()
+			if expiresAt == 0 {
+				return 0
+			}
+
+			return time.Until(time.Unix(expiresAt, 0)).Seconds()
+		},
+	)
+
 	registry.MustRegister(
 		recorder.requestsTotal,
 		recorder.requestDuration,
 		recorder.requestErrors,
 		recorder.retryAttempts,
+		recorder.retryBudgetExhausted,
 		recorder.circuitBreakerState,
 		recorder.endpointsReady,
+		recorder.certRotations,
+		recorder.certRotationStatus,
+		recorder.certExpiry,
+		recorder.authorizationDenied,
+		recorder.outlierEjections,
+		recorder.outlierEjected,
+		recorder.healthChecks,
+		recorder.endpointsUnhealthy,
+		recorder.tcpConnections,
+		recorder.tcpReceivedBytes,
+		recorder.tcpSentBytes,
+		recorder.configReloads,
+		recorder.configVersion,
 	)
 
-	return recorder
+	return recorder, nil
 }
 
 func (r *Recorder) Handler() http.Handler {
 	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
 }
 
-func (r *Recorder) ObserveRequest(service string, statusCode string, direction string, duration time.Duration) {
-	r.requestsTotal.WithLabelValues(normalizeService(service), statusCode, normalizeDirection(direction)).Inc()
-	r.requestDuration.WithLabelValues(normalizeService(service), normalizeDirection(direction)).Observe(duration.Seconds())
+func (r *Recorder) ObserveRequest(
+	service string,
+	statusCode string,
+	direction string,
+	mtls bool,
+	method string,
+	path string,
+	peers Peers,
+	duration time.Duration,
+) {
+	service = normalizeService(service)
+	direction = normalizeDirection(direction)
+	method = normalizeMethod(method)
+	route := r.routes.label(service, path)
+	peerValues := peers.values()
+
+	r.requestsTotal.WithLabelValues(r.labels[metricRequestsTotal].values(
+		append([]string{service, statusCode, direction, strconv.FormatBool(mtls), method, route}, peerValues...)...,
+	)...).Inc()
+	r.requestDuration.WithLabelValues(r.labels[metricRequestDuration].values(
+		append([]string{service, direction, method, route}, peerValues...)...,
+	)...).Observe(duration.Seconds())
+}
+
+func (r *Recorder) ObserveTCPConnection(
+	service string,
+	direction string,
+	mtls bool,
+	peers Peers,
+	received int64,
+	sent int64,
+) {
+	service = normalizeService(service)
+	direction = normalizeDirection(direction)
+	peerValues := peers.values()
+
+	r.tcpConnections.WithLabelValues(r.labels[metricTCPConnections].values(
+		append([]string{service, direction, strconv.FormatBool(mtls)}, peerValues...)...,
+	)...).Inc()
+	r.tcpReceivedBytes.WithLabelValues(r.labels[metricTCPReceivedBytes].values(
+		append([]string{service, direction}, peerValues...)...,
+	)...).Add(float64(received))
+	r.tcpSentBytes.WithLabelValues(r.labels[metricTCPSentBytes].values(
+		append([]string{service, direction}, peerValues...)...,
+	)...).Add(float64(sent))
 }
 
 func (r *Recorder) ObserveError(service string, errorType string) {
@@ -104,6 +297,10 @@ func (r *Recorder) IncRetry(service string) {
 	r.retryAttempts.WithLabelValues(normalizeService(service)).Inc()
 }
 
+func (r *Recorder) IncRetryBudgetExhausted(service string) {
+	r.retryBudgetExhausted.WithLabelValues(normalizeService(service)).Inc()
+}
+
 func (r *Recorder) SetCircuitBreakerState(service string, state int) {
 	r.circuitBreakerState.WithLabelValues(normalizeService(service)).Set(float64(state))
 }
@@ -112,6 +309,47 @@ func (r *Recorder) SetEndpointsReady(service string, ready int) {
 	r.endpointsReady.WithLabelValues(normalizeService(service)).Set(float64(ready))
 }
 
+func (r *Recorder) ObserveCertificateRotation(result string) {
+	r.certRotations.WithLabelValues(result).Inc()
+}
+
+func (r *Recorder) SetCertificateRotationStatus(status int) {
+	r.certRotationStatus.Set(float64(status))
+}
+
+func (r *Recorder) SetCertificateExpiry(expiresAt time.Time) {
+	r.certExpiresAt.Store(expiresAt.Unix())
+}
+
+func (r *Recorder) ObserveConfigReload(result string) {
+	r.configReloads.WithLabelValues(result).Inc()
+}
+
+func (r *Recorder) SetConfigVersion(version string) {
+	r.configVersion.Reset()
+	r.configVersion.WithLabelValues(version).Set(1)
+}
+
+func (r *Recorder) IncAuthorizationDenied(policy string) {
+	r.authorizationDenied.WithLabelValues(normalizePolicy(policy)).Inc()
+}
+
+func (r *Recorder) IncOutlierEjection(service string, reason string) {
+	r.outlierEjections.WithLabelValues(normalizeService(service), reason).Inc()
+}
+
+func (r *Recorder) SetOutlierEjectedEndpoints(service string, ejected int) {
+	r.outlierEjected.WithLabelValues(normalizeService(service)).Set(float64(ejected))
+}
+
+func (r *Recorder) ObserveHealthCheck(service string, result string) {
+	r.healthChecks.WithLabelValues(normalizeService(service), result).Inc()
+}
+
+func (r *Recorder) SetEndpointsUnhealthy(service string, unhealthy int) {
+	r.endpointsUnhealthy.WithLabelValues(normalizeService(service)).Set(float64(unhealthy))
+}
+
 func normalizeService(service string) string {
 	if service == "" {
 		return "external"
@@ -129,6 +367,16 @@ func normalizeDirection(direction string) string {
 	}
 }
 
+func normalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
 func normalizeErrorType(errorType string) string {
 	if errorType == "" {
 		return "unknown"
@@ -136,3 +384,11 @@ func normalizeErrorType(errorType string) string {
 
 	return errorType
 }
+
+func normalizePolicy(policy string) string {
+	if policy == "" {
+		return "default"
+	}
+
+	return policy
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	maxRoutesPerService = 100
+	maxRouteSegments    = 3
+	otherRoute          = "other"
+)
+
+type routeSet struct {
+	mu     sync.Mutex
+	routes map[string]map[string]struct{}
+}
+
+func newRouteSet() *routeSet {
+	return &routeSet{routes: make(map[string]map[string]struct{})}
+}
+
+func (s *routeSet) label(service string, path string) string {
+	route := normalizeRoute(path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	known, ok := s.routes[service]
+	if !ok {
+		known = make(map[string]struct{})
+		s.routes[service] = known
+	}
+
+	if _, ok := known[route]; ok {
+		return route
+	}
+
+	// New routes past the limit share one series, so a path scan cannot blow up the registry.
+	if len(known) >= maxRoutesPerService {
+		return otherRoute
+	}
+
+	known[route] = struct{}{}
+	return route
+}
+
+func normalizeRoute(path string) string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return "/"
+	}
+
+	var route strings.Builder
+	for i, segment := range strings.Split(trimmed, "/") {
+		if i == maxRouteSegments {
+			route.WriteString("/*")
+			break
+		}
+
+		route.WriteByte('/')
+		if isIdentifierSegment(segment) {
+			route.WriteString(":id")
+		} else {
+			route.WriteString(segment)
+		}
+	}
+
+	return route.String()
+}
+
+func isIdentifierSegment(segment string) bool {
+	if segment == "" {
+		return false
+	}
+
+	digits, hex := 0, true
+	for _, r := range segment {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r >= 'a' && r <= 'f', r >= 'A' && r <= 'F', r == '-':
+		default:
+			hex = false
+		}
+	}
+
+	// Numeric IDs, UUIDs and hashes.
+	return digits == len(segment) || (hex && digits > 0 && len(segment) >= 16)
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestNormalizeRoute(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "", want: "/"},
+		{path: "/", want: "/"},
+		{path: "/reviews/0", want: "/reviews/:id"},
+		{path: "/api/v1/products", want: "/api/v1/products"},
+		{path: "/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301/items", want: "/orders/:id/items"},
+		{path: "/a/b/c/d/e", want: "/a/b/c/*"},
+		{path: "/reviews.v1.Reviews/Get", want: "/reviews.v1.Reviews/Get"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeRoute(tt.path); got != tt.want {
+			t.Fatalf("normalizeRoute(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRouteSetLimitsRoutesPerService(t *testing.T) {
+	routes := newRouteSet()
+	for i := range maxRoutesPerService {
+		routes.label("reviews", "/page-"+strconv.Itoa(i))
+	}
+
+	if got := routes.label("reviews", "/one-more"); got != otherRoute {
+		t.Fatalf("label() past the limit = %q, want %q", got, otherRoute)
+	}
+	if got := routes.label("reviews", "/page-0"); got != "/page-0" {
+		t.Fatalf("label() of a known route = %q, want /page-0", got)
+	}
+	if got := routes.label("ratings", "/one-more"); got != "/one-more" {
+		t.Fatalf("label() of another service = %q, want /one-more", got)
+	}
+}
@@ -0,0 +1,180 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+type AuthorizationAction string
+
+const (
+	AuthorizationActionAllow AuthorizationAction = "ALLOW"
+	AuthorizationActionDeny  AuthorizationAction = "DENY"
+)
+
+type AuthorizationPolicy struct {
+	Name   string
+	Action AuthorizationAction
+	Rules  []AuthorizationRule
+}
+
+type AuthorizationRule struct {
+	Principals []string
+	Ports      []int
+	Methods    []string
+	Paths      []string
+}
+
+type AuthorizationRequest struct {
+	Principal string
+	Port      int
+	HTTP      bool
+	Method    string
+	Path      string
+}
+
+type AuthorizationDecision struct {
+	Allowed bool
+	Policy  string
+}
+
+type AuthorizationStore struct {
+	mu           sync.RWMutex
+	policies     []AuthorizationPolicy
+	requiresHTTP bool
+}
+
+// denyAllPolicy stands in for a policy that cannot be parsed: its single
+// empty rule matches every request.
+func denyAllPolicy(name string) AuthorizationPolicy {
+	return AuthorizationPolicy{
+		Name:   name,
+		Action: AuthorizationActionDeny,
+		Rules:  []AuthorizationRule{{}},
+	}
+}
+
+func NewAuthorizationStore() *AuthorizationStore {
+	return &AuthorizationStore{}
+}
+
+func (s *AuthorizationStore) Replace(policies []AuthorizationPolicy) {
+	cloned := make([]AuthorizationPolicy, len(policies))
+	copy(cloned, policies)
+
+	requiresHTTP := false
+	for _, policy := range cloned {
+		for _, rule := range policy.Rules {
+			if rule.hasHTTPConditions() {
+				requiresHTTP = true
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.policies = cloned
+	s.requiresHTTP = requiresHTTP
+	s.mu.Unlock()
+}
+
+func (s *AuthorizationStore) RequiresHTTP() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.requiresHTTP
+}
+
+func (s *AuthorizationStore) Evaluate(request AuthorizationRequest) AuthorizationDecision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, policy := range s.policies {
+		if policy.Action == AuthorizationActionDeny && policy.matches(request) {
+			return AuthorizationDecision{Allowed: false, Policy: policy.Name}
+		}
+	}
+
+	allowPolicies := 0
+	for _, policy := range s.policies {
+		if policy.Action != AuthorizationActionAllow {
+			continue
+		}
+
+		allowPolicies++
+		if policy.matches(request) {
+			return AuthorizationDecision{Allowed: true, Policy: policy.Name}
+		}
+	}
+
+	return AuthorizationDecision{Allowed: allowPolicies == 0}
+}
+
+func (p AuthorizationPolicy) matches(request AuthorizationRequest) bool {
+	for _, rule := range p.Rules {
+		if rule.matches(request, p.Action) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r AuthorizationRule) matches(request AuthorizationRequest, action AuthorizationAction) bool {
+	if len(r.Principals) > 0 && !matchAnyValue(r.Principals, request.Principal) {
+		return false
+	}
+
+	if len(r.Ports) > 0 && !slices.Contains(r.Ports, request.Port) {
+		return false
+	}
+
+	if !r.hasHTTPConditions() {
+		return true
+	}
+
+	// Opaque TCP traffic has no method or path: an ALLOW rule that needs them
+	// cannot match, while a DENY rule falls back to its remaining conditions.
+	if !request.HTTP {
+		return action == AuthorizationActionDeny
+	}
+
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(method string) bool {
+		return strings.EqualFold(method, request.Method)
+	}) {
+		return false
+	}
+
+	if len(r.Paths) > 0 && !matchAnyValue(r.Paths, request.Path) {
+		return false
+	}
+
+	return true
+}
+
+func (r AuthorizationRule) hasHTTPConditions() bool {
+	return len(r.Methods) > 0 || len(r.Paths) > 0
+}
+
+func matchAnyValue(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matchValue(pattern, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchValue(pattern string, value string) bool {
+	switch {
+	case pattern == "*":
+		return value != ""
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(value, strings.TrimPrefix(pattern, "*"))
+	default:
+		return pattern == value
+	}
+}
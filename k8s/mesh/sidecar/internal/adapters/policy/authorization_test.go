@@ -0,0 +1,91 @@
+package policy
+
+import "testing"
+
+func TestAuthorizationStoreEvaluate(t *testing.T) {
+	const productpage = "spiffe://cluster.local/ns/bookinfo/sa/bookinfo-productpage"
+
+	store := NewAuthorizationStore()
+	store.Replace([]AuthorizationPolicy{
+		{
+			Name:   "deny-admin",
+			Action: AuthorizationActionDeny,
+			Rules:  []AuthorizationRule{{Paths: []string{"/admin/*"}}},
+		},
+		{
+			Name:   "allow-productpage",
+			Action: AuthorizationActionAllow,
+			Rules: []AuthorizationRule{{
+				Principals: []string{"spiffe://cluster.local/ns/bookinfo/sa/*"},
+				Ports:      []int{9080},
+				Methods:    []string{"GET"},
+			}},
+		},
+	})
+
+	if !store.RequiresHTTP() {
+		t.Fatal("expected store with method and path rules to require http attributes")
+	}
+
+	tests := []struct {
+		name    string
+		request AuthorizationRequest
+		allowed bool
+		policy  string
+	}{
+		{
+			name:    "allowed by principal, port and method",
+			request: AuthorizationRequest{Principal: productpage, Port: 9080, HTTP: true, Method: "get", Path: "/reviews/1"},
+			allowed: true,
+			policy:  "allow-productpage",
+		},
+		{
+			name:    "deny rule wins over allow rule",
+			request: AuthorizationRequest{Principal: productpage, Port: 9080, HTTP: true, Method: "GET", Path: "/admin/users"},
+			policy:  "deny-admin",
+		},
+		{
+			name:    "no allow rule matches method",
+			request: AuthorizationRequest{Principal: productpage, Port: 9080, HTTP: true, Method: "POST", Path: "/reviews/1"},
+		},
+		{
+			name:    "plaintext peer has no principal",
+			request: AuthorizationRequest{Port: 9080, HTTP: true, Method: "GET", Path: "/reviews/1"},
+		},
+		{
+			name:    "opaque tcp does not match http allow rule",
+			request: AuthorizationRequest{Principal: productpage, Port: 9080},
+			policy:  "deny-admin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := store.Evaluate(tt.request)
+			if decision.Allowed != tt.allowed || decision.Policy != tt.policy {
+				t.Fatalf("Evaluate() = %+v, want allowed=%t policy=%q", decision, tt.allowed, tt.policy)
+			}
+		})
+	}
+}
+
+func TestAuthorizationStoreAllowsWithoutAllowPolicies(t *testing.T) {
+	store := NewAuthorizationStore()
+	if decision := store.Evaluate(AuthorizationRequest{Port: 9080}); !decision.Allowed {
+		t.Fatal("expected traffic to be allowed when no policies are defined")
+	}
+
+	store.Replace([]AuthorizationPolicy{{
+		Name:   "deny-legacy",
+		Action: AuthorizationActionDeny,
+		Rules:  []AuthorizationRule{{Principals: []string{"*/sa/legacy"}}},
+	}})
+
+	if decision := store.Evaluate(AuthorizationRequest{Principal: "spiffe://cluster.local/ns/default/sa/reviews", Port: 9080}); !decision.Allowed {
+		t.Fatal("expected traffic not matching deny policy to be allowed")
+	}
+
+	if decision := store.Evaluate(AuthorizationRequest{Principal: "spiffe://cluster.local/ns/default/sa/legacy", Port: 9080}); decision.Allowed {
+		t.Fatal("expected traffic matching deny policy to be denied")
+	}
+}
@@ -0,0 +1,372 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// reconnectInterval delays re-establishing interrupted watches. Policies are
+// re-listed on every watch event and on reconnect, never on a timer.
+const reconnectInterval = 5 * time.Second
+
+var (
+	authorizationPolicyResource = schema.GroupVersionResource{
+		Group:    "mesh.io",
+		Version:  "v1alpha1",
+		Resource: "authorizationpolicies",
+	}
+	peerAuthenticationResource = schema.GroupVersionResource{
+		Group:    "mesh.io",
+		Version:  "v1alpha1",
+		Resource: "peerauthentications",
+	}
+	trafficPolicyResource = schema.GroupVersionResource{
+		Group:    "mesh.io",
+		Version:  "v1alpha1",
+		Resource: "trafficpolicies",
+	}
+)
+
+type Controller struct {
+	clientset          kubernetes.Interface
+	dynamic            dynamic.Interface
+	namespace          string
+	podName            string
+	authorization      *AuthorizationStore
+	peerAuthentication *PeerAuthenticationStore
+	traffic            TrafficPolicySink
+
+	podLabels          labels.Set
+	trafficUnavailable bool
+}
+
+type workloadSelector struct {
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+}
+
+type authorizationPolicySpec struct {
+	Selector workloadSelector `json:"selector,omitempty"`
+	Action   string           `json:"action,omitempty"`
+	Rules    []struct {
+		Principals []string `json:"principals,omitempty"`
+		Ports      []int    `json:"ports,omitempty"`
+		Methods    []string `json:"methods,omitempty"`
+		Paths      []string `json:"paths,omitempty"`
+	} `json:"rules,omitempty"`
+}
+
+type peerAuthenticationSpec struct {
+	Selector workloadSelector `json:"selector,omitempty"`
+	MTLS     struct {
+		Mode string `json:"mode,omitempty"`
+	} `json:"mtls,omitempty"`
+}
+
+func NewController(
+	clientset kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	namespace string,
+	podName string,
+	authorization *AuthorizationStore,
+	peerAuthentication *PeerAuthenticationStore,
+	traffic TrafficPolicySink,
+) *Controller {
+	return &Controller{
+		clientset:          clientset,
+		dynamic:            dynamicClient,
+		namespace:          namespace,
+		podName:            podName,
+		authorization:      authorization,
+		peerAuthentication: peerAuthentication,
+		traffic:            traffic,
+	}
+}
+
+func (c *Controller) InitialSync(ctx context.Context) error {
+	pod, err := c.clientset.CoreV1().Pods(c.namespace).Get(ctx, c.podName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get pod %s/%s for policy selectors: %w", c.namespace, c.podName, err)
+	}
+	c.podLabels = labels.Set(pod.Labels)
+
+	return c.relist(ctx)
+}
+
+func (c *Controller) Run(ctx context.Context) error {
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err := c.watchLoop(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			slog.Debug("policy watch interrupted", slog.Any("error", err))
+
+			timer := time.NewTimer(reconnectInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+
+			if relistErr := c.relist(ctx); relistErr != nil {
+				return relistErr
+			}
+		}
+	}
+}
+
+func (c *Controller) watchLoop(ctx context.Context) error {
+	authorizationWatch, err := c.dynamic.Resource(authorizationPolicyResource).Namespace(c.namespace).Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("watch authorizationpolicies: %w", err)
+	}
+	defer authorizationWatch.Stop()
+
+	peerAuthenticationWatch, err := c.dynamic.Resource(peerAuthenticationResource).Namespace(c.namespace).Watch(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("watch peerauthentications: %w", err)
+	}
+	defer peerAuthenticationWatch.Stop()
+
+	// TrafficPolicy is optional: without access to it the other policies are
+	// still watched, and the next reconnect tries again.
+	var trafficPolicyEvents <-chan watch.Event
+	if c.traffic != nil {
+		trafficPolicyWatch, err := c.dynamic.Resource(trafficPolicyResource).Namespace(c.namespace).Watch(ctx, metav1.ListOptions{})
+		switch {
+		case trafficPoliciesUnavailable(err):
+		case err != nil:
+			return fmt.Errorf("watch trafficpolicies: %w", err)
+		default:
+			defer trafficPolicyWatch.Stop()
+			trafficPolicyEvents = trafficPolicyWatch.ResultChan()
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-authorizationWatch.ResultChan():
+			if !ok {
+				return fmt.Errorf("authorizationpolicy watch channel closed")
+			}
+
+			if event.Type == watch.Error {
+				return fmt.Errorf("authorizationpolicy watch stream returned error event")
+			}
+
+			if err := c.relist(ctx); err != nil {
+				return err
+			}
+		case event, ok := <-peerAuthenticationWatch.ResultChan():
+			if !ok {
+				return fmt.Errorf("peerauthentication watch channel closed")
+			}
+
+			if event.Type == watch.Error {
+				return fmt.Errorf("peerauthentication watch stream returned error event")
+			}
+
+			if err := c.relist(ctx); err != nil {
+				return err
+			}
+		case event, ok := <-trafficPolicyEvents:
+			if !ok {
+				return fmt.Errorf("trafficpolicy watch channel closed")
+			}
+
+			if event.Type == watch.Error {
+				return fmt.Errorf("trafficpolicy watch stream returned error event")
+			}
+
+			if err := c.relist(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *Controller) relist(ctx context.Context) error {
+	if err := c.relistAuthorizationPolicies(ctx); err != nil {
+		return err
+	}
+
+	if err := c.relistPeerAuthentications(ctx); err != nil {
+		return err
+	}
+
+	return c.relistTrafficPolicies(ctx)
+}
+
+func (c *Controller) relistAuthorizationPolicies(ctx context.Context) error {
+	items, err := c.list(ctx, authorizationPolicyResource)
+	if err != nil {
+		return err
+	}
+
+	policies := make([]AuthorizationPolicy, 0, len(items))
+	for _, item := range items {
+		policy, selected, err := c.parseAuthorizationPolicy(item)
+		if err != nil && selected {
+			// Dropping the policy could leave no ALLOW policy and open the workload.
+			slog.Error(
+				"invalid authorization policy selects this workload, denying all inbound traffic",
+				slog.String("policy", item.GetName()),
+				slog.Any("error", err),
+			)
+			policies = append(policies, denyAllPolicy(item.GetName()))
+			continue
+		}
+		if err != nil {
+			slog.Warn(
+				"skipping invalid authorization policy",
+				slog.String("policy", item.GetName()),
+				slog.Any("error", err),
+			)
+			continue
+		}
+
+		if selected {
+			policies = append(policies, policy)
+		}
+	}
+
+	c.authorization.Replace(policies)
+	return nil
+}
+
+func (c *Controller) relistPeerAuthentications(ctx context.Context) error {
+	items, err := c.list(ctx, peerAuthenticationResource)
+	if err != nil {
+		return err
+	}
+
+	var namespaceMode, selectedMode MTLSMode
+	for _, item := range items {
+		var spec peerAuthenticationSpec
+		if err := decodeSpec(item, &spec); err != nil {
+			slog.Warn("skipping invalid peer authentication", slog.String("policy", item.GetName()), slog.Any("error", err))
+			continue
+		}
+
+		mode := MTLSMode(strings.ToUpper(strings.TrimSpace(spec.MTLS.Mode)))
+		switch mode {
+		case MTLSModeStrict, MTLSModePermissive:
+		default:
+			slog.Warn("skipping peer authentication with unsupported mode", slog.String("policy", item.GetName()), slog.String("mode", spec.MTLS.Mode))
+			continue
+		}
+
+		switch {
+		case len(spec.Selector.MatchLabels) == 0:
+			namespaceMode = strictest(namespaceMode, mode)
+		case c.selects(spec.Selector):
+			selectedMode = strictest(selectedMode, mode)
+		}
+	}
+
+	previous := c.peerAuthentication.Mode()
+	c.peerAuthentication.Replace(namespaceMode, selectedMode)
+	if current := c.peerAuthentication.Mode(); current != previous {
+		slog.Info("inbound mtls mode changed", slog.String("from", string(previous)), slog.String("to", string(current)))
+	}
+
+	return nil
+}
+
+func (c *Controller) list(ctx context.Context, resource schema.GroupVersionResource) ([]unstructured.Unstructured, error) {
+	list, err := c.dynamic.Resource(resource).Namespace(c.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("list %s: %w", resource.Resource, err)
+	}
+
+	return list.Items, nil
+}
+
+func (c *Controller) selects(selector workloadSelector) bool {
+	return labels.SelectorFromSet(selector.MatchLabels).Matches(c.podLabels)
+}
+
+// selectsUnparsed reads only the selector of a resource whose spec cannot be
+// decoded. An unreadable selector is assumed to select this workload.
+func (c *Controller) selectsUnparsed(item unstructured.Unstructured) bool {
+	matchLabels, _, err := unstructured.NestedStringMap(item.Object, "spec", "selector", "matchLabels")
+	if err != nil {
+		return true
+	}
+
+	return c.selects(workloadSelector{MatchLabels: matchLabels})
+}
+
+func decodeSpec(item unstructured.Unstructured, target any) error {
+	rawSpec, _, err := unstructured.NestedMap(item.Object, "spec")
+	if err != nil {
+		return fmt.Errorf("read spec: %w", err)
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSpec, target); err != nil {
+		return fmt.Errorf("decode spec: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Controller) parseAuthorizationPolicy(item unstructured.Unstructured) (AuthorizationPolicy, bool, error) {
+	var spec authorizationPolicySpec
+	if err := decodeSpec(item, &spec); err != nil {
+		return AuthorizationPolicy{}, c.selectsUnparsed(item), err
+	}
+
+	if !c.selects(spec.Selector) {
+		return AuthorizationPolicy{}, false, nil
+	}
+
+	action := AuthorizationAction(strings.ToUpper(strings.TrimSpace(spec.Action)))
+	switch action {
+	case "":
+		action = AuthorizationActionAllow
+	case AuthorizationActionAllow, AuthorizationActionDeny:
+	default:
+		return AuthorizationPolicy{}, true, fmt.Errorf("unsupported action %q", spec.Action)
+	}
+
+	policy := AuthorizationPolicy{
+		Name:   item.GetName(),
+		Action: action,
+		Rules:  make([]AuthorizationRule, 0, len(spec.Rules)),
+	}
+
+	for _, rule := range spec.Rules {
+		policy.Rules = append(policy.Rules, AuthorizationRule{
+			Principals: rule.Principals,
+			Ports:      rule.Ports,
+			Methods:    rule.Methods,
+			Paths:      rule.Paths,
+		})
+	}
+
+	return policy, true, nil
+}
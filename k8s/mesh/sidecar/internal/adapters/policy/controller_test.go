@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type recordingSink struct {
+	replaced bool
+	policies []DestinationPolicy
+}
+
+func (s *recordingSink) ReplaceDestinationPolicies(policies []DestinationPolicy) {
+	s.replaced = true
+	s.policies = policies
+}
+
+func TestInitialSyncToleratesForbiddenTrafficPolicies(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "reviews-v1",
+		Namespace: "bookinfo",
+		Labels:    map[string]string{"app": "reviews"},
+	}})
+
+	denyAdmin := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "mesh.io/v1alpha1",
+		"kind":       "AuthorizationPolicy",
+		"metadata":   map[string]any{"name": "deny-admin", "namespace": "bookinfo"},
+		"spec": map[string]any{
+			"action": "DENY",
+			"rules":  []any{map[string]any{"paths": []any{"/admin/*"}}},
+		},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			authorizationPolicyResource: "AuthorizationPolicyList",
+			peerAuthenticationResource:  "PeerAuthenticationList",
+			trafficPolicyResource:       "TrafficPolicyList",
+		},
+		denyAdmin,
+	)
+	dynamicClient.PrependReactor("list", "trafficpolicies", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(trafficPolicyResource.GroupResource(), "", nil)
+	})
+
+	authorization := NewAuthorizationStore()
+	sink := &recordingSink{policies: []DestinationPolicy{{Name: "stale"}}}
+	controller := NewController(clientset, dynamicClient, "bookinfo", "reviews-v1", authorization, NewPeerAuthenticationStore(MTLSModePermissive, ""), sink)
+
+	if err := controller.InitialSync(context.Background()); err != nil {
+		t.Fatalf("InitialSync() error = %v", err)
+	}
+	if !sink.replaced || len(sink.policies) != 0 {
+		t.Fatalf("destination policies = %v, want none", sink.policies)
+	}
+	if decision := authorization.Evaluate(AuthorizationRequest{HTTP: true, Path: "/admin/users"}); decision.Allowed {
+		t.Fatal("authorization policies were not loaded")
+	}
+}
+
+func TestInvalidAuthorizationPolicyDeniesSelectedWorkload(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "reviews-v1",
+		Namespace: "bookinfo",
+		Labels:    map[string]string{"app": "reviews"},
+	}})
+
+	policy := func(name string, spec map[string]any) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "mesh.io/v1alpha1",
+			"kind":       "AuthorizationPolicy",
+			"metadata":   map[string]any{"name": name, "namespace": "bookinfo"},
+			"spec":       spec,
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		policy      *unstructured.Unstructured
+		wantAllowed bool
+	}{
+		{
+			name: "undecodable rules",
+			policy: policy("allow-frontend", map[string]any{
+				"selector": map[string]any{"matchLabels": map[string]any{"app": "reviews"}},
+				"rules":    []any{map[string]any{"ports": []any{"http"}}},
+			}),
+		},
+		{
+			name:   "unsupported action",
+			policy: policy("audit", map[string]any{"action": "AUDIT"}),
+		},
+		{
+			name: "other workload",
+			policy: policy("allow-ratings", map[string]any{
+				"selector": map[string]any{"matchLabels": map[string]any{"app": "ratings"}},
+				"rules":    []any{map[string]any{"ports": []any{"http"}}},
+			}),
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+				runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					authorizationPolicyResource: "AuthorizationPolicyList",
+					peerAuthenticationResource:  "PeerAuthenticationList",
+					trafficPolicyResource:       "TrafficPolicyList",
+				},
+				tt.policy,
+			)
+
+			authorization := NewAuthorizationStore()
+			controller := NewController(clientset, dynamicClient, "bookinfo", "reviews-v1", authorization, NewPeerAuthenticationStore(MTLSModePermissive, ""), &recordingSink{})
+			if err := controller.InitialSync(context.Background()); err != nil {
+				t.Fatalf("InitialSync() error = %v", err)
+			}
+
+			decision := authorization.Evaluate(AuthorizationRequest{Principal: "spiffe://cluster.local/ns/bookinfo/sa/productpage", Port: 9080})
+			if decision.Allowed != tt.wantAllowed {
+				t.Fatalf("Evaluate().Allowed = %t, want %t", decision.Allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
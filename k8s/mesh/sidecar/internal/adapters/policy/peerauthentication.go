@@ -0,0 +1,58 @@
+package policy
+
+import "sync"
+
+type MTLSMode string
+
+const (
+	MTLSModeStrict     MTLSMode = "STRICT"
+	MTLSModePermissive MTLSMode = "PERMISSIVE"
+)
+
+type PeerAuthenticationStore struct {
+	defaultMode  MTLSMode
+	workloadMode MTLSMode
+
+	mu            sync.RWMutex
+	namespaceMode MTLSMode
+	selectedMode  MTLSMode
+}
+
+func NewPeerAuthenticationStore(defaultMode MTLSMode, workloadMode MTLSMode) *PeerAuthenticationStore {
+	if defaultMode == "" {
+		defaultMode = MTLSModePermissive
+	}
+
+	return &PeerAuthenticationStore{
+		defaultMode:  defaultMode,
+		workloadMode: workloadMode,
+	}
+}
+
+func (s *PeerAuthenticationStore) Replace(namespaceMode MTLSMode, selectedMode MTLSMode) {
+	s.mu.Lock()
+	s.namespaceMode = namespaceMode
+	s.selectedMode = selectedMode
+	s.mu.Unlock()
+}
+
+func (s *PeerAuthenticationStore) Mode() MTLSMode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, mode := range []MTLSMode{s.selectedMode, s.workloadMode, s.namespaceMode} {
+		if mode != "" {
+			return mode
+		}
+	}
+
+	return s.defaultMode
+}
+
+func strictest(current MTLSMode, next MTLSMode) MTLSMode {
+	if current == MTLSModeStrict || next == "" {
+		return current
+	}
+
+	return next
+}
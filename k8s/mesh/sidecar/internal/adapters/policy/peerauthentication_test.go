@@ -0,0 +1,26 @@
+package policy
+
+import "testing"
+
+func TestPeerAuthenticationStoreModePrecedence(t *testing.T) {
+	store := NewPeerAuthenticationStore(MTLSModePermissive, "")
+	if mode := store.Mode(); mode != MTLSModePermissive {
+		t.Fatalf("Mode() = %s, want mesh-wide default %s", mode, MTLSModePermissive)
+	}
+
+	store.Replace(MTLSModeStrict, "")
+	if mode := store.Mode(); mode != MTLSModeStrict {
+		t.Fatalf("Mode() = %s, want namespace mode %s", mode, MTLSModeStrict)
+	}
+
+	store = NewPeerAuthenticationStore(MTLSModePermissive, MTLSModePermissive)
+	store.Replace(MTLSModeStrict, "")
+	if mode := store.Mode(); mode != MTLSModePermissive {
+		t.Fatalf("Mode() = %s, want workload annotation to override namespace policy", mode)
+	}
+
+	store.Replace(MTLSModeStrict, MTLSModeStrict)
+	if mode := store.Mode(); mode != MTLSModeStrict {
+		t.Fatalf("Mode() = %s, want workload policy to override annotation", mode)
+	}
+}
@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DestinationPolicy is a TrafficPolicy resource: traffic settings for one
+// destination service, optionally narrowed to a single service port.
+type DestinationPolicy struct {
+	Name    string
+	Service string
+	Port    int
+	// Spec is the JSON spec; its settings use the sidecar.yaml layout and are
+	// resolved on top of the sidecar-wide policy by the sink.
+	Spec []byte
+}
+
+// TrafficPolicySink receives the TrafficPolicy resources of the namespace on every relist.
+type TrafficPolicySink interface {
+	ReplaceDestinationPolicies(policies []DestinationPolicy)
+}
+
+type trafficPolicySpec struct {
+	Host string `json:"host,omitempty"`
+	Port int    `json:"port,omitempty"`
+}
+
+func parseDestinationPolicy(item unstructured.Unstructured) (DestinationPolicy, error) {
+	var spec trafficPolicySpec
+	if err := decodeSpec(item, &spec); err != nil {
+		return DestinationPolicy{}, err
+	}
+
+	host := strings.TrimSpace(spec.Host)
+	if host == "" {
+		return DestinationPolicy{}, fmt.Errorf("host is required")
+	}
+
+	if spec.Port < 0 || spec.Port > 65535 {
+		return DestinationPolicy{}, fmt.Errorf("port %d is out of range", spec.Port)
+	}
+
+	rawSpec, err := json.Marshal(item.Object["spec"])
+	if err != nil {
+		return DestinationPolicy{}, fmt.Errorf("encode spec: %w", err)
+	}
+
+	return DestinationPolicy{
+		Name:    item.GetName(),
+		Service: destinationService(host, item.GetNamespace()),
+		Port:    spec.Port,
+		Spec:    rawSpec,
+	}, nil
+}
+
+// destinationService turns a short Service name into the FQDN used by discovery.
+func destinationService(host string, namespace string) string {
+	if strings.Contains(host, ".") {
+		return host
+	}
+
+	return fmt.Sprintf("%s.%s.svc.cluster.local", host, namespace)
+}
+
+// trafficPoliciesUnavailable reports errors of a cluster without TrafficPolicy
+// support: the CRD is missing or the workload Role predates it.
+func trafficPoliciesUnavailable(err error) bool {
+	return apierrors.IsNotFound(err) || apierrors.IsForbidden(err)
+}
+
+func (c *Controller) relistTrafficPolicies(ctx context.Context) error {
+	if c.traffic == nil {
+		return nil
+	}
+
+	items, err := c.list(ctx, trafficPolicyResource)
+	if trafficPoliciesUnavailable(err) {
+		if !c.trafficUnavailable {
+			slog.Warn("trafficpolicies are not readable, per-destination policies are disabled", slog.Any("error", err))
+			c.trafficUnavailable = true
+		}
+
+		c.traffic.ReplaceDestinationPolicies(nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.trafficUnavailable = false
+
+	policies := make([]DestinationPolicy, 0, len(items))
+	for _, item := range items {
+		policy, err := parseDestinationPolicy(item)
+		if err != nil {
+			slog.Warn("skipping invalid traffic policy", slog.String("policy", item.GetName()), slog.Any("error", err))
+			continue
+		}
+
+		policies = append(policies, policy)
+	}
+
+	// The list order is not guaranteed; sorting keeps conflict resolution stable.
+	slices.SortFunc(policies, func(a, b DestinationPolicy) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	c.traffic.ReplaceDestinationPolicies(policies)
+	return nil
+}
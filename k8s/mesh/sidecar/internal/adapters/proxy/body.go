@@ -0,0 +1,175 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+func (b *countingBody) count() int {
+	return int(b.n.Load())
+}
+
+// countBody leaves empty bodies in place: transports treat a wrapped
+// http.NoBody as a body of unknown length.
+func countBody(body *io.ReadCloser) *countingBody {
+	if *body == nil || *body == http.NoBody {
+		return &countingBody{}
+	}
+
+	counted := &countingBody{ReadCloser: *body}
+	*body = counted
+	return counted
+}
+
+func countRequestBody(request *http.Request) *countingBody {
+	if request.ContentLength == 0 {
+		return &countingBody{}
+	}
+
+	return countBody(&request.Body)
+}
+
+var errBodyNotReplayable = errors.New("request body exceeded the retry buffer limit")
+
+// ReplayableBody records the first limit bytes of a request body, so a retry
+// can resend what an earlier attempt already consumed and continue with the
+// rest of the client stream. Attempts read through independent readers.
+type ReplayableBody struct {
+	source io.ReadCloser
+	limit  int
+
+	// readMu serializes reads from source; mu guards the recorded state.
+	readMu   sync.Mutex
+	mu       sync.Mutex
+	buf      []byte
+	read     int
+	err      error
+	overflow bool
+}
+
+// BufferRequestBody makes the request body replayable and points
+// request.Body and request.GetBody at its readers. It returns nil for
+// requests without a body, which are always replayable.
+func BufferRequestBody(request *http.Request, limit int) *ReplayableBody {
+	if request.Body == nil || request.Body == http.NoBody || request.ContentLength == 0 {
+		return nil
+	}
+
+	body := &ReplayableBody{source: request.Body, limit: limit}
+	request.Body = body.NewReader()
+	request.GetBody = func() (io.ReadCloser, error) {
+		return body.NewReader(), nil
+	}
+
+	return body
+}
+
+// Replayable reports whether the body read so far still fits the buffer.
+func (b *ReplayableBody) Replayable() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !b.overflow
+}
+
+// NewReader starts the body from the beginning. Closing a reader leaves the
+// source open for later attempts.
+func (b *ReplayableBody) NewReader() io.ReadCloser {
+	return &replayReader{body: b}
+}
+
+// Close drains and closes the client body once no attempt needs it anymore.
+func (b *ReplayableBody) Close() error {
+	return b.source.Close()
+}
+
+func (b *ReplayableBody) readAt(offset int, p []byte) (int, error) {
+	for {
+		if n, ok, err := b.readRecorded(offset, p); ok {
+			return n, err
+		}
+
+		b.readMu.Lock()
+		b.mu.Lock()
+		// Another attempt may have read the source while this one waited.
+		atHead := offset == b.read && b.err == nil
+		b.mu.Unlock()
+		if !atHead {
+			b.readMu.Unlock()
+			continue
+		}
+
+		n, err := b.source.Read(p)
+		b.record(p[:n], err)
+		b.readMu.Unlock()
+		return n, err
+	}
+}
+
+func (b *ReplayableBody) readRecorded(offset int, p []byte) (int, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case offset < b.read && b.overflow:
+		return 0, true, errBodyNotReplayable
+	case offset < b.read:
+		return copy(p, b.buf[offset:]), true, nil
+	case b.err != nil:
+		return 0, true, b.err
+	default:
+		return 0, false, nil
+	}
+}
+
+func (b *ReplayableBody) record(chunk []byte, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.read += len(chunk)
+	if !b.overflow {
+		if b.read > b.limit {
+			b.overflow = true
+			b.buf = nil
+		} else {
+			b.buf = append(b.buf, chunk...)
+		}
+	}
+
+	if err != nil {
+		b.err = err
+	}
+}
+
+type replayReader struct {
+	body   *ReplayableBody
+	offset int
+}
+
+func (r *replayReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err := r.body.readAt(r.offset, p)
+	r.offset += n
+	return n, err
+}
+
+func (r *replayReader) Close() error {
+	return nil
+}
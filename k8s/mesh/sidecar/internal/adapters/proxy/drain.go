@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+type drainState struct {
+	mu       sync.Mutex
+	draining bool
+	idle     map[net.Conn]struct{}
+
+	// Inbound HTTP/2 connections are registered with base so that its
+	// shutdown sends GOAWAY on each of them.
+	base   *http.Server
+	server *http2.Server
+}
+
+func newDrainState() *drainState {
+	state := &drainState{
+		idle:   make(map[net.Conn]struct{}),
+		base:   &http.Server{},
+		server: &http2.Server{},
+	}
+	_ = http2.ConfigureServer(state.base, state.server)
+
+	return state
+}
+
+// Drain tells inbound clients to go away while the listeners stay open:
+// HTTP/1 responses get Connection: close, idle keep-alive connections are
+// closed, HTTP/2 connections receive GOAWAY and idle upstream connections
+// are released.
+func (f *Forwarder) Drain() {
+	f.drain.mu.Lock()
+	if f.drain.draining {
+		f.drain.mu.Unlock()
+		return
+	}
+	f.drain.draining = true
+	for conn := range f.drain.idle {
+		_ = conn.SetReadDeadline(time.Now())
+	}
+	f.drain.mu.Unlock()
+
+	f.goAway()
+	f.closeIdleUpstreams()
+}
+
+func (f *Forwarder) draining() bool {
+	f.drain.mu.Lock()
+	defer f.drain.mu.Unlock()
+
+	return f.drain.draining
+}
+
+func (f *Forwarder) goAway() {
+	// Shutdown only runs the GOAWAY hooks: base has no listeners or HTTP/1 connections.
+	_ = f.drain.base.Shutdown(context.Background())
+}
+
+// awaitNextRequest marks a keep-alive connection as idle until the next
+// request arrives. It reports false when the connection should be closed instead.
+func (f *Forwarder) awaitNextRequest(conn net.Conn) (func(), bool) {
+	f.drain.mu.Lock()
+	defer f.drain.mu.Unlock()
+
+	if f.drain.draining {
+		return nil, false
+	}
+
+	f.drain.idle[conn] = struct{}{}
+	return func() {
+		f.drain.mu.Lock()
+		delete(f.drain.idle, conn)
+		f.drain.mu.Unlock()
+	}, true
+}
+
+// goAwayIfDraining covers connections accepted after Drain: they were not
+// registered when the GOAWAY hooks ran.
+func (f *Forwarder) goAwayIfDraining(ctx *domain.ConnContext, once *sync.Once) {
+	if isInbound(ctx) && f.draining() {
+		once.Do(f.goAway)
+	}
+}
+
+func (f *Forwarder) closeIdleUpstreams() {
+	f.transportMu.Lock()
+	defer f.transportMu.Unlock()
+
+	for _, transport := range f.httpTransports {
+		transport.CloseIdleConnections()
+	}
+	for _, transport := range f.http2Transports {
+		transport.CloseIdleConnections()
+	}
+	if f.plainTransport != nil {
+		f.plainTransport.CloseIdleConnections()
+	}
+	if f.plainH2Transport != nil {
+		f.plainH2Transport.CloseIdleConnections()
+	}
+}
+
+// readHTTPRequest reads the next request of an HTTP/1 connection. On
+// inbound connections it stops after the first response once draining starts.
+func (f *Forwarder) readHTTPRequest(ctx *domain.ConnContext, reader *bufio.Reader, first bool) (*http.Request, bool, error) {
+	if first || !isInbound(ctx) {
+		request, err := http.ReadRequest(reader)
+		return request, true, err
+	}
+
+	release, ok := f.awaitNextRequest(ctx.ClientConn)
+	if !ok {
+		return nil, false, nil
+	}
+
+	request, err := http.ReadRequest(reader)
+	release()
+	if err != nil && f.draining() {
+		return nil, false, nil
+	}
+
+	return request, true, err
+}
+
+// markDrainClose asks the client to close an inbound HTTP/1 connection after this response.
+func (f *Forwarder) markDrainClose(ctx *domain.ConnContext, response *http.Response) {
+	if !isInbound(ctx) || !f.draining() {
+		return
+	}
+
+	response.Close = true
+	response.Header.Set("Connection", "close")
+}
+
+func (f *Forwarder) http2Server(ctx *domain.ConnContext) *http2.Server {
+	if isInbound(ctx) {
+		return f.drain.server
+	}
+
+	return &http2.Server{}
+}
+
+func isInbound(ctx *domain.ConnContext) bool {
+	return ctx.GetString(domain.MetadataDirection) == string(domain.DirectionInbound)
+}
@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func serveInbound(t *testing.T, forwarder *Forwarder, targetAddr string) (net.Listener, <-chan struct{}) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	finished := make(chan struct{}, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				_ = forwarder.Handle(&domain.ConnContext{
+					Context:    context.Background(),
+					ClientConn: conn,
+					Metadata: map[string]any{
+						domain.MetadataDirection:  string(domain.DirectionInbound),
+						domain.MetadataTargetAddr: targetAddr,
+					},
+				})
+				finished <- struct{}{}
+			}()
+		}
+	}()
+
+	return listener, finished
+}
+
+func TestDrainClosesInboundHTTP1KeepAliveConnections(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer upstream.Close()
+
+	forwarder := NewForwarder(nil, time.Second, "")
+	forwarder.PassthroughChain = domain.Chain()
+	listener, finished := serveInbound(t, forwarder, upstream.Listener.Addr().String())
+
+	get := func(conn net.Conn, reader *bufio.Reader) *http.Response {
+		t.Helper()
+
+		if _, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: reviews\r\n\r\n"); err != nil {
+			t.Fatalf("write request: %v", err)
+		}
+		response, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatalf("read response: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, response.Body)
+		_ = response.Body.Close()
+		return response
+	}
+
+	idle, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer idle.Close()
+
+	if response := get(idle, bufio.NewReader(idle)); response.Close {
+		t.Fatal("response asks to close the connection before drain")
+	}
+
+	forwarder.Drain()
+
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle keep-alive connection is still served after drain")
+	}
+
+	fresh, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer fresh.Close()
+
+	// ReadResponse turns "Connection: close" into Close.
+	if response := get(fresh, bufio.NewReader(fresh)); !response.Close {
+		t.Fatal("response during drain does not ask to close the connection")
+	}
+}
+
+func TestDrainSendsGoAwayOnInboundHTTP2Connections(t *testing.T) {
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	upstream.Config.Protocols = new(http.Protocols)
+	upstream.Config.Protocols.SetUnencryptedHTTP2(true)
+	upstream.Start()
+	defer upstream.Close()
+
+	forwarder := NewForwarder(nil, time.Second, "")
+	forwarder.PassthroughChain = domain.Chain()
+	listener, _ := serveInbound(t, forwarder, upstream.Listener.Addr().String())
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	clientConn, err := (&http2.Transport{AllowHTTP: true}).NewClientConn(conn)
+	if err != nil {
+		t.Fatalf("new client conn: %v", err)
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, "http://reviews:9080/", nil)
+	response, err := clientConn.RoundTrip(request)
+	if err != nil {
+		t.Fatalf("round trip: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+
+	forwarder.Drain()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !clientConn.State().Closing {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not receive GOAWAY after drain")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
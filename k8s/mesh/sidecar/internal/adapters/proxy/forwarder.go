@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -9,19 +10,38 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"golang.org/x/net/http2"
+
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
 
 type Forwarder struct {
-	TLSConfig      *tls.Config
-	DialTimeout    time.Duration
-	CopyMode       CopyMode
-	transportMu    sync.Mutex
-	httpTransports map[string]*http.Transport
+	TLSConfig        *tls.Config
+	DialTimeout      time.Duration
+	CopyMode         CopyMode
+	RequestChain     domain.Handler
+	PassthroughChain domain.Handler
+	transportMu      sync.Mutex
+	httpTransports   map[transportKey]*http.Transport
+	plainTransport   *http.Transport
+
+	http2Transports  map[transportKey]*http2.Transport
+	plainH2Transport *http2.Transport
+
+	drain *drainState
+}
+
+// transportKey identifies a pool of mesh connections: one per destination
+// service, port and expected peer identity.
+type transportKey struct {
+	serverName string
+	port       int
+	identity   string
 }
 
 type CopyMode string
@@ -40,7 +60,10 @@ func NewForwarder(tlsConfig *tls.Config, dialTimeout time.Duration, copyMode Cop
 		TLSConfig:      tlsConfig,
 		DialTimeout:    dialTimeout,
 		CopyMode:       copyMode,
-		httpTransports: make(map[string]*http.Transport),
+		httpTransports: make(map[transportKey]*http.Transport),
+
+		http2Transports: make(map[transportKey]*http2.Transport),
+		drain:           newDrainState(),
 	}
 }
 
@@ -52,6 +75,8 @@ func (f *Forwarder) Handle(ctx *domain.ConnContext) error {
 
 	inMesh := ctx.GetBool(domain.MetadataInMesh)
 	serverName := ctx.GetString(domain.MetadataServerName)
+	identity := ctx.GetString(domain.MetadataExpectedIdentity)
+	destinationPort := ctx.GetInt(domain.MetadataDestinationPort)
 
 	slog.Debug(
 		"forward routing decision",
@@ -60,6 +85,15 @@ func (f *Forwarder) Handle(ctx *domain.ConnContext) error {
 		slog.String("server_name", serverName),
 	)
 
+	if f.RequestChain != nil {
+		switch domain.Protocol(ctx.GetString(domain.MetadataProtocol)) {
+		case domain.ProtocolHTTP:
+			return f.serveRoutedHTTP(ctx, bufio.NewReader(ctx.ClientConn))
+		case domain.ProtocolHTTP2:
+			return f.serveRoutedHTTP2(ctx)
+		}
+	}
+
 	var (
 		targetConn net.Conn
 		err        error
@@ -72,11 +106,14 @@ func (f *Forwarder) Handle(ctx *domain.ConnContext) error {
 			return domain.Wrap(domain.ErrorKindTLS, fmt.Errorf("invalid tls configuration"))
 		}
 
-		if handled, err := f.handleHTTP(ctx, targetAddr, serverName, clientReader); handled {
-			return err
+		// A declared opaque protocol may be server-first, waiting for client bytes would only add latency.
+		if ctx.GetString(domain.MetadataProtocol) == "" {
+			if handled, err := f.handleHTTP(ctx, targetAddr, serverName, identity, destinationPort, clientReader); handled {
+				return err
+			}
 		}
 
-		targetConn, err = DialMTLS(ctx.Context, targetAddr, serverName, f.TLSConfig, f.DialTimeout)
+		targetConn, err = f.dialMesh(ctx.Context, targetAddr, serverName, identity, destinationPort)
 		if err != nil {
 			slog.Warn(
 				"forward mTLS dial failed",
@@ -87,19 +124,31 @@ func (f *Forwarder) Handle(ctx *domain.ConnContext) error {
 			return err
 		}
 
+		if tlsConn, ok := targetConn.(*tls.Conn); ok {
+			ctx.Set(domain.MetadataPeerIdentity, PeerIdentity(tlsConn.ConnectionState()))
+		}
+
 		slog.Debug(
 			"forward mTLS dial established",
 			slog.String("target", targetAddr),
 			slog.String("server_name", serverName),
+			slog.String("peer_identity", ctx.GetString(domain.MetadataPeerIdentity)),
 		)
 
 		defer targetConn.Close()
-		if err := bridgeConnectionsWithReader(ctx.ClientConn, clientReader, targetConn, f.CopyMode); err != nil {
+		stats, err := bridgeConnectionsWithReader(ctx.ClientConn, clientReader, targetConn, f.CopyMode)
+		stats.record(ctx)
+		if err != nil {
 			return domain.Wrap(domain.ErrorKindProxy, err)
 		}
 
 		return nil
 	} else {
+		if ctx.GetString(domain.MetadataDirection) == string(domain.DirectionInbound) {
+			authorize, _ := ctx.Metadata[domain.MetadataRequestAuthorizer].(domain.RequestAuthorizer)
+			return f.handleInbound(ctx, targetAddr, authorize)
+		}
+
 		dialer := &net.Dialer{Timeout: f.DialTimeout}
 		targetConn, err = dialer.DialContext(ctx.Context, "tcp", targetAddr)
 		if err != nil {
@@ -111,57 +160,382 @@ func (f *Forwarder) Handle(ctx *domain.ConnContext) error {
 	}
 	defer targetConn.Close()
 
-	if err := bridgeConnections(ctx.ClientConn, targetConn, f.CopyMode); err != nil {
+	stats, err := bridgeConnections(ctx.ClientConn, targetConn, f.CopyMode)
+	stats.record(ctx)
+	if err != nil {
 		return domain.Wrap(domain.ErrorKindProxy, err)
 	}
 
 	return nil
 }
 
-func (f *Forwarder) handleHTTP(ctx *domain.ConnContext, targetAddr string, serverName string, reader *bufio.Reader) (bool, error) {
+func (f *Forwarder) handleInbound(ctx *domain.ConnContext, targetAddr string, authorize domain.RequestAuthorizer) error {
+	clientReader := bufio.NewReader(ctx.ClientConn)
+
+	// Sniffing delays server-first protocols, so it is only done when HTTP
+	// requests are observed or authorized and the port is not declared opaque.
+	var protocol domain.Protocol
+	if (f.PassthroughChain != nil || authorize != nil) && ctx.GetString(domain.MetadataProtocol) == "" {
+		protocol = sniffHTTPProtocol(ctx.ClientConn, clientReader)
+	}
+
+	switch protocol {
+	case domain.ProtocolHTTP:
+		ctx.Set(domain.MetadataProtocol, string(protocol))
+		return f.serveHTTP(ctx, f.plainHTTPTransport(), "http", targetAddr, clientReader)
+	case domain.ProtocolHTTP2:
+		ctx.Set(domain.MetadataProtocol, string(protocol))
+		return f.serveInboundHTTP2(ctx, &bufferedConn{Conn: ctx.ClientConn, reader: clientReader}, targetAddr, authorize)
+	}
+
+	if authorize != nil {
+		if err := authorize(nil); err != nil {
+			return err
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: f.DialTimeout}
+	targetConn, err := dialer.DialContext(ctx.Context, "tcp", targetAddr)
+	if err != nil {
+		slog.Warn("forward plain dial failed", slog.String("target", targetAddr), slog.Any("error", err))
+		return domain.ClassifyDialError(err)
+	}
+	defer targetConn.Close()
+
+	stats, err := bridgeConnectionsWithReader(ctx.ClientConn, clientReader, targetConn, f.CopyMode)
+	stats.record(ctx)
+	if err != nil {
+		return domain.Wrap(domain.ErrorKindProxy, err)
+	}
+
+	return nil
+}
+
+func (f *Forwarder) handleHTTP(
+	ctx *domain.ConnContext,
+	targetAddr string,
+	serverName string,
+	identity string,
+	destinationPort int,
+	reader *bufio.Reader,
+) (bool, error) {
 	if !looksLikeHTTPRequest(ctx.ClientConn, reader) {
 		return false, nil
 	}
 
-	transport := f.httpTransport(serverName)
-	for {
-		request, err := http.ReadRequest(reader)
+	ctx.Set(domain.MetadataProtocol, string(domain.ProtocolHTTP))
+	return true, f.serveHTTP(ctx, f.httpTransport(serverName, identity, destinationPort), "https", targetAddr, reader)
+}
+
+func (f *Forwarder) Dial(
+	ctx context.Context,
+	targetAddr string,
+	serverName string,
+	identity string,
+	destinationPort int,
+) (net.Conn, error) {
+	if serverName != "" {
+		if f.TLSConfig == nil {
+			return nil, domain.Wrap(domain.ErrorKindTLS, fmt.Errorf("invalid tls configuration"))
+		}
+
+		return f.dialMesh(ctx, targetAddr, serverName, identity, destinationPort)
+	}
+
+	dialer := &net.Dialer{Timeout: f.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", targetAddr)
+	if err != nil {
+		return nil, domain.ClassifyDialError(err)
+	}
+
+	return conn, nil
+}
+
+func (f *Forwarder) dialMesh(
+	ctx context.Context,
+	targetAddr string,
+	serverName string,
+	identity string,
+	destinationPort int,
+	nextProtos ...string,
+) (net.Conn, error) {
+	conn, err := DialMTLS(ctx, targetAddr, serverName, identity, f.TLSConfig, f.DialTimeout, nextProtos...)
+	if err != nil {
+		return nil, err
+	}
+
+	if destinationPort > 0 {
+		if err := WritePortPreface(conn, destinationPort); err != nil {
+			_ = conn.Close()
+			return nil, domain.Wrap(domain.ErrorKindProxy, err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (f *Forwarder) serveHTTP(
+	ctx *domain.ConnContext,
+	transport roundTripper,
+	scheme string,
+	targetAddr string,
+	reader *bufio.Reader,
+) error {
+	authorize, _ := ctx.Metadata[domain.MetadataRequestAuthorizer].(domain.RequestAuthorizer)
+	for first := true; ; first = false {
+		request, ok, err := f.readHTTPRequest(ctx, reader, first)
+		if !ok {
+			return nil
+		}
+		if err != nil {
+			if isStreamTerminationError(err) {
+				return nil
+			}
+			return domain.Wrap(domain.ErrorKindProxy, err)
+		}
+		request = request.WithContext(context.WithoutCancel(ctx.Context))
+
+		closeConn := request.Close
+		err = f.passthroughHTTPRequest(ctx, request, func(observed *domain.ConnContext) error {
+			if authorize != nil {
+				if err := authorize(request); err != nil {
+					_ = writeForbidden(ctx.ClientConn, request)
+					return err
+				}
+			}
+
+			request.RequestURI = ""
+			request.URL.Scheme = scheme
+			request.URL.Host = targetAddr
+			if request.URL.Path == "" {
+				request.URL.Path = "/"
+			}
+
+			response, err := roundTripHTTP(observed, transport, request)
+			if err != nil {
+				return domain.Wrap(domain.ErrorKindProxy, err)
+			}
+
+			if response.TLS != nil {
+				observed.Set(domain.MetadataPeerIdentity, PeerIdentity(*response.TLS))
+			}
+			observed.Set(domain.MetadataStatusCode, strconv.Itoa(response.StatusCode))
+			f.markDrainClose(ctx, response)
+			closeConn = closeConn || response.Close
+
+			sent := countBody(&response.Body)
+			err = writeHTTPResponse(ctx.ClientConn, response)
+			observed.Set(domain.MetadataBytesSent, sent.count())
+			if err != nil {
+				return err
+			}
+
+			recordGRPCStatus(observed, response)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if closeConn {
+			return nil
+		}
+	}
+}
+
+func (f *Forwarder) passthroughHTTPRequest(ctx *domain.ConnContext, request *http.Request, handle domain.NextFunc) error {
+	requestCtx := ctx.CloneWithContext(request.Context())
+	requestCtx.Set(domain.MetadataHTTPRequest, request)
+
+	received := countRequestBody(request)
+	terminal := func(observed *domain.ConnContext) error {
+		err := handle(observed)
+		observed.Set(domain.MetadataBytesReceived, received.count())
+		return err
+	}
+
+	if f.PassthroughChain == nil {
+		return terminal(requestCtx)
+	}
+
+	return f.PassthroughChain.Handle(requestCtx, terminal)
+}
+
+func (f *Forwarder) serveRoutedHTTP(ctx *domain.ConnContext, reader *bufio.Reader) error {
+	for first := true; ; first = false {
+		request, ok, err := f.readHTTPRequest(ctx, reader, first)
+		if !ok {
+			return nil
+		}
 		if err != nil {
 			if isStreamTerminationError(err) {
-				return true, nil
+				return nil
 			}
-			return true, domain.Wrap(domain.ErrorKindProxy, err)
+			return domain.Wrap(domain.ErrorKindProxy, err)
 		}
 
-		request.RequestURI = ""
-		request.URL.Scheme = "https"
-		request.URL.Host = targetAddr
-		if request.URL.Path == "" {
-			request.URL.Path = "/"
+		// Each request is routed, timed and accounted on its own, independently of
+		// how long the client keeps the connection open.
+		request = request.WithContext(context.WithoutCancel(ctx.Context))
+
+		closeConn := request.Close
+		wroteHeader := false
+		err = f.routeHTTPRequest(ctx, request, func(_ *domain.ConnContext, response *http.Response) error {
+			f.markDrainClose(ctx, response)
+			closeConn = closeConn || response.Close
+			wroteHeader = true
+			return writeHTTPResponse(ctx.ClientConn, response)
+		})
+		if err != nil {
+			// RequestChain has recorded the failure and the client gets a status
+			// here; an error would let the connection chain replay the connection.
+			if !wroteHeader {
+				status := errorStatus(err)
+				_ = writeStatus(ctx.ClientConn, request, status, http.StatusText(status)+"\n")
+			}
+			slog.Debug("routed http request failed", slog.String("target", ctx.OriginalDst), slog.Any("error", err))
+			return nil
+		}
+
+		if closeConn {
+			return nil
 		}
+	}
+}
+
+func (f *Forwarder) routeHTTPRequest(ctx *domain.ConnContext, request *http.Request, respond responder) error {
+	requestCtx := ctx.CloneWithContext(request.Context())
+	requestCtx.Set(domain.MetadataHTTPRequest, request)
+	received := countRequestBody(request)
 
-		response, err := roundTripHTTP(ctx, transport, request)
+	// The response is written inside the chain, so the status of a streamed
+	// gRPC response is known to metrics and routing once its trailers arrive.
+	return f.RequestChain.Handle(requestCtx, func(routed *domain.ConnContext) error {
+		defer func() { routed.Set(domain.MetadataBytesReceived, received.count()) }()
+
+		response, err := f.roundTripRoute(routed, request)
 		if err != nil {
-			return true, domain.Wrap(domain.ErrorKindProxy, err)
+			return err
 		}
 
-		writeErr := response.Write(ctx.ClientConn)
-		closeErr := response.Body.Close()
-		if writeErr != nil {
-			return true, domain.Wrap(domain.ErrorKindProxy, writeErr)
+		if retry, ok := routed.Metadata[domain.MetadataResponseRetrier].(domain.ResponseRetrier); ok && retry(response) {
+			// The attempt still reports its status to routing and the breaker;
+			// the retry middleware sends the request again.
+			_ = response.Body.Close()
+			return nil
 		}
-		if closeErr != nil {
-			return true, domain.Wrap(domain.ErrorKindProxy, closeErr)
+
+		if cookie, ok := routed.Metadata[domain.MetadataSetCookie].(*http.Cookie); ok {
+			response.Header.Add("Set-Cookie", cookie.String())
 		}
 
-		if request.Close || response.Close {
-			return true, nil
+		sent := countBody(&response.Body)
+		err = respond(routed, response)
+		routed.Set(domain.MetadataBytesSent, sent.count())
+		if err != nil {
+			return err
 		}
+
+		recordGRPCStatus(routed, response)
+		return nil
+	})
+}
+
+func (f *Forwarder) roundTripRoute(ctx *domain.ConnContext, request *http.Request) (*http.Response, error) {
+	targetAddr := ctx.GetString(domain.MetadataTargetAddr)
+	if targetAddr == "" {
+		return nil, domain.Wrap(domain.ErrorKindProxy, fmt.Errorf("missing target address"))
+	}
+
+	inMesh := ctx.GetBool(domain.MetadataInMesh)
+	if inMesh && f.TLSConfig == nil {
+		return nil, domain.Wrap(domain.ErrorKindTLS, fmt.Errorf("invalid tls configuration"))
+	}
+
+	serverName := ctx.GetString(domain.MetadataServerName)
+	identity := ctx.GetString(domain.MetadataExpectedIdentity)
+	destinationPort := ctx.GetInt(domain.MetadataDestinationPort)
+
+	var transport roundTripper
+	switch {
+	case request.ProtoMajor == 2 && inMesh:
+		transport = f.http2Transport(serverName, identity, destinationPort)
+	case request.ProtoMajor == 2:
+		transport = f.plainHTTP2Transport()
+	case inMesh:
+		transport = f.httpTransport(serverName, identity, destinationPort)
+	default:
+		transport = f.plainHTTPTransport()
+	}
+
+	scheme := "http"
+	if inMesh {
+		scheme = "https"
+	}
+
+	request.RequestURI = ""
+	request.URL.Scheme = scheme
+	request.URL.Host = targetAddr
+	if request.URL.Path == "" {
+		request.URL.Path = "/"
+	}
+
+	response, err := roundTripHTTP(ctx, transport, request)
+	if err != nil {
+		return nil, domain.Wrap(domain.ErrorKindProxy, err)
 	}
+
+	if response.TLS != nil {
+		ctx.Set(domain.MetadataPeerIdentity, PeerIdentity(*response.TLS))
+	}
+	ctx.Set(domain.MetadataStatusCode, strconv.Itoa(response.StatusCode))
+
+	if release, ok := ctx.Metadata[domain.MetadataEndpointRelease].(domain.EndpointRelease); ok {
+		delete(ctx.Metadata, domain.MetadataEndpointRelease)
+		response.Body = &releaseOnClose{ReadCloser: response.Body, release: release}
+	}
+
+	return response, nil
 }
 
-func roundTripHTTP(ctx *domain.ConnContext, transport *http.Transport, request *http.Request) (*http.Response, error) {
-	response, err := transport.RoundTrip(request.WithContext(ctx.Context))
+type releaseOnClose struct {
+	io.ReadCloser
+	release domain.EndpointRelease
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// errorStatus is the status a client gets for a request that failed upstream.
+func errorStatus(err error) int {
+	switch {
+	case domain.IsKind(err, domain.ErrorKindTimeout):
+		return http.StatusGatewayTimeout
+	case domain.IsKind(err, domain.ErrorKindProxy):
+		return http.StatusBadGateway
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+func writeHTTPResponse(conn net.Conn, response *http.Response) error {
+	writeErr := response.Write(conn)
+	closeErr := response.Body.Close()
+	if writeErr != nil {
+		return domain.Wrap(domain.ErrorKindProxy, writeErr)
+	}
+	if closeErr != nil {
+		return domain.Wrap(domain.ErrorKindProxy, closeErr)
+	}
+
+	return nil
+}
+
+func roundTripHTTP(ctx *domain.ConnContext, transport roundTripper, request *http.Request) (*http.Response, error) {
+	response, err := roundTripHeaders(ctx.Context, transport, request)
 	if err == nil {
 		return response, nil
 	}
@@ -180,7 +554,25 @@ func roundTripHTTP(ctx *domain.ConnContext, transport *http.Transport, request *
 		retryRequest.Body = body
 	}
 
-	return transport.RoundTrip(retryRequest)
+	ctx.Set(domain.MetadataRetryCount, ctx.GetInt(domain.MetadataRetryCount)+1)
+	return roundTripHeaders(ctx.Context, transport, retryRequest)
+}
+
+func roundTripHeaders(ctx context.Context, transport roundTripper, request *http.Request) (*http.Response, error) {
+	// The deadline of ctx bounds the wait for response headers only: the body
+	// may be a long stream and lives until the caller closes it.
+	requestCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, cancel)
+
+	response, err := transport.RoundTrip(request.WithContext(requestCtx))
+	stop()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	response.Body = &cancelOnClose{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
 }
 
 func canReplayHTTPRequest(request *http.Request) bool {
@@ -193,21 +585,46 @@ func canReplayHTTPRequest(request *http.Request) bool {
 	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
 }
 
-func (f *Forwarder) httpTransport(serverName string) *http.Transport {
+func (f *Forwarder) httpTransport(serverName string, identity string, destinationPort int) *http.Transport {
 	f.transportMu.Lock()
 	defer f.transportMu.Unlock()
 
-	if transport, ok := f.httpTransports[serverName]; ok {
+	key := transportKey{serverName: serverName, port: destinationPort, identity: identity}
+	if transport, ok := f.httpTransports[key]; ok {
 		return transport
 	}
 
-	tlsConfig := f.TLSConfig.Clone()
-	tlsConfig.ClientAuth = tls.NoClientCert
-	tlsConfig.ServerName = serverName
+	transport := f.newHTTPTransport(ClientTLSConfig(f.TLSConfig, serverName, identity))
+	transport.DialTLSContext = func(ctx context.Context, _ string, addr string) (net.Conn, error) {
+		return f.dialMesh(ctx, addr, serverName, identity, destinationPort, "http/1.1")
+	}
+	f.httpTransports[key] = transport
+	return transport
+}
 
-	transport := &http.Transport{
-		Proxy:               nil,
-		DialContext:         (&net.Dialer{Timeout: f.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+func (f *Forwarder) plainHTTPTransport() *http.Transport {
+	f.transportMu.Lock()
+	defer f.transportMu.Unlock()
+
+	if f.plainTransport == nil {
+		f.plainTransport = f.newHTTPTransport(nil)
+	}
+
+	return f.plainTransport
+}
+
+func (f *Forwarder) newHTTPTransport(tlsConfig *tls.Config) *http.Transport {
+	dialer := &net.Dialer{Timeout: f.DialTimeout, KeepAlive: 30 * time.Second}
+
+	return &http.Transport{
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, domain.ClassifyDialError(err)
+			}
+			return conn, nil
+		},
 		TLSClientConfig:     tlsConfig,
 		ForceAttemptHTTP2:   false,
 		MaxIdleConns:        1024,
@@ -216,102 +633,130 @@ func (f *Forwarder) httpTransport(serverName string) *http.Transport {
 		TLSHandshakeTimeout: f.DialTimeout,
 		TLSNextProto:        map[string]func(string, *tls.Conn) http.RoundTripper{},
 	}
-	f.httpTransports[serverName] = transport
-	return transport
 }
 
 func looksLikeHTTPRequest(conn net.Conn, reader *bufio.Reader) bool {
+	return sniffHTTPProtocol(conn, reader) == domain.ProtocolHTTP
+}
+
+func sniffHTTPProtocol(conn net.Conn, reader *bufio.Reader) domain.Protocol {
 	if err := conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
-		return false
+		return ""
 	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	return peekHTTPProtocol(reader)
+}
+
+func peekHTTPProtocol(reader *bufio.Reader) domain.Protocol {
 	prefix, err := reader.Peek(4)
-	_ = conn.SetReadDeadline(time.Time{})
 	if err != nil {
-		return false
+		return ""
 	}
 
 	switch string(prefix) {
 	case "GET ", "POST", "PUT ", "HEAD", "DELE", "PATC", "OPTI":
-		return true
-	default:
-		return false
+		return domain.ProtocolHTTP
+	case "PRI ":
+		preface, err := reader.Peek(len(http2.ClientPreface))
+		if err == nil && string(preface) == http2.ClientPreface {
+			return domain.ProtocolHTTP2
+		}
 	}
-}
-
-func bridgeConnections(clientConn net.Conn, targetConn net.Conn, copyMode CopyMode) error {
-	errCh := make(chan error, 2)
 
-	go func() {
-		errCh <- copyStream(targetConn, clientConn, copyMode)
-	}()
+	return ""
+}
 
-	go func() {
-		errCh <- copyStream(clientConn, targetConn, copyMode)
-	}()
+type transferred struct {
+	received int64
+	sent     int64
+}
 
-	errFirst := <-errCh
-	errSecond := <-errCh
+func (t transferred) record(ctx *domain.ConnContext) {
+	ctx.Set(domain.MetadataBytesReceived, int(t.received))
+	ctx.Set(domain.MetadataBytesSent, int(t.sent))
+}
 
-	if !isStreamTerminationError(errFirst) {
-		return errFirst
-	}
+type copyResult struct {
+	toTarget bool
+	bytes    int64
+	err      error
+}
 
-	if !isStreamTerminationError(errSecond) {
-		return errSecond
-	}
+func bridgeConnections(clientConn net.Conn, targetConn net.Conn, copyMode CopyMode) (transferred, error) {
+	return bridgeStreams(
+		func() (int64, error) { return copyStream(targetConn, clientConn, copyMode) },
+		func() (int64, error) { return copyStream(clientConn, targetConn, copyMode) },
+	)
+}
 
-	return nil
+func bridgeConnectionsWithReader(clientConn net.Conn, clientReader *bufio.Reader, targetConn net.Conn, copyMode CopyMode) (transferred, error) {
+	return bridgeStreams(
+		func() (int64, error) { return copyStreamFromReader(targetConn, clientReader, copyMode) },
+		func() (int64, error) { return copyStream(clientConn, targetConn, copyMode) },
+	)
 }
 
-func bridgeConnectionsWithReader(clientConn net.Conn, clientReader *bufio.Reader, targetConn net.Conn, copyMode CopyMode) error {
-	errCh := make(chan error, 2)
+func bridgeStreams(toTarget func() (int64, error), toClient func() (int64, error)) (transferred, error) {
+	results := make(chan copyResult, 2)
 
 	go func() {
-		errCh <- copyStreamFromReader(targetConn, clientReader, copyMode)
+		n, err := toTarget()
+		results <- copyResult{toTarget: true, bytes: n, err: err}
 	}()
 
 	go func() {
-		errCh <- copyStream(clientConn, targetConn, copyMode)
+		n, err := toClient()
+		results <- copyResult{bytes: n, err: err}
 	}()
 
-	errFirst := <-errCh
-	errSecond := <-errCh
-
-	if !isStreamTerminationError(errFirst) {
-		return errFirst
-	}
+	var (
+		stats     transferred
+		bridgeErr error
+	)
+	for range 2 {
+		result := <-results
+		if result.toTarget {
+			stats.received = result.bytes
+		} else {
+			stats.sent = result.bytes
+		}
 
-	if !isStreamTerminationError(errSecond) {
-		return errSecond
+		if bridgeErr == nil && !isStreamTerminationError(result.err) {
+			bridgeErr = result.err
+		}
 	}
 
-	return nil
+	return stats, bridgeErr
 }
 
-func copyStream(dst net.Conn, src net.Conn, copyMode CopyMode) error {
+func copyStream(dst net.Conn, src net.Conn, copyMode CopyMode) (int64, error) {
 	if copyMode == CopyModeZeroCopy {
-		if copied, err := copyStreamZeroCopy(dst, src); copied {
+		if n, copied, err := copyStreamZeroCopy(dst, src); copied {
 			closeWrite(dst)
-			return err
+			return n, err
 		}
 	}
 
-	_, err := copyStreamBuffered(dst, src)
+	n, err := copyStreamBuffered(dst, src)
 	closeWrite(dst)
-	return err
+	return n, err
 }
 
-func copyStreamFromReader(dst net.Conn, src *bufio.Reader, copyMode CopyMode) error {
+func copyStreamFromReader(dst net.Conn, src *bufio.Reader, copyMode CopyMode) (int64, error) {
+	var buffered int64
 	if src.Buffered() > 0 {
-		if _, err := copyStreamBuffered(dst, io.LimitReader(src, int64(src.Buffered()))); err != nil {
+		n, err := copyStreamBuffered(dst, io.LimitReader(src, int64(src.Buffered())))
+		if err != nil {
 			closeWrite(dst)
-			return err
+			return n, err
 		}
+		buffered = n
 	}
 
-	_, err := copyStreamBuffered(dst, src)
+	n, err := copyStreamBuffered(dst, src)
 	closeWrite(dst)
-	return err
+	return buffered + n, err
 }
 
 type plainReader struct {
@@ -326,19 +771,19 @@ func copyStreamBuffered(dst io.Writer, src io.Reader) (int64, error) {
 	return io.CopyBuffer(plainWriter{Writer: dst}, plainReader{Reader: src}, make([]byte, 32*1024))
 }
 
-func copyStreamZeroCopy(dst net.Conn, src net.Conn) (bool, error) {
+func copyStreamZeroCopy(dst net.Conn, src net.Conn) (int64, bool, error) {
 	tcpDst, ok := dst.(*net.TCPConn)
 	if !ok {
-		return false, nil
+		return 0, false, nil
 	}
 
 	tcpSrc, ok := src.(*net.TCPConn)
 	if !ok {
-		return false, nil
+		return 0, false, nil
 	}
 
-	_, err := tcpDst.ReadFrom(tcpSrc)
-	return true, err
+	n, err := tcpDst.ReadFrom(tcpSrc)
+	return n, true, err
 }
 
 func closeWrite(conn net.Conn) {
@@ -1,9 +1,16 @@
 package proxy
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
 	"net"
+	"net/http"
 	"testing"
 	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
 
 type closeWriteConn struct {
@@ -51,3 +58,149 @@ func TestNewForwarderDefaultsToBufferedCopyMode(t *testing.T) {
 		t.Fatalf("CopyMode = %q, want %q", forwarder.CopyMode, CopyModeBuffered)
 	}
 }
+
+func TestBridgeConnectionsCountsTransferredBytes(t *testing.T) {
+	clientConn, clientPeer := net.Pipe()
+	targetConn, targetPeer := net.Pipe()
+
+	go func() {
+		_, _ = clientPeer.Write([]byte("ping"))
+		_, _ = io.ReadFull(clientPeer, make([]byte, 5))
+		_ = clientPeer.Close()
+	}()
+	go func() {
+		_, _ = io.ReadFull(targetPeer, make([]byte, 4))
+		_, _ = targetPeer.Write([]byte("pong!"))
+		_ = targetPeer.Close()
+	}()
+
+	stats, err := bridgeConnections(clientConn, targetConn, CopyModeBuffered)
+	if err != nil {
+		t.Fatalf("bridgeConnections() error = %v", err)
+	}
+
+	if stats.received != 4 || stats.sent != 5 {
+		t.Fatalf("transferred = %+v, want received 4 and sent 5", stats)
+	}
+}
+
+func TestForwarderAnswersFailedHTTP1RequestsWithStatus(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	target := closed.Addr().String()
+	_ = closed.Close()
+
+	forwarder := NewForwarder(nil, time.Second, "")
+	forwarder.RequestChain = domain.Chain()
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+
+		_ = forwarder.Handle(&domain.ConnContext{
+			Context:    context.Background(),
+			ClientConn: server,
+			Metadata: map[string]any{
+				domain.MetadataProtocol:   string(domain.ProtocolHTTP),
+				domain.MetadataTargetAddr: target,
+			},
+		})
+	}()
+
+	request, err := http.NewRequest(http.MethodGet, "http://reviews:9080/reviews", nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	go func() {
+		_ = request.Write(client)
+	}()
+
+	response, err := http.ReadResponse(bufio.NewReader(client), request)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", response.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestForwarderPassesDeclaredOpaqueInboundThrough(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer echo.Close()
+
+	go func() {
+		conn, err := echo.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	forwarder := NewForwarder(nil, time.Second, "")
+	forwarder.PassthroughChain = domain.Chain()
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+
+		_ = forwarder.Handle(&domain.ConnContext{
+			Context:    context.Background(),
+			ClientConn: server,
+			Metadata: map[string]any{
+				domain.MetadataDirection:  string(domain.DirectionInbound),
+				domain.MetadataProtocol:   string(domain.ProtocolTCP),
+				domain.MetadataTargetAddr: echo.Addr().String(),
+			},
+		})
+	}()
+
+	// HTTP-looking bytes on an opaque port must reach the application untouched.
+	const payload = "GET / HTTP/1.1\r\nHost: db\r\n\r\n"
+	go func() {
+		_, _ = io.WriteString(client, payload)
+	}()
+
+	_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	got := make([]byte, len(payload))
+	if _, err := io.ReadFull(client, got); err != nil {
+		t.Fatalf("read echo: %v", err)
+	}
+	if string(got) != payload {
+		t.Fatalf("echo = %q, want %q", got, payload)
+	}
+}
+
+func TestForwarderPoolsTransportsPerIdentity(t *testing.T) {
+	forwarder := NewForwarder(&tls.Config{}, time.Second, "")
+
+	first := forwarder.httpTransport("reviews.bookinfo.svc.cluster.local", "/ns/bookinfo/sa/reviews", 9080)
+	if again := forwarder.httpTransport("reviews.bookinfo.svc.cluster.local", "/ns/bookinfo/sa/reviews", 9080); again != first {
+		t.Fatal("httpTransport() returned a new transport for the same destination")
+	}
+
+	for _, other := range []struct {
+		serverName string
+		identity   string
+		port       int
+	}{
+		{serverName: "reviews.bookinfo.svc.cluster.local", identity: "/ns/bookinfo/sa/ratings", port: 9080},
+		{serverName: "reviews.bookinfo.svc.cluster.local", identity: "/ns/bookinfo/sa/reviews", port: 9081},
+		{serverName: "ratings.bookinfo.svc.cluster.local", identity: "/ns/bookinfo/sa/reviews", port: 9080},
+	} {
+		if forwarder.httpTransport(other.serverName, other.identity, other.port) == first {
+			t.Fatalf("httpTransport(%q, %q, %d) shares the pool of another destination", other.serverName, other.identity, other.port)
+		}
+	}
+}
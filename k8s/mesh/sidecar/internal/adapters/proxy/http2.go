@@ -0,0 +1,297 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+const (
+	grpcContentType       = "application/grpc"
+	grpcStatusUnavailable = "14"
+	grpcStatusDeadline    = "4"
+	grpcStatusDenied      = "7"
+)
+
+type responder func(routed *domain.ConnContext, response *http.Response) error
+
+type roundTripper interface {
+	http.RoundTripper
+	CloseIdleConnections()
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (f *Forwarder) serveRoutedHTTP2(ctx *domain.ConnContext) error {
+	var goAway sync.Once
+	// Streams outlive the connection-level timeout; RequestChain applies it per request.
+	f.http2Server(ctx).ServeConn(ctx.ClientConn, &http2.ServeConnOpts{
+		Context: context.WithoutCancel(ctx.Context),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			f.goAwayIfDraining(ctx, &goAway)
+			wroteHeader := false
+			err := f.routeHTTPRequest(ctx, request, func(_ *domain.ConnContext, response *http.Response) error {
+				wroteHeader = true
+				return writeHTTP2Response(w, response)
+			})
+			finishHTTP2Stream(w, request, err, wroteHeader)
+		}),
+	})
+
+	return nil
+}
+
+func (f *Forwarder) serveInboundHTTP2(
+	ctx *domain.ConnContext,
+	conn net.Conn,
+	targetAddr string,
+	authorize domain.RequestAuthorizer,
+) error {
+	var goAway sync.Once
+	f.http2Server(ctx).ServeConn(conn, &http2.ServeConnOpts{
+		Context: context.WithoutCancel(ctx.Context),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			f.goAwayIfDraining(ctx, &goAway)
+			wroteHeader := false
+			err := f.passthroughHTTPRequest(ctx, request, func(observed *domain.ConnContext) error {
+				if authorize != nil {
+					if err := authorize(request); err != nil {
+						writeHTTP2Forbidden(w, request)
+						return err
+					}
+				}
+
+				request.RequestURI = ""
+				request.URL.Scheme = "http"
+				request.URL.Host = targetAddr
+
+				response, err := roundTripHTTP(observed, f.plainHTTP2Transport(), request)
+				if err != nil {
+					return domain.Wrap(domain.ErrorKindProxy, err)
+				}
+				observed.Set(domain.MetadataStatusCode, strconv.Itoa(response.StatusCode))
+
+				wroteHeader = true
+				sent := countBody(&response.Body)
+				err = writeHTTP2Response(w, response)
+				observed.Set(domain.MetadataBytesSent, sent.count())
+				if err != nil {
+					return err
+				}
+
+				recordGRPCStatus(observed, response)
+				return nil
+			})
+			if domain.IsKind(err, domain.ErrorKindForbidden) {
+				return
+			}
+			finishHTTP2Stream(w, request, err, wroteHeader)
+		}),
+	})
+
+	return nil
+}
+
+func finishHTTP2Stream(w http.ResponseWriter, request *http.Request, err error, wroteHeader bool) {
+	if err == nil {
+		return
+	}
+
+	if wroteHeader {
+		// The status is already sent, only a stream reset tells the client the response is broken.
+		panic(http.ErrAbortHandler)
+	}
+	writeHTTP2Error(w, request, err)
+}
+
+func (f *Forwarder) http2Transport(serverName string, identity string, destinationPort int) *http2.Transport {
+	f.transportMu.Lock()
+	defer f.transportMu.Unlock()
+
+	key := transportKey{serverName: serverName, port: destinationPort, identity: identity}
+	if transport, ok := f.http2Transports[key]; ok {
+		return transport
+	}
+
+	transport := &http2.Transport{
+		DialTLSContext: func(ctx context.Context, _ string, addr string, _ *tls.Config) (net.Conn, error) {
+			return f.dialMesh(ctx, addr, serverName, identity, destinationPort, http2.NextProtoTLS)
+		},
+		ReadIdleTimeout: 30 * time.Second,
+	}
+	f.http2Transports[key] = transport
+	return transport
+}
+
+func (f *Forwarder) plainHTTP2Transport() *http2.Transport {
+	f.transportMu.Lock()
+	defer f.transportMu.Unlock()
+
+	if f.plainH2Transport == nil {
+		dialer := &net.Dialer{Timeout: f.DialTimeout, KeepAlive: 30 * time.Second}
+		f.plainH2Transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network string, addr string, _ *tls.Config) (net.Conn, error) {
+				conn, err := dialer.DialContext(ctx, network, addr)
+				if err != nil {
+					return nil, domain.ClassifyDialError(err)
+				}
+				return conn, nil
+			},
+			ReadIdleTimeout: 30 * time.Second,
+		}
+	}
+
+	return f.plainH2Transport
+}
+
+func writeHTTP2Response(w http.ResponseWriter, response *http.Response) error {
+	defer response.Body.Close()
+
+	header := w.Header()
+	for key, values := range response.Header {
+		header[key] = values
+	}
+	w.WriteHeader(response.StatusCode)
+
+	controller := http.NewResponseController(w)
+	buffer := make([]byte, 32*1024)
+	for {
+		n, readErr := response.Body.Read(buffer)
+		if n > 0 {
+			if _, err := w.Write(buffer[:n]); err != nil {
+				return domain.Wrap(domain.ErrorKindProxy, err)
+			}
+			// gRPC streams expect every message to reach the client without waiting for the next one.
+			_ = controller.Flush()
+		}
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if readErr != nil {
+			return domain.Wrap(domain.ErrorKindProxy, readErr)
+		}
+	}
+
+	for key, values := range response.Trailer {
+		header[http.TrailerPrefix+key] = values
+	}
+
+	return nil
+}
+
+func writeHTTP2Error(w http.ResponseWriter, request *http.Request, err error) {
+	status, grpcCode := errorStatus(err), grpcStatusUnavailable
+	if domain.IsKind(err, domain.ErrorKindTimeout) {
+		grpcCode = grpcStatusDeadline
+	}
+
+	if IsGRPCRequest(request) {
+		writeGRPCStatus(w, grpcCode, domain.NormalizeErrorType(err))
+		return
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
+
+func writeHTTP2Forbidden(w http.ResponseWriter, request *http.Request) {
+	if IsGRPCRequest(request) {
+		writeGRPCStatus(w, grpcStatusDenied, strings.TrimSpace(forbiddenBody))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = io.WriteString(w, forbiddenBody)
+}
+
+func writeGRPCStatus(w http.ResponseWriter, code string, message string) {
+	// A trailers-only response: gRPC clients read the status from the headers.
+	w.Header().Set("Content-Type", grpcContentType)
+	w.Header().Set("Grpc-Status", code)
+	w.Header().Set("Grpc-Message", message)
+	w.WriteHeader(http.StatusOK)
+}
+
+func IsGRPCRequest(request *http.Request) bool {
+	return strings.HasPrefix(request.Header.Get("Content-Type"), grpcContentType)
+}
+
+// IsGRPCUnavailable reports a trailers-only gRPC response with status
+// UNAVAILABLE, the code gRPC treats as safe to retry.
+func IsGRPCUnavailable(response *http.Response) bool {
+	status, ok := grpcStatus(response)
+	return ok && status == grpcStatusUnavailable
+}
+
+func recordGRPCStatus(ctx *domain.ConnContext, response *http.Response) {
+	if status, ok := grpcStatus(response); ok {
+		ctx.Set(domain.MetadataGRPCStatus, status)
+		ctx.Set(domain.MetadataStatusCode, grpcHTTPStatus(status))
+	}
+}
+
+func grpcStatus(response *http.Response) (string, bool) {
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), grpcContentType) {
+		return "", false
+	}
+
+	if status := response.Trailer.Get("Grpc-Status"); status != "" {
+		return status, true
+	}
+	if status := response.Header.Get("Grpc-Status"); status != "" {
+		return status, true
+	}
+
+	// A gRPC response without a status was cut off before its trailers.
+	return "2", true
+}
+
+func grpcHTTPStatus(code string) string {
+	switch code {
+	case "0":
+		return "200"
+	case "1":
+		return "499"
+	case "3", "9", "11":
+		return "400"
+	case "4":
+		return "504"
+	case "5":
+		return "404"
+	case "6", "10":
+		return "409"
+	case "7":
+		return "403"
+	case "8":
+		return "429"
+	case "12":
+		return "501"
+	case "14":
+		return "503"
+	case "16":
+		return "401"
+	default:
+		return "500"
+	}
+}
@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+type statusCapture struct {
+	mu     sync.Mutex
+	status string
+	grpc   string
+}
+
+func (c *statusCapture) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	err := next(ctx)
+
+	c.mu.Lock()
+	c.status = ctx.GetString(domain.MetadataStatusCode)
+	c.grpc = ctx.GetString(domain.MetadataGRPCStatus)
+	c.mu.Unlock()
+
+	return err
+}
+
+func TestSniffHTTPProtocolDetectsHTTP2Preface(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want domain.Protocol
+	}{
+		{name: "http2", data: http2.ClientPreface, want: domain.ProtocolHTTP2},
+		{name: "http1", data: "GET / HTTP/1.1\r\n\r\n", want: domain.ProtocolHTTP},
+		{name: "opaque", data: "PRI something else entirely", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			go func() {
+				_, _ = io.WriteString(client, tt.data)
+			}()
+
+			if got := sniffHTTPProtocol(server, bufio.NewReader(server)); got != tt.want {
+				t.Fatalf("sniffHTTPProtocol() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwarderProxiesGRPCStatusFromTrailers(t *testing.T) {
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			w.WriteHeader(http.StatusHTTPVersionNotSupported)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		_, _ = w.Write([]byte{0, 0, 0, 0, 0})
+		w.Header().Set("Grpc-Status", "14")
+	}))
+	upstream.Config.Protocols = new(http.Protocols)
+	upstream.Config.Protocols.SetUnencryptedHTTP2(true)
+	upstream.Start()
+	defer upstream.Close()
+
+	capture := &statusCapture{}
+	forwarder := NewForwarder(nil, time.Second, "")
+	forwarder.RequestChain = domain.Chain(capture)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_ = forwarder.Handle(&domain.ConnContext{
+			Context:    context.Background(),
+			ClientConn: conn,
+			Metadata: map[string]any{
+				domain.MetadataProtocol:   string(domain.ProtocolHTTP2),
+				domain.MetadataTargetAddr: upstream.Listener.Addr().String(),
+			},
+		})
+	}()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network string, _ string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, listener.Addr().String())
+		},
+	}}
+
+	request, err := http.NewRequest(http.MethodPost, "http://reviews:9080/reviews.v1.Reviews/Get", strings.NewReader("\x00\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/grpc")
+
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("round trip: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+
+	if got := response.Trailer.Get("Grpc-Status"); got != "14" {
+		t.Fatalf("Grpc-Status trailer = %q, want 14", got)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		capture.mu.Lock()
+		status, grpcStatus := capture.status, capture.grpc
+		capture.mu.Unlock()
+
+		if status == "503" && grpcStatus == "14" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("recorded status = %q, grpc status = %q, want 503 and 14", status, grpcStatus)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForwarderServesHTTP2StreamsAfterConnectionTimeout(t *testing.T) {
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	upstream.Config.Protocols = new(http.Protocols)
+	upstream.Config.Protocols.SetUnencryptedHTTP2(true)
+	upstream.Start()
+	defer upstream.Close()
+
+	forwarder := NewForwarder(nil, time.Second, "")
+	forwarder.RequestChain = domain.Chain()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// The connection-level timeout middleware leaves a context like this one.
+		connCtx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		_ = forwarder.Handle(&domain.ConnContext{
+			Context:    connCtx,
+			ClientConn: conn,
+			Metadata: map[string]any{
+				domain.MetadataProtocol:   string(domain.ProtocolHTTP2),
+				domain.MetadataTargetAddr: upstream.Listener.Addr().String(),
+			},
+		})
+	}()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network string, _ string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, listener.Addr().String())
+		},
+	}}
+
+	for i := range 2 {
+		if i > 0 {
+			time.Sleep(500 * time.Millisecond)
+		}
+
+		response, err := client.Get("http://reviews:9080/reviews/1")
+		if err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+		_, _ = io.Copy(io.Discard, response.Body)
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i+1, response.StatusCode)
+		}
+	}
+}
@@ -6,35 +6,29 @@ import (
 	"crypto/x509"
 	"fmt"
 	"net"
-	"os"
+	"slices"
 	"time"
 
+	"golang.org/x/net/http2"
+
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
 
-func BuildTLSFromFiles(certFile string, keyFile string, caFile string) (*tls.Config, error) {
-	certPEM, err := os.ReadFile(certFile)
-	if err != nil {
-		return nil, fmt.Errorf("read cert file: %w", err)
-	}
+// MeshNextProto is offered in ALPN by every mesh client, so that a plain
+// inbound port tells mesh mTLS from TLS the application terminates itself.
+const MeshNextProto = "mesh"
 
-	keyPEM, err := os.ReadFile(keyFile)
-	if err != nil {
-		return nil, fmt.Errorf("read key file: %w", err)
-	}
+type CertificateSource interface {
+	Certificate() (*tls.Certificate, error)
+}
 
-	caPEM, err := os.ReadFile(caFile)
-	if err != nil {
-		return nil, fmt.Errorf("read ca file: %w", err)
+func BuildRotatingTLS(source CertificateSource, caPEM []byte, trustDomain string) (*tls.Config, error) {
+	if source == nil {
+		return nil, fmt.Errorf("certificate source is nil")
 	}
 
-	return BuildTLSFromIssuedMaterial(certPEM, keyPEM, caPEM)
-}
-
-func BuildTLSFromIssuedMaterial(certPEM []byte, keyPEM []byte, caPEM []byte) (*tls.Config, error) {
-	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		return nil, fmt.Errorf("load key pair: %w", err)
+	if trustDomain == "" {
+		return nil, fmt.Errorf("trust domain is empty")
 	}
 
 	caPool := x509.NewCertPool()
@@ -43,20 +37,47 @@ func BuildTLSFromIssuedMaterial(certPEM []byte, keyPEM []byte, caPEM []byte) (*t
 	}
 
 	return &tls.Config{
-		MinVersion:   tls.VersionTLS12,
-		Certificates: []tls.Certificate{certificate},
-		RootCAs:      caPool,
-		ClientCAs:    caPool,
-		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return source.Certificate()
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return source.Certificate()
+		},
+		VerifyPeerCertificate: verifySPIFFEPeer(trustDomain, caPool),
+		RootCAs:               caPool,
+		ClientCAs:             caPool,
+		ClientAuth:            tls.RequireAndVerifyClientCert,
+		NextProtos:            []string{http2.NextProtoTLS, "http/1.1", MeshNextProto},
 	}, nil
 }
 
+// ClientTLSConfig accepts only a peer presenting identity, the SPIFFE ID path
+// of the destination's service account, when it is set.
+func ClientTLSConfig(baseConfig *tls.Config, serverName string, identity string) *tls.Config {
+	clientConfig := baseConfig.Clone()
+	clientConfig.ClientAuth = tls.NoClientCert
+	clientConfig.ServerName = serverName
+	clientConfig.NextProtos = nil
+	if clientConfig.VerifyPeerCertificate != nil {
+		// Peers are authenticated by SPIFFE ID in VerifyPeerCertificate, not by host name.
+		clientConfig.InsecureSkipVerify = true
+		if identity != "" {
+			clientConfig.VerifyPeerCertificate = verifySPIFFEIdentity(clientConfig.VerifyPeerCertificate, identity)
+		}
+	}
+
+	return clientConfig
+}
+
 func DialMTLS(
 	ctx context.Context,
 	address string,
 	serverName string,
+	identity string,
 	baseConfig *tls.Config,
 	dialTimeout time.Duration,
+	nextProtos ...string,
 ) (net.Conn, error) {
 	if baseConfig == nil {
 		return nil, domain.Wrap(domain.ErrorKindTLS, fmt.Errorf("missing tls config"))
@@ -66,15 +87,14 @@ func DialMTLS(
 		return nil, domain.Wrap(domain.ErrorKindTLS, fmt.Errorf("missing tls server name"))
 	}
 
-	clientConfig := baseConfig.Clone()
-	clientConfig.ClientAuth = tls.NoClientCert
-	clientConfig.ServerName = serverName
+	config := ClientTLSConfig(baseConfig, serverName, identity)
+	config.NextProtos = append(slices.Clone(nextProtos), MeshNextProto)
 
 	dialer := &tls.Dialer{
 		NetDialer: &net.Dialer{
 			Timeout: dialTimeout,
 		},
-		Config: clientConfig,
+		Config: config,
 	}
 
 	connection, err := dialer.DialContext(ctx, "tcp", address)
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	portPrefaceMagic  = "MSH1"
+	portPrefaceLength = len(portPrefaceMagic) + 2
+)
+
+func WritePortPreface(conn net.Conn, port int) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid destination port %d", port)
+	}
+
+	preface := make([]byte, portPrefaceLength)
+	copy(preface, portPrefaceMagic)
+	binary.BigEndian.PutUint16(preface[len(portPrefaceMagic):], uint16(port))
+
+	if _, err := conn.Write(preface); err != nil {
+		return fmt.Errorf("write destination port preface: %w", err)
+	}
+
+	return nil
+}
+
+func ReadPortPreface(conn net.Conn, timeout time.Duration) (net.Conn, int) {
+	reader := bufio.NewReader(conn)
+	buffered := &bufferedConn{Conn: conn, reader: reader}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return buffered, 0
+	}
+	preface, err := reader.Peek(portPrefaceLength)
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil || string(preface[:len(portPrefaceMagic)]) != portPrefaceMagic {
+		return buffered, 0
+	}
+
+	port := int(binary.BigEndian.Uint16(preface[len(portPrefaceMagic):]))
+	_, _ = reader.Discard(portPrefaceLength)
+
+	return buffered, port
+}
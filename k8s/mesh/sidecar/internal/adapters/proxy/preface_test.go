@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPortPrefaceRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_ = WritePortPreface(client, 9000)
+		_, _ = client.Write([]byte("ping"))
+		_ = client.Close()
+	}()
+
+	conn, port := ReadPortPreface(server, time.Second)
+	if port != 9000 {
+		t.Fatalf("ReadPortPreface() port = %d, want 9000", port)
+	}
+
+	payload, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	if string(payload) != "ping" {
+		t.Fatalf("payload = %q, want %q", payload, "ping")
+	}
+}
+
+func TestReadPortPrefaceKeepsDataWithoutPreface(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_, _ = client.Write([]byte("GET / HTTP/1.1\r\n"))
+		_ = client.Close()
+	}()
+
+	conn, port := ReadPortPreface(server, time.Second)
+	if port != 0 {
+		t.Fatalf("ReadPortPreface() port = %d, want 0", port)
+	}
+
+	payload, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	if string(payload) != "GET / HTTP/1.1\r\n" {
+		t.Fatalf("payload = %q, want request line", payload)
+	}
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	forbiddenBody         = "RBAC: access denied\n"
+	forbiddenDrainLimit   = 64 * 1024
+	forbiddenReadDeadline = time.Second
+)
+
+func RejectForbidden(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	if !looksLikeHTTPRequest(conn, reader) {
+		return
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(forbiddenReadDeadline))
+	defer conn.SetReadDeadline(time.Time{})
+
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+
+	_ = writeForbidden(conn, request)
+}
+
+func writeForbidden(conn net.Conn, request *http.Request) error {
+	return writeStatus(conn, request, http.StatusForbidden, forbiddenBody)
+}
+
+// writeStatus answers request with a plain-text response and closes the
+// connection afterwards.
+func writeStatus(conn net.Conn, request *http.Request, status int, body string) error {
+	// Consume what is left of the request so closing the socket does not
+	// turn into a reset before the client has read the response.
+	if request.Body != nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(request.Body, forbiddenDrainLimit))
+		_ = request.Body.Close()
+	}
+
+	response := &http.Response{
+		StatusCode:    status,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Request:       request,
+		Header:        http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Close:         true,
+	}
+
+	return response.Write(conn)
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+const (
+	tlsRecordTypeHandshake = 0x16
+	tlsRecordHeaderLen     = 5
+	tlsMaxRecordLen        = 1 << 14
+)
+
+var errClientHelloRead = errors.New("client hello read")
+
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// SniffMeshTLS reports whether the connection starts with a ClientHello of a
+// mesh client, which offers MeshNextProto. Any other TLS is left untouched.
+func SniffMeshTLS(conn net.Conn, timeout time.Duration) (net.Conn, bool) {
+	reader := bufio.NewReaderSize(conn, tlsRecordHeaderLen+tlsMaxRecordLen)
+	sniffed := &bufferedConn{Conn: conn, reader: reader}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return sniffed, false
+	}
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+
+	// TLS record header: handshake content type, a 3.x version and the length.
+	header, err := reader.Peek(tlsRecordHeaderLen)
+	if err != nil || header[0] != tlsRecordTypeHandshake || header[1] != 0x03 || header[2] > 0x04 {
+		return sniffed, false
+	}
+
+	length := int(binary.BigEndian.Uint16(header[3:]))
+	if length > tlsMaxRecordLen {
+		return sniffed, false
+	}
+
+	record, err := reader.Peek(tlsRecordHeaderLen + length)
+	if err != nil {
+		return sniffed, false
+	}
+
+	return sniffed, slices.Contains(clientHelloProtos(record), MeshNextProto)
+}
+
+// clientHelloProtos returns the ALPN protocols of a ClientHello record. The
+// record is fed to a server handshake that stops once the hello is parsed.
+func clientHelloProtos(record []byte) []string {
+	var protos []string
+	server := tls.Server(&helloConn{reader: bytes.NewReader(record)}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			protos = hello.SupportedProtos
+			return nil, errClientHelloRead
+		},
+	})
+	_ = server.Handshake()
+
+	return protos
+}
+
+// helloConn replays a peeked ClientHello and discards whatever the handshake
+// writes back, so the real client sees nothing of the parsing.
+type helloConn struct {
+	reader io.Reader
+}
+
+func (c *helloConn) Read(p []byte) (int, error)       { return c.reader.Read(p) }
+func (c *helloConn) Write(p []byte) (int, error)      { return len(p), nil }
+func (c *helloConn) Close() error                     { return nil }
+func (c *helloConn) LocalAddr() net.Addr              { return nil }
+func (c *helloConn) RemoteAddr() net.Addr             { return nil }
+func (c *helloConn) SetDeadline(time.Time) error      { return nil }
+func (c *helloConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *helloConn) SetWriteDeadline(time.Time) error { return nil }
+
+func SniffHTTP(conn net.Conn) (net.Conn, domain.Protocol) {
+	reader := bufio.NewReader(conn)
+	return &bufferedConn{Conn: conn, reader: reader}, sniffHTTPProtocol(conn, reader)
+}
+
+func PeekHTTP(conn net.Conn) (net.Conn, domain.Protocol) {
+	reader := bufio.NewReader(conn)
+	return &bufferedConn{Conn: conn, reader: reader}, peekHTTPProtocol(reader)
+}
+
+func IsLocalConnection(conn net.Conn) bool {
+	remote, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	if remote.IP.IsLoopback() {
+		return true
+	}
+
+	local, ok := conn.LocalAddr().(*net.TCPAddr)
+	return ok && local.IP.Equal(remote.IP)
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSniffMeshTLSDetectsMeshClientHello(t *testing.T) {
+	tests := []struct {
+		name       string
+		nextProtos []string
+		plaintext  bool
+		want       bool
+	}{
+		{name: "mesh l4", nextProtos: []string{MeshNextProto}, want: true},
+		{name: "mesh http2", nextProtos: []string{"h2", MeshNextProto}, want: true},
+		{name: "application tls", nextProtos: []string{"h2", "http/1.1"}},
+		{name: "tls without alpn"},
+		{name: "plaintext", plaintext: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			go func() {
+				if tt.plaintext {
+					_, _ = io.WriteString(client, "GET / HTTP/1.1\r\n\r\n")
+					return
+				}
+				_ = tls.Client(client, &tls.Config{InsecureSkipVerify: true, NextProtos: tt.nextProtos}).Handshake()
+			}()
+
+			conn, got := SniffMeshTLS(server, time.Second)
+			if got != tt.want {
+				t.Fatalf("SniffMeshTLS() = %t, want %t", got, tt.want)
+			}
+
+			// The sniffed bytes are still delivered to whoever reads the connection.
+			first := make([]byte, 1)
+			if _, err := io.ReadFull(conn, first); err != nil {
+				t.Fatalf("read sniffed connection: %v", err)
+			}
+			want := byte(tlsRecordTypeHandshake)
+			if tt.plaintext {
+				want = 'G'
+			}
+			if first[0] != want {
+				t.Fatalf("first byte = %#x, want %#x", first[0], want)
+			}
+		})
+	}
+}
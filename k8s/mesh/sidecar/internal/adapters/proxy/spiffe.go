@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+const spiffeScheme = "spiffe"
+
+func PeerIdentity(state tls.ConnectionState) string {
+	if len(state.PeerCertificates) == 0 {
+		return ""
+	}
+
+	id, err := spiffeIDFromCertificate(state.PeerCertificates[0])
+	if err != nil {
+		return ""
+	}
+
+	return id.String()
+}
+
+func HandshakePeer(ctx context.Context, conn *tls.Conn, timeout time.Duration) (string, error) {
+	handshakeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := conn.HandshakeContext(handshakeCtx); err != nil {
+		return "", domain.Wrap(domain.ErrorKindTLS, fmt.Errorf("inbound tls handshake: %w", err))
+	}
+
+	identity := PeerIdentity(conn.ConnectionState())
+	if identity == "" {
+		return "", domain.Wrap(domain.ErrorKindTLS, fmt.Errorf("peer certificate has no spiffe identity"))
+	}
+
+	return identity, nil
+}
+
+func verifySPIFFEPeer(trustDomain string, roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		var leaf *x509.Certificate
+		if len(verifiedChains) > 0 && len(verifiedChains[0]) > 0 {
+			leaf = verifiedChains[0][0]
+		} else {
+			verified, err := verifyChain(rawCerts, roots)
+			if err != nil {
+				return err
+			}
+			leaf = verified
+		}
+
+		id, err := spiffeIDFromCertificate(leaf)
+		if err != nil {
+			return err
+		}
+
+		if id.Host != trustDomain {
+			return fmt.Errorf("peer spiffe id %s is outside trust domain %q", id, trustDomain)
+		}
+
+		return nil
+	}
+}
+
+// verifySPIFFEIdentity narrows verify down to a single workload identity.
+func verifySPIFFEIdentity(
+	verify func([][]byte, [][]*x509.Certificate) error,
+	identity string,
+) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if err := verify(rawCerts, verifiedChains); err != nil {
+			return err
+		}
+
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("parse peer certificate: %w", err)
+		}
+
+		id, err := spiffeIDFromCertificate(leaf)
+		if err != nil {
+			return err
+		}
+
+		if id.Path != identity {
+			return fmt.Errorf("peer spiffe id %s does not match expected identity %q", id, identity)
+		}
+
+		return nil
+	}
+}
+
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) (*x509.Certificate, error) {
+	if len(rawCerts) == 0 {
+		return nil, fmt.Errorf("peer did not present a certificate")
+	}
+
+	certificates := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		certificate, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse peer certificate: %w", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	if _, err := certificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}); err != nil {
+		return nil, fmt.Errorf("verify peer certificate chain: %w", err)
+	}
+
+	return certificates[0], nil
+}
+
+func spiffeIDFromCertificate(certificate *x509.Certificate) (*url.URL, error) {
+	var found *url.URL
+	for _, uri := range certificate.URIs {
+		if uri == nil || !strings.EqualFold(uri.Scheme, spiffeScheme) {
+			continue
+		}
+
+		if found != nil {
+			return nil, fmt.Errorf("peer certificate has more than one spiffe id")
+		}
+		found = uri
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("peer certificate has no spiffe id")
+	}
+
+	if found.Host == "" || !strings.HasPrefix(found.Path, "/") {
+		return nil, fmt.Errorf("peer certificate has malformed spiffe id %q", found.String())
+	}
+
+	return found, nil
+}
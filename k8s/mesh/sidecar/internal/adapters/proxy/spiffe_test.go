@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestVerifySPIFFEPeerAcceptsTrustDomainIdentity(t *testing.T) {
+	roots, leafDER := newTestChain(t, "spiffe://cluster.local/ns/default/sa/reviews")
+
+	verify := verifySPIFFEPeer("cluster.local", roots)
+	if err := verify([][]byte{leafDER}, nil); err != nil {
+		t.Fatalf("verifySPIFFEPeer() error = %v", err)
+	}
+}
+
+func TestVerifySPIFFEPeerRejectsForeignTrustDomain(t *testing.T) {
+	roots, leafDER := newTestChain(t, "spiffe://other.example/ns/default/sa/reviews")
+
+	verify := verifySPIFFEPeer("cluster.local", roots)
+	if err := verify([][]byte{leafDER}, nil); err == nil {
+		t.Fatal("expected foreign trust domain to be rejected")
+	}
+}
+
+func TestVerifySPIFFEPeerRejectsCertificateWithoutSPIFFEID(t *testing.T) {
+	roots, leafDER := newTestChain(t, "")
+
+	verify := verifySPIFFEPeer("cluster.local", roots)
+	if err := verify([][]byte{leafDER}, nil); err == nil {
+		t.Fatal("expected certificate without spiffe id to be rejected")
+	}
+}
+
+func TestVerifySPIFFEIdentityRejectsOtherServiceAccount(t *testing.T) {
+	roots, leafDER := newTestChain(t, "spiffe://cluster.local/ns/default/sa/ratings")
+
+	verify := verifySPIFFEPeer("cluster.local", roots)
+	if err := verifySPIFFEIdentity(verify, "/ns/default/sa/ratings")([][]byte{leafDER}, nil); err != nil {
+		t.Fatalf("verifySPIFFEIdentity() error = %v", err)
+	}
+	if err := verifySPIFFEIdentity(verify, "/ns/default/sa/reviews")([][]byte{leafDER}, nil); err == nil {
+		t.Fatal("expected a peer with another service account to be rejected")
+	}
+}
+
+func newTestChain(t *testing.T, spiffeID string) (*x509.CertPool, []byte) {
+	t.Helper()
+
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate CA key: %v", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create CA certificate: %v", err)
+	}
+
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("parse CA certificate: %v", err)
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate leaf key: %v", err)
+	}
+
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "default/reviews"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+
+	if spiffeID != "" {
+		uri, err := url.Parse(spiffeID)
+		if err != nil {
+			t.Fatalf("parse spiffe id: %v", err)
+		}
+		leafTemplate.URIs = []*url.URL{uri}
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caCert, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create leaf certificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	return roots, leafDER
+}
@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	exportQueueSize     = 2048
+	exportBatchSize     = 512
+	exportFlushInterval = 5 * time.Second
+	exportTimeout       = 10 * time.Second
+	scopeName           = "github.com/LLIEPJIOK/sidecar"
+)
+
+type Exporter struct {
+	endpoint string
+	resource map[string]string
+	client   *http.Client
+	queue    chan *Span
+}
+
+func NewExporter(endpoint string, resource map[string]string) *Exporter {
+	return &Exporter{
+		endpoint: endpoint,
+		resource: resource,
+		client:   &http.Client{Timeout: exportTimeout},
+		queue:    make(chan *Span, exportQueueSize),
+	}
+}
+
+func (e *Exporter) Enqueue(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+		// A slow collector must not hold up proxied requests.
+		slog.Debug("span export queue is full, dropping span", slog.String("trace_id", span.Context.TraceID.String()))
+	}
+}
+
+func (e *Exporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(exportFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, exportBatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(ctx, batch); err != nil {
+			slog.Warn("span export failed", slog.Int("spans", len(batch)), slog.Any("error", err))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+		drain:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+				default:
+					break drain
+				}
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), exportTimeout)
+			flush(shutdownCtx)
+			cancel()
+			return ctx.Err()
+		case <-ticker.C:
+			flush(ctx)
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				flush(ctx)
+			}
+		}
+	}
+}
+
+func (e *Exporter) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.payload(spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build export request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("send spans: %w", err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("collector responded with status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+// The payload follows the OTLP/HTTP JSON encoding of ExportTraceServiceRequest.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+const (
+	otlpStatusUnset = 0
+	otlpStatusError = 2
+)
+
+func (e *Exporter) payload(spans []*Span) otlpRequest {
+	resource := make(map[string]any, len(e.resource))
+	for key, value := range e.resource {
+		resource[key] = value
+	}
+
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		encoded = append(encoded, encodeSpan(span))
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes(resource)},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: encoded}},
+	}}}
+}
+
+func encodeSpan(span *Span) otlpSpan {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	encoded := otlpSpan{
+		TraceID:           span.Context.TraceID.String(),
+		SpanID:            span.Context.SpanID.String(),
+		TraceState:        span.Context.TraceState,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		Attributes:        encodeAttributes(span.attributes),
+		Status:            otlpStatus{Code: otlpStatusUnset},
+	}
+	if span.ParentID.IsValid() {
+		encoded.ParentSpanID = span.ParentID.String()
+	}
+	if span.failed {
+		encoded.Status = otlpStatus{Code: otlpStatusError, Message: span.message}
+	}
+
+	return encoded
+}
+
+func encodeAttributes(attributes map[string]any) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	encoded := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		var value otlpValue
+		switch typed := attributes[key].(type) {
+		case string:
+			value.StringValue = &typed
+		case int:
+			formatted := strconv.Itoa(typed)
+			value.IntValue = &formatted
+		case bool:
+			value.BoolValue = &typed
+		default:
+			formatted := fmt.Sprint(typed)
+			value.StringValue = &formatted
+		}
+		encoded = append(encoded, otlpAttribute{Key: key, Value: value})
+	}
+
+	return encoded
+}
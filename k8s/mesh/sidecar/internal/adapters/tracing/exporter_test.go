@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExporterSendsOTLPJSON(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", r.Header.Get("Content-Type"))
+		}
+
+		var payload otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		received <- payload
+	}))
+	defer collector.Close()
+
+	exporter := NewExporter(collector.URL+"/v1/traces", map[string]string{"service.name": "reviews-v1.bookinfo"})
+	tracer := NewTracer(1, exporter)
+
+	span := tracer.Start(http.Header{}, "GET ratings", SpanKindClient)
+	span.SetAttribute("mesh.retry_count", 1)
+	span.SetError("dial")
+	tracer.Finish(span)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- exporter.Run(ctx) }()
+	cancel()
+
+	select {
+	case payload := <-received:
+		spans := payload.ResourceSpans[0].ScopeSpans[0].Spans
+		if len(spans) != 1 {
+			t.Fatalf("exported %d spans, want 1", len(spans))
+		}
+		if spans[0].TraceID != span.Context.TraceID.String() || spans[0].Kind != SpanKindClient {
+			t.Fatalf("exported span = %+v, want trace %s and client kind", spans[0], span.Context.TraceID)
+		}
+		if spans[0].Status.Code != otlpStatusError {
+			t.Fatalf("status code = %d, want error", spans[0].Status.Code)
+		}
+		if value := spans[0].Attributes[0].Value.IntValue; value == nil || *value != "1" {
+			t.Fatalf("mesh.retry_count = %v, want 1", value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("collector received no spans")
+	}
+
+	<-done
+}
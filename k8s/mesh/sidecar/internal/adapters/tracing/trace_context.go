@@ -0,0 +1,133 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	headerTraceparent = "Traceparent"
+	headerTracestate  = "Tracestate"
+
+	traceparentLength = 55
+	flagSampled       = 0x01
+)
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+func (c SpanContext) Traceparent() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + c.TraceID.String() + "-" + c.SpanID.String() + "-" + flags
+}
+
+func Extract(header http.Header) (SpanContext, bool) {
+	values := header.Values(headerTraceparent)
+	if len(values) != 1 {
+		return SpanContext{}, false
+	}
+
+	parent, ok := parseTraceparent(values[0])
+	if !ok {
+		return SpanContext{}, false
+	}
+
+	parent.TraceState = strings.Join(header.Values(headerTracestate), ",")
+	return parent, true
+}
+
+func Inject(header http.Header, span SpanContext) {
+	header.Set(headerTraceparent, span.Traceparent())
+	if span.TraceState != "" {
+		header.Set(headerTracestate, span.TraceState)
+	} else {
+		header.Del(headerTracestate)
+	}
+}
+
+func parseTraceparent(value string) (SpanContext, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < traceparentLength {
+		return SpanContext{}, false
+	}
+
+	var version [1]byte
+	if !decodeHex(version[:], value[0:2]) || version[0] == 0xff {
+		return SpanContext{}, false
+	}
+	// Version 00 has a fixed length, later versions may append fields after a dash.
+	if version[0] == 0 && len(value) != traceparentLength {
+		return SpanContext{}, false
+	}
+	if len(value) > traceparentLength && value[traceparentLength] != '-' {
+		return SpanContext{}, false
+	}
+
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return SpanContext{}, false
+	}
+
+	var parent SpanContext
+	if !decodeHex(parent.TraceID[:], value[3:35]) || !parent.TraceID.IsValid() {
+		return SpanContext{}, false
+	}
+	if !decodeHex(parent.SpanID[:], value[36:52]) || !parent.SpanID.IsValid() {
+		return SpanContext{}, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(flags[:], value[53:55]) {
+		return SpanContext{}, false
+	}
+	parent.Sampled = flags[0]&flagSampled != 0
+
+	return parent, true
+}
+
+func decodeHex(dst []byte, value string) bool {
+	if !isLowerHex(value) {
+		return false
+	}
+
+	n, err := hex.Decode(dst, []byte(value))
+	return err == nil && n == len(dst)
+}
+
+func isLowerHex(value string) bool {
+	for _, char := range value {
+		if (char < '0' || char > '9') && (char < 'a' || char > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true, sampled: true},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", valid: true},
+		{value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", valid: true, sampled: true},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{value: "00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseTraceparent(tt.value)
+		if ok != tt.valid {
+			t.Fatalf("parseTraceparent(%q) valid = %t, want %t", tt.value, ok, tt.valid)
+		}
+		if ok && got.Sampled != tt.sampled {
+			t.Fatalf("parseTraceparent(%q) sampled = %t, want %t", tt.value, got.Sampled, tt.sampled)
+		}
+	}
+}
+
+func TestTracerContinuesIncomingTrace(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("tracestate", "vendor=value")
+
+	span := NewTracer(0, nil).Start(header, "GET reviews", SpanKindClient)
+
+	if span.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("trace id = %s, want the incoming trace id", span.Context.TraceID)
+	}
+	if span.ParentID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("parent id = %s, want the incoming span id", span.ParentID)
+	}
+	if !span.Context.Sampled {
+		t.Fatal("span is not sampled, want the sampled flag of the parent to win over the rate")
+	}
+	if got := header.Get("traceparent"); got != span.Context.Traceparent() {
+		t.Fatalf("traceparent = %q, want %q", got, span.Context.Traceparent())
+	}
+	if got := header.Get("tracestate"); got != "vendor=value" {
+		t.Fatalf("tracestate = %q, want vendor=value", got)
+	}
+}
+
+func TestTracerStartsTraceWithSampling(t *testing.T) {
+	header := http.Header{}
+	span := NewTracer(1, nil).Start(header, "GET reviews", SpanKindServer)
+
+	if span.ParentID.IsValid() {
+		t.Fatalf("root span has parent %s", span.ParentID)
+	}
+	if !span.Context.Sampled {
+		t.Fatal("span is not sampled with rate 1")
+	}
+	if header.Get("traceparent") == "" {
+		t.Fatal("traceparent was not injected")
+	}
+
+	if NewTracer(0, nil).Start(http.Header{}, "GET reviews", SpanKindServer).Context.Sampled {
+		t.Fatal("span is sampled with rate 0")
+	}
+}
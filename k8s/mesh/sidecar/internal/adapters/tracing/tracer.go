@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type SpanKind int
+
+const (
+	SpanKindServer SpanKind = 2
+	SpanKindClient SpanKind = 3
+)
+
+type Span struct {
+	Context  SpanContext
+	ParentID SpanID
+	Name     string
+	Kind     SpanKind
+	Start    time.Time
+	End      time.Time
+
+	mu         sync.Mutex
+	attributes map[string]any
+	failed     bool
+	message    string
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+func (s *Span) SetError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failed = true
+	s.message = message
+}
+
+type spanExporter interface {
+	Enqueue(span *Span)
+}
+
+type Tracer struct {
+	sampleRate float64
+	exporter   spanExporter
+}
+
+func NewTracer(sampleRate float64, exporter spanExporter) *Tracer {
+	return &Tracer{sampleRate: sampleRate, exporter: exporter}
+}
+
+func (t *Tracer) Start(header http.Header, name string, kind SpanKind) *Span {
+	span := &Span{Name: name, Kind: kind, Start: time.Now()}
+
+	if parent, ok := Extract(header); ok {
+		span.Context = parent
+		span.ParentID = parent.SpanID
+	} else {
+		span.Context.TraceID = newTraceID()
+		span.Context.Sampled = t.sample(span.Context.TraceID)
+	}
+	span.Context.SpanID = newSpanID()
+
+	// The next hop sees this span as its parent.
+	Inject(header, span.Context)
+	return span
+}
+
+func (t *Tracer) Finish(span *Span) {
+	span.End = time.Now()
+	if span.Context.Sampled && t.exporter != nil {
+		t.exporter.Enqueue(span)
+	}
+}
+
+func (t *Tracer) sample(traceID TraceID) bool {
+	switch {
+	case t.sampleRate >= 1:
+		return true
+	case t.sampleRate <= 0:
+		return false
+	}
+
+	// The decision depends on the trace id only, so every hop that starts
+	// from the same id agrees on it.
+	return binary.BigEndian.Uint64(traceID[8:]) < uint64(t.sampleRate*math.MaxUint64)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+
+	return id
+}
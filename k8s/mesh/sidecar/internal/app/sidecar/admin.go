@@ -0,0 +1,131 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+)
+
+type connectionCounter struct {
+	mu     sync.Mutex
+	active map[string]int
+}
+
+func newConnectionCounter() *connectionCounter {
+	return &connectionCounter{active: make(map[string]int)}
+}
+
+func (c *connectionCounter) add(listener string, delta int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.active[listener] += delta
+}
+
+func (c *connectionCounter) snapshot() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return maps.Clone(c.active)
+}
+
+type adminServer struct {
+	cfg          config.Config
+	cache        *discovery.ServiceCache
+	breaker      *breakerMiddleware
+	traffic      *trafficPolicies
+	certificates *certificateManager
+	connections  *connectionCounter
+	logLevel     *slog.LevelVar
+}
+
+func (a *adminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, _ *http.Request) {
+		cfg := a.cfg
+		if a.traffic != nil {
+			cfg = cfg.WithTrafficPolicy(a.traffic.Load())
+		}
+		writeAdminJSON(w, http.StatusOK, cfg)
+	})
+	mux.HandleFunc("GET /services", func(w http.ResponseWriter, _ *http.Request) {
+		writeAdminJSON(w, http.StatusOK, a.cache.Snapshot())
+	})
+	mux.HandleFunc("GET /breakers", a.handleBreakers)
+	mux.HandleFunc("GET /certificates", a.handleCertificates)
+	mux.HandleFunc("GET /connections", func(w http.ResponseWriter, _ *http.Request) {
+		writeAdminJSON(w, http.StatusOK, a.connections.snapshot())
+	})
+	mux.HandleFunc("GET /logging", a.handleLogging)
+	mux.HandleFunc("POST /logging", a.handleLogging)
+
+	if a.cfg.AdminPprofEnabled {
+		mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+		mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	}
+
+	return mux
+}
+
+func (a *adminServer) handleBreakers(w http.ResponseWriter, _ *http.Request) {
+	if a.breaker == nil {
+		writeAdminJSON(w, http.StatusOK, []breakerStatus{})
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, a.breaker.snapshot())
+}
+
+func (a *adminServer) handleCertificates(w http.ResponseWriter, _ *http.Request) {
+	if a.certificates == nil {
+		writeAdminError(w, http.StatusNotFound, "mTLS is disabled")
+		return
+	}
+
+	info, ok := a.certificates.info()
+	if !ok {
+		writeAdminError(w, http.StatusServiceUnavailable, "workload certificate is not issued yet")
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, info)
+}
+
+func (a *adminServer) handleLogging(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(r.URL.Query().Get("level"))); err != nil {
+			writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("invalid level: %v", err))
+			return
+		}
+
+		previous := a.logLevel.Level()
+		a.logLevel.Set(level)
+		slog.Info("log level changed", slog.String("from", previous.String()), slog.String("to", level.String()))
+	}
+
+	writeAdminJSON(w, http.StatusOK, map[string]string{"level": a.logLevel.Level().String()})
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(value)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, message string) {
+	writeAdminJSON(w, status, map[string]string{"error": message})
+}
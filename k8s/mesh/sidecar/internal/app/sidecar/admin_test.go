@@ -0,0 +1,103 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func TestAdminLoggingSwitchesLevel(t *testing.T) {
+	admin := &adminServer{logLevel: new(slog.LevelVar), connections: newConnectionCounter()}
+	handler := admin.Handler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/logging?level=debug", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("POST /logging status = %d, want 200", recorder.Code)
+	}
+	if got := admin.logLevel.Level(); got != slog.LevelDebug {
+		t.Fatalf("level = %s, want DEBUG", got)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/logging?level=verbose", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("POST /logging with unknown level status = %d, want 400", recorder.Code)
+	}
+	if got := admin.logLevel.Level(); got != slog.LevelDebug {
+		t.Fatalf("level = %s after rejected switch, want DEBUG", got)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("pprof status = %d without ADMIN_PPROF_ENABLED, want 404", recorder.Code)
+	}
+}
+
+func TestAdminReportsBreakersAndConnections(t *testing.T) {
+	traffic := newTrafficPolicies(config.TrafficPolicy{
+		CircuitBreakerPolicy: config.CircuitBreakerPolicy{FailureThreshold: 1, RecoveryTime: time.Minute},
+	}, nil)
+	breaker := newBreakerMiddleware(traffic, metrics.NewRecorder())
+	ctx := &domain.ConnContext{}
+	ctx.Set(domain.MetadataBreakerKey, "reviews:9080")
+	_ = breaker.Handle(ctx, func(*domain.ConnContext) error {
+		return domain.Wrap(domain.ErrorKindDial, errors.New("connection refused"))
+	})
+
+	connections := newConnectionCounter()
+	connections.add("outbound", 1)
+	connections.add("outbound", 1)
+	connections.add("outbound", -1)
+
+	admin := &adminServer{
+		cfg:         config.Config{AdminPprofEnabled: true},
+		cache:       discovery.NewServiceCache(nil),
+		breaker:     breaker,
+		connections: connections,
+		logLevel:    new(slog.LevelVar),
+	}
+	handler := admin.Handler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/breakers", nil))
+	var breakers []breakerStatus
+	if err := json.NewDecoder(recorder.Body).Decode(&breakers); err != nil {
+		t.Fatalf("decode /breakers: %v", err)
+	}
+	if len(breakers) != 1 || breakers[0].Key != "reviews:9080" || breakers[0].State != "open" {
+		t.Fatalf("breakers = %+v, want one open breaker for reviews:9080", breakers)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/connections", nil))
+	var active map[string]int
+	if err := json.NewDecoder(recorder.Body).Decode(&active); err != nil {
+		t.Fatalf("decode /connections: %v", err)
+	}
+	if active["outbound"] != 1 {
+		t.Fatalf("active outbound connections = %d, want 1", active["outbound"])
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/certificates", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("/certificates status = %d without mTLS, want 404", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("pprof status = %d with ADMIN_PPROF_ENABLED, want 200", recorder.Code)
+	}
+}
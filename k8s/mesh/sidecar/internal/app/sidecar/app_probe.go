@@ -0,0 +1,108 @@
+package sidecar
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+)
+
+const appHealthPath = "/app-health/"
+
+// appProber runs the application probes that the injector moved to the
+// status port. The kubelet probes from the node IP, which STRICT mTLS and
+// authorization would reject on the application ports, so the sidecar
+// repeats the probe over loopback and reports the result.
+type appProber struct {
+	probes map[string]config.AppProbe
+	client *http.Client
+	dialer net.Dialer
+}
+
+func newAppProber(probes map[string]config.AppProbe) *appProber {
+	if len(probes) == 0 {
+		return nil
+	}
+
+	return &appProber{
+		probes: probes,
+		client: &http.Client{
+			Transport: &http.Transport{
+				// The kubelet does not verify application certificates either.
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives: true,
+			},
+			// The kubelet counts 3xx as success, pass it on instead of following.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (p *appProber) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	probe, ok := p.probes[request.URL.Path]
+	if !ok {
+		http.NotFound(w, request)
+		return
+	}
+
+	switch {
+	case probe.HTTPGet != nil:
+		p.probeHTTP(w, request, probe.HTTPGet)
+	case probe.TCPSocket != nil:
+		p.probeTCP(w, request, probe.TCPSocket.Port)
+	default:
+		http.NotFound(w, request)
+	}
+}
+
+func (p *appProber) probeHTTP(w http.ResponseWriter, request *http.Request, probe *config.AppHTTPProbe) {
+	scheme := strings.ToLower(probe.Scheme)
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	path := probe.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	url := scheme + "://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(probe.Port)) + path
+	probeRequest, err := http.NewRequestWithContext(request.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, header := range probe.Headers {
+		if strings.EqualFold(header.Name, "Host") {
+			probeRequest.Host = header.Value
+			continue
+		}
+		probeRequest.Header.Add(header.Name, header.Value)
+	}
+
+	response, err := p.client.Do(probeRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	_ = response.Body.Close()
+
+	w.WriteHeader(response.StatusCode)
+}
+
+func (p *appProber) probeTCP(w http.ResponseWriter, request *http.Request, port int) {
+	conn, err := p.dialer.DialContext(request.Context(), "tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	_ = conn.Close()
+
+	w.WriteHeader(http.StatusOK)
+}
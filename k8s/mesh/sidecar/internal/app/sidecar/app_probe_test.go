@@ -0,0 +1,80 @@
+package sidecar
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+)
+
+func TestAppProbesRunOverLoopback(t *testing.T) {
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		switch {
+		case request.URL.Path == "/ready" && request.Header.Get("X-Probe") == "kubelet" && request.Host == "reviews.local":
+			w.WriteHeader(http.StatusOK)
+		case request.URL.Path == "/moved":
+			http.Redirect(w, request, "/elsewhere", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer app.Close()
+
+	_, portText, err := net.SplitHostPort(app.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("split app address: %v", err)
+	}
+	appPort, _ := strconv.Atoi(portText)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	_ = closed.Close()
+
+	state := newReadiness(newAppProber(map[string]config.AppProbe{
+		"/app-health/reviews/readyz": {HTTPGet: &config.AppHTTPProbe{
+			Path: "/ready",
+			Port: appPort,
+			Headers: []config.AppProbeHeader{
+				{Name: "X-Probe", Value: "kubelet"},
+				{Name: "Host", Value: "reviews.local"},
+			},
+		}},
+		"/app-health/reviews/livez":    {HTTPGet: &config.AppHTTPProbe{Path: "/broken", Port: appPort}},
+		"/app-health/reviews/startupz": {HTTPGet: &config.AppHTTPProbe{Path: "/moved", Port: appPort}},
+		"/app-health/ratings/readyz":   {TCPSocket: &config.AppTCPProbe{Port: appPort}},
+		"/app-health/ratings/livez":    {TCPSocket: &config.AppTCPProbe{Port: closedPort}},
+	}))
+	status := httptest.NewServer(state.Handler())
+	defer status.Close()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/app-health/reviews/readyz", want: http.StatusOK},
+		{path: "/app-health/reviews/livez", want: http.StatusInternalServerError},
+		{path: "/app-health/reviews/startupz", want: http.StatusFound},
+		{path: "/app-health/ratings/readyz", want: http.StatusOK},
+		{path: "/app-health/ratings/livez", want: http.StatusServiceUnavailable},
+		{path: "/app-health/ratings/startupz", want: http.StatusNotFound},
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for _, tt := range tests {
+		response, err := client.Get(status.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", tt.path, err)
+		}
+		_ = response.Body.Close()
+
+		if response.StatusCode != tt.want {
+			t.Fatalf("GET %s status = %d, want %d", tt.path, response.StatusCode, tt.want)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
@@ -13,42 +12,68 @@ import (
 	"strings"
 
 	"github.com/LLIEPJIOK/sidecar/internal/adapters/certmanager"
-	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
 	"github.com/LLIEPJIOK/sidecar/internal/config"
 )
 
-func bootstrapTLSConfig(ctx context.Context, cfg config.Config) (*tls.Config, error) {
+type issuedMaterial struct {
+	certPEM []byte
+	keyPEM  []byte
+	caPEM   []byte
+}
+
+func issueCertificate(ctx context.Context, cfg config.Config, client *certmanager.Client) (issuedMaterial, error) {
 	if !cfg.BootstrapCertificates {
-		return proxy.BuildTLSFromFiles(cfg.CertFile, cfg.KeyFile, cfg.CAFile)
+		return readCertificateFiles(cfg)
 	}
 
 	tokenRaw, err := os.ReadFile(cfg.ServiceAccountTokenPath)
 	if err != nil {
-		return nil, fmt.Errorf("read service account token: %w", err)
+		return issuedMaterial{}, fmt.Errorf("read service account token: %w", err)
 	}
 
 	token := strings.TrimSpace(string(tokenRaw))
 	if token == "" {
-		return nil, fmt.Errorf("service account token is empty")
+		return issuedMaterial{}, fmt.Errorf("service account token is empty")
 	}
 
 	keyPEM, csrPEM, err := generateCSR(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("generate csr: %w", err)
+		return issuedMaterial{}, fmt.Errorf("generate csr: %w", err)
 	}
 
-	client := certmanager.NewClient(cfg.CertManagerSignURL, nil)
 	leafCertPEM, caPEM, err := client.Sign(ctx, csrPEM, token)
 	if err != nil {
-		return nil, fmt.Errorf("request certificate from cert-manager: %w", err)
+		return issuedMaterial{}, fmt.Errorf("request certificate from cert-manager: %w", err)
+	}
+
+	return issuedMaterial{
+		certPEM: leafCertPEM,
+		keyPEM:  keyPEM,
+		caPEM:   caPEM,
+	}, nil
+}
+
+func readCertificateFiles(cfg config.Config) (issuedMaterial, error) {
+	certPEM, err := os.ReadFile(cfg.CertFile)
+	if err != nil {
+		return issuedMaterial{}, fmt.Errorf("read cert file: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return issuedMaterial{}, fmt.Errorf("read key file: %w", err)
 	}
 
-	tlsConfig, err := proxy.BuildTLSFromIssuedMaterial(leafCertPEM, keyPEM, caPEM)
+	caPEM, err := os.ReadFile(cfg.CAFile)
 	if err != nil {
-		return nil, fmt.Errorf("build tls config from issued certificate: %w", err)
+		return issuedMaterial{}, fmt.Errorf("read ca file: %w", err)
 	}
 
-	return tlsConfig, nil
+	return issuedMaterial{
+		certPEM: certPEM,
+		keyPEM:  keyPEM,
+		caPEM:   caPEM,
+	}, nil
 }
 
 func generateCSR(cfg config.Config) ([]byte, []byte, error) {
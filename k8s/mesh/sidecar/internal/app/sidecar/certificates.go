@@ -0,0 +1,241 @@
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/certmanager"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+)
+
+const (
+	certRotationStatusHealthy = 0
+	certRotationStatusFailing = 1
+)
+
+type certificateManager struct {
+	cfg      config.Config
+	client   *certmanager.Client
+	recorder *metrics.Recorder
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	leaf        *x509.Certificate
+	caPEM       []byte
+}
+
+func newCertificateManager(cfg config.Config, recorder *metrics.Recorder) *certificateManager {
+	return &certificateManager{
+		cfg:      cfg,
+		client:   certmanager.NewClient(cfg.CertManagerSignURL, nil),
+		recorder: recorder,
+	}
+}
+
+func (m *certificateManager) Bootstrap(ctx context.Context) (*tls.Config, error) {
+	material, err := issueCertificate(ctx, m.cfg, m.client)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.apply(material); err != nil {
+		return nil, fmt.Errorf("apply issued certificate: %w", err)
+	}
+
+	tlsConfig, err := proxy.BuildRotatingTLS(m, material.caPEM, m.cfg.TrustDomain)
+	if err != nil {
+		return nil, fmt.Errorf("build tls config from issued certificate: %w", err)
+	}
+
+	m.recorder.SetCertificateRotationStatus(certRotationStatusHealthy)
+	return tlsConfig, nil
+}
+
+func (m *certificateManager) Certificate() (*tls.Certificate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.certificate == nil {
+		return nil, fmt.Errorf("workload certificate is not issued yet")
+	}
+
+	return m.certificate, nil
+}
+
+func (m *certificateManager) Run(ctx context.Context) error {
+	var retryIn time.Duration
+	for {
+		waitFor := m.nextRotationDelay()
+		if retryIn > 0 {
+			waitFor = retryIn
+		}
+
+		timer := time.NewTimer(waitFor)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if err := m.rotate(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			retryIn = m.nextBackoff(retryIn)
+			m.recorder.ObserveCertificateRotation("failure")
+			m.recorder.SetCertificateRotationStatus(certRotationStatusFailing)
+			slog.Warn(
+				"certificate rotation failed",
+				slog.Duration("retry_in", retryIn),
+				slog.Time("expires_at", m.expiresAt()),
+				slog.Any("error", err),
+			)
+			continue
+		}
+
+		retryIn = 0
+		m.recorder.ObserveCertificateRotation("success")
+		m.recorder.SetCertificateRotationStatus(certRotationStatusHealthy)
+	}
+}
+
+func (m *certificateManager) rotate(ctx context.Context) error {
+	material, err := issueCertificate(ctx, m.cfg, m.client)
+	if err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	current := m.leaf
+	caChanged := !bytes.Equal(bytes.TrimSpace(m.caPEM), bytes.TrimSpace(material.caPEM))
+	m.mu.RUnlock()
+
+	// Mounted certificate files may not be refreshed yet; reinstalling the
+	// same leaf past its rotation point would retry without any delay.
+	if block, _ := pem.Decode(material.certPEM); block != nil && current != nil && bytes.Equal(block.Bytes, current.Raw) {
+		return fmt.Errorf("certificate is unchanged since the last rotation")
+	}
+
+	if caChanged {
+		slog.Warn("certificate authority changed during rotation; trust bundle is applied on restart only")
+	}
+
+	return m.apply(material)
+}
+
+func (m *certificateManager) apply(material issuedMaterial) error {
+	certificate, err := tls.X509KeyPair(material.certPEM, material.keyPEM)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("parse leaf certificate: %w", err)
+	}
+	certificate.Leaf = leaf
+
+	m.mu.Lock()
+	m.certificate = &certificate
+	m.leaf = leaf
+	if m.caPEM == nil {
+		m.caPEM = material.caPEM
+	}
+	m.mu.Unlock()
+
+	m.recorder.SetCertificateExpiry(leaf.NotAfter)
+	slog.Info(
+		"workload certificate installed",
+		slog.String("subject", leaf.Subject.CommonName),
+		slog.Time("not_before", leaf.NotBefore),
+		slog.Time("expires_at", leaf.NotAfter),
+	)
+
+	return nil
+}
+
+func (m *certificateManager) nextRotationDelay() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.leaf == nil {
+		return 0
+	}
+
+	lifetime := m.leaf.NotAfter.Sub(m.leaf.NotBefore)
+	rotateAt := m.leaf.NotBefore.Add(time.Duration(float64(lifetime) * m.cfg.CertRotationFraction))
+
+	delay := time.Until(rotateAt)
+	if delay < 0 {
+		return 0
+	}
+
+	return delay
+}
+
+func (m *certificateManager) nextBackoff(current time.Duration) time.Duration {
+	if current <= 0 {
+		return m.cfg.CertRotationBackoff
+	}
+
+	next := current * 2
+	if next > m.cfg.CertRotationMaxBackoff {
+		return m.cfg.CertRotationMaxBackoff
+	}
+
+	return next
+}
+
+func (m *certificateManager) expiresAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.leaf == nil {
+		return time.Time{}
+	}
+
+	return m.leaf.NotAfter
+}
+
+type certificateInfo struct {
+	Subject      string
+	Issuer       string
+	SerialNumber string
+	URIs         []string
+	NotBefore    time.Time
+	NotAfter     time.Time
+}
+
+func (m *certificateManager) info() (certificateInfo, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.leaf == nil {
+		return certificateInfo{}, false
+	}
+
+	uris := make([]string, 0, len(m.leaf.URIs))
+	for _, uri := range m.leaf.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	return certificateInfo{
+		Subject:      m.leaf.Subject.String(),
+		Issuer:       m.leaf.Issuer.String(),
+		SerialNumber: m.leaf.SerialNumber.String(),
+		URIs:         uris,
+		NotBefore:    m.leaf.NotBefore,
+		NotAfter:     m.leaf.NotAfter,
+	}, true
+}
@@ -0,0 +1,147 @@
+package sidecar
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+)
+
+func TestCertificateManagerRotateSwapsKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Config{
+		CertFile:               filepath.Join(dir, "tls.crt"),
+		KeyFile:                filepath.Join(dir, "tls.key"),
+		CAFile:                 filepath.Join(dir, "ca.crt"),
+		TrustDomain:            "cluster.local",
+		CertRotationFraction:   0.5,
+		CertRotationBackoff:    time.Second,
+		CertRotationMaxBackoff: 4 * time.Second,
+	}
+
+	writeSelfSignedPair(t, cfg, "first", time.Hour)
+
+	manager := newCertificateManager(cfg, metrics.NewRecorder())
+	tlsConfig, err := manager.Bootstrap(context.Background())
+	if err != nil {
+		t.Fatalf("Bootstrap() error = %v", err)
+	}
+
+	if tlsConfig.GetCertificate == nil || tlsConfig.GetClientCertificate == nil {
+		t.Fatal("expected tls config to resolve certificates dynamically")
+	}
+
+	delay := manager.nextRotationDelay()
+	if delay <= 0 || delay > 31*time.Minute {
+		t.Fatalf("nextRotationDelay() = %s, want about half of the lifetime", delay)
+	}
+
+	writeSelfSignedPair(t, cfg, "second", time.Hour)
+	if err := manager.rotate(context.Background()); err != nil {
+		t.Fatalf("rotate() error = %v", err)
+	}
+
+	certificate, err := tlsConfig.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+
+	if certificate.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("served certificate CN = %q, want %q", certificate.Leaf.Subject.CommonName, "second")
+	}
+}
+
+func TestCertificateManagerRotateRejectsUnchangedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Config{
+		CertFile:               filepath.Join(dir, "tls.crt"),
+		KeyFile:                filepath.Join(dir, "tls.key"),
+		CAFile:                 filepath.Join(dir, "ca.crt"),
+		TrustDomain:            "cluster.local",
+		CertRotationFraction:   0.5,
+		CertRotationBackoff:    time.Second,
+		CertRotationMaxBackoff: 4 * time.Second,
+	}
+
+	// The certificate on disk is already past its rotation point.
+	writeSelfSignedPair(t, cfg, "stale", time.Millisecond)
+
+	manager := newCertificateManager(cfg, metrics.NewRecorder())
+	if _, err := manager.Bootstrap(context.Background()); err != nil {
+		t.Fatalf("Bootstrap() error = %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	if delay := manager.nextRotationDelay(); delay != 0 {
+		t.Fatalf("nextRotationDelay() = %s, want 0 for a stale certificate", delay)
+	}
+	if err := manager.rotate(context.Background()); err == nil {
+		t.Fatal("rotate() error = nil, want the unchanged certificate reported as a failure")
+	}
+
+	writeSelfSignedPair(t, cfg, "fresh", time.Hour)
+	if err := manager.rotate(context.Background()); err != nil {
+		t.Fatalf("rotate() error = %v", err)
+	}
+}
+
+func TestCertificateManagerBackoffIsCapped(t *testing.T) {
+	manager := &certificateManager{cfg: config.Config{
+		CertRotationBackoff:    time.Second,
+		CertRotationMaxBackoff: 3 * time.Second,
+	}}
+
+	var current time.Duration
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	for i, expected := range want {
+		current = manager.nextBackoff(current)
+		if current != expected {
+			t.Fatalf("nextBackoff() step %d = %s, want %s", i, current, expected)
+		}
+	}
+}
+
+func writeSelfSignedPair(t *testing.T, cfg config.Config, commonName string, ttl time.Duration) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now,
+		NotAfter:              now.Add(ttl),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	for path, content := range map[string][]byte{cfg.CertFile: certPEM, cfg.KeyFile: keyPEM, cfg.CAFile: certPEM} {
+		if err := os.WriteFile(path, content, 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+}
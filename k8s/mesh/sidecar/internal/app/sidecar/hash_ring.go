@@ -0,0 +1,115 @@
+package sidecar
+
+import (
+	"hash/fnv"
+	"slices"
+	"strconv"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+const hashRingReplicas = 128
+
+type ringPoint struct {
+	hash     uint64
+	endpoint string
+}
+
+type hashRing struct {
+	members map[string]domain.Endpoint
+	points  []ringPoint
+}
+
+func newHashRing() *hashRing {
+	return &hashRing{members: make(map[string]domain.Endpoint)}
+}
+
+// sync applies endpoint changes in place: points of surviving endpoints stay
+// where they are, so only keys owned by added or removed endpoints move.
+func (r *hashRing) sync(endpoints []domain.Endpoint) {
+	current := make(map[string]domain.Endpoint, len(endpoints))
+	for _, endpoint := range endpoints {
+		current[endpointAddr(endpoint)] = endpoint
+	}
+
+	removed := false
+	for addr := range r.members {
+		if _, ok := current[addr]; !ok {
+			delete(r.members, addr)
+			removed = true
+		}
+	}
+
+	if removed {
+		r.points = slices.DeleteFunc(r.points, func(point ringPoint) bool {
+			_, ok := r.members[point.endpoint]
+			return !ok
+		})
+	}
+
+	added := false
+	for addr, endpoint := range current {
+		if _, ok := r.members[addr]; !ok {
+			for replica := range hashRingReplicas {
+				r.points = append(r.points, ringPoint{hash: hashKey(addr + "#" + strconv.Itoa(replica)), endpoint: addr})
+			}
+			added = true
+		}
+		r.members[addr] = endpoint
+	}
+
+	if added {
+		slices.SortFunc(r.points, func(a, b ringPoint) int {
+			switch {
+			case a.hash < b.hash:
+				return -1
+			case a.hash > b.hash:
+				return 1
+			default:
+				return 0
+			}
+		})
+	}
+}
+
+// get returns the first endpoint clockwise from key that allowed accepts.
+func (r *hashRing) get(key string, allowed func(addr string) bool) (domain.Endpoint, bool) {
+	if len(r.points) == 0 {
+		return domain.Endpoint{}, false
+	}
+
+	hash := hashKey(key)
+	idx, _ := slices.BinarySearchFunc(r.points, hash, func(point ringPoint, target uint64) int {
+		switch {
+		case point.hash < target:
+			return -1
+		case point.hash > target:
+			return 1
+		default:
+			return 0
+		}
+	})
+	for offset := range len(r.points) {
+		point := r.points[(idx+offset)%len(r.points)]
+		if allowed == nil || allowed(point.endpoint) {
+			return r.members[point.endpoint], true
+		}
+	}
+
+	return domain.Endpoint{}, false
+}
+
+func hashKey(key string) uint64 {
+	hasher := fnv.New64a()
+	_, _ = hasher.Write([]byte(key))
+
+	// FNV clusters similar inputs such as "ip#1", "ip#2"; the 64-bit murmur
+	// finalizer spreads them evenly over the ring.
+	hash := hasher.Sum64()
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	hash *= 0xc4ceb9fe1a85ec53
+	hash ^= hash >> 33
+	return hash
+}
@@ -0,0 +1,51 @@
+package sidecar
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func TestHashRingMovesOnlyKeysOfChangedEndpoints(t *testing.T) {
+	endpoints := make([]domain.Endpoint, 0, 5)
+	for i := 1; i <= 5; i++ {
+		endpoints = append(endpoints, domain.Endpoint{IP: "10.0.0." + strconv.Itoa(i), Port: 9080})
+	}
+
+	ring := newHashRing()
+	ring.sync(endpoints)
+
+	const keys = 10000
+	before := make([]string, keys)
+	for i := range keys {
+		endpoint, _ := ring.get("user-"+strconv.Itoa(i), nil)
+		before[i] = endpoint.IP
+	}
+
+	ring.sync(append(endpoints, domain.Endpoint{IP: "10.0.0.6", Port: 9080}))
+
+	moved := 0
+	for i := range keys {
+		endpoint, _ := ring.get("user-"+strconv.Itoa(i), nil)
+		if endpoint.IP == before[i] {
+			continue
+		}
+		if endpoint.IP != "10.0.0.6" {
+			t.Fatalf("key moved from %s to %s, want moves only to the added endpoint", before[i], endpoint.IP)
+		}
+		moved++
+	}
+
+	// About 1/6 of the keys should move to the new endpoint.
+	if moved < keys/12 || moved > keys/4 {
+		t.Fatalf("moved %d of %d keys, want roughly %d", moved, keys, keys/6)
+	}
+
+	ring.sync(endpoints)
+	for i := range keys {
+		if endpoint, _ := ring.get("user-"+strconv.Itoa(i), nil); endpoint.IP != before[i] {
+			t.Fatalf("key %d maps to %s after removal, want %s", i, endpoint.IP, before[i])
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package sidecar
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+const (
+	healthCheckTick      = time.Second
+	grpcHealthServing    = 1
+	grpcHealthCheckPath  = "/grpc.health.v1.Health/Check"
+	healthCheckUserAgent = "mesh-sidecar-health-check"
+)
+
+type upstreamDialer interface {
+	Dial(ctx context.Context, targetAddr string, serverName string, identity string, destinationPort int) (net.Conn, error)
+}
+
+type healthState struct {
+	target    domain.HealthCheckTarget
+	unhealthy bool
+	successes int
+	failures  int
+	nextCheck time.Time
+	running   bool
+}
+
+type healthChecker struct {
+	cache           *discovery.ServiceCache
+	dialer          upstreamDialer
+	defaults        config.HealthCheckConfig
+	inboundMTLSPort int
+	mtlsEnabled     bool
+	recorder        *metrics.Recorder
+
+	mu     sync.Mutex
+	states map[string]*healthState
+	rnd    *mathrand.Rand
+}
+
+func newHealthChecker(
+	cache *discovery.ServiceCache,
+	dialer upstreamDialer,
+	defaults config.HealthCheckConfig,
+	inboundMTLSPort int,
+	mtlsEnabled bool,
+	recorder *metrics.Recorder,
+) *healthChecker {
+	return &healthChecker{
+		cache:           cache,
+		dialer:          dialer,
+		defaults:        defaults,
+		inboundMTLSPort: inboundMTLSPort,
+		mtlsEnabled:     mtlsEnabled,
+		recorder:        recorder,
+		states:          make(map[string]*healthState),
+		rnd:             mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (h *healthChecker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(healthCheckTick)
+	defer ticker.Stop()
+
+	for {
+		h.schedule(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthChecker) healthy(endpoints []domain.Endpoint) []domain.Endpoint {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	healthy := make([]domain.Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if state, ok := h.states[endpointAddr(endpoint)]; ok && state.unhealthy {
+			continue
+		}
+		healthy = append(healthy, endpoint)
+	}
+
+	// With every endpoint failing the checks are more likely wrong than the service.
+	if len(healthy) == 0 {
+		return endpoints
+	}
+
+	return healthy
+}
+
+func (h *healthChecker) schedule(ctx context.Context, now time.Time) {
+	targets := h.cache.HealthCheckTargets()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seen := make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		target.Policy = h.resolve(target.Policy)
+		if target.Policy.Type == domain.HealthCheckNone {
+			continue
+		}
+
+		// Endpoints shared by several services or cluster IP aliases are probed once.
+		key := endpointAddr(target.Endpoint)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		state, ok := h.states[key]
+		if !ok {
+			// A random first probe spreads the probes of many sidecars over the interval.
+			state = &healthState{nextCheck: now.Add(time.Duration(h.rnd.Int63n(int64(target.Policy.Interval))))}
+			h.states[key] = state
+		}
+		state.target = target
+
+		if state.running || now.Before(state.nextCheck) {
+			continue
+		}
+
+		state.running = true
+		go h.probe(ctx, key, target)
+	}
+
+	for key, state := range h.states {
+		if _, ok := seen[key]; !ok && !state.running {
+			delete(h.states, key)
+		}
+	}
+
+	unhealthy := make(map[string]int)
+	for _, state := range h.states {
+		service := state.target.Endpoint.ServiceName
+		count := unhealthy[service]
+		if state.unhealthy {
+			count++
+		}
+		unhealthy[service] = count
+	}
+	for service, count := range unhealthy {
+		h.recorder.SetEndpointsUnhealthy(service, count)
+	}
+}
+
+func (h *healthChecker) resolve(policy domain.HealthCheckPolicy) domain.HealthCheckPolicy {
+	if policy.Type == "" {
+		policy.Type = domain.HealthCheckType(h.defaults.Type)
+	}
+	if policy.Path == "" {
+		policy.Path = h.defaults.Path
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = h.defaults.Interval
+	}
+
+	return policy
+}
+
+func (h *healthChecker) probe(ctx context.Context, key string, target domain.HealthCheckTarget) {
+	checkCtx, cancel := context.WithTimeout(ctx, h.defaults.Timeout)
+	err := h.check(checkCtx, target)
+	cancel()
+
+	h.record(key, target, err, time.Now())
+}
+
+func (h *healthChecker) record(key string, target domain.HealthCheckTarget, err error, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.states[key]
+	if !ok {
+		return
+	}
+
+	state.running = false
+	state.nextCheck = now.Add(h.jittered(target.Policy.Interval))
+
+	if err != nil {
+		h.recorder.ObserveHealthCheck(target.Endpoint.ServiceName, "failure")
+		state.successes = 0
+		state.failures++
+		if !state.unhealthy && state.failures >= h.defaults.UnhealthyThreshold {
+			state.unhealthy = true
+			slog.Warn(
+				"endpoint marked unhealthy by active health check",
+				slog.String("endpoint", key),
+				slog.String("service", target.Endpoint.ServiceName),
+				slog.Any("error", err),
+			)
+		}
+		return
+	}
+
+	h.recorder.ObserveHealthCheck(target.Endpoint.ServiceName, "success")
+	state.failures = 0
+	state.successes++
+	if state.unhealthy && state.successes >= h.defaults.HealthyThreshold {
+		state.unhealthy = false
+		slog.Info("endpoint marked healthy by active health check", slog.String("endpoint", key), slog.String("service", target.Endpoint.ServiceName))
+	}
+}
+
+func (h *healthChecker) jittered(interval time.Duration) time.Duration {
+	spread := float64(interval) * h.defaults.Jitter
+	return interval + time.Duration((h.rnd.Float64()*2-1)*spread)
+}
+
+func (h *healthChecker) check(ctx context.Context, target domain.HealthCheckTarget) error {
+	port := target.Policy.Port
+	if port == 0 {
+		port = target.Endpoint.Port
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if h.mtlsEnabled && target.Endpoint.Meshed {
+		conn, err = h.dialer.Dial(ctx, net.JoinHostPort(target.Endpoint.IP, strconv.Itoa(h.inboundMTLSPort)), target.Endpoint.ServiceName, target.Endpoint.Identity, port)
+	} else {
+		conn, err = h.dialer.Dial(ctx, net.JoinHostPort(target.Endpoint.IP, strconv.Itoa(port)), "", "", 0)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	switch target.Policy.Type {
+	case domain.HealthCheckHTTP:
+		return checkHTTP(ctx, conn, target.Endpoint.ServiceName, target.Policy.Path)
+	case domain.HealthCheckGRPC:
+		return checkGRPC(ctx, conn, target.Endpoint.ServiceName, target.Policy.GRPCService)
+	default:
+		return nil
+	}
+}
+
+func checkHTTP(ctx context.Context, conn net.Conn, host string, path string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("User-Agent", healthCheckUserAgent)
+	request.Close = true
+
+	if err := request.Write(conn); err != nil {
+		return err
+	}
+
+	response, err := http.ReadResponse(bufio.NewReader(conn), request)
+	if err != nil {
+		return err
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("health check returned status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+func checkGRPC(ctx context.Context, conn net.Conn, host string, service string) error {
+	clientConn, err := (&http2.Transport{AllowHTTP: true}).NewClientConn(conn)
+	if err != nil {
+		return err
+	}
+	defer clientConn.Close()
+
+	message := []byte{}
+	if service != "" {
+		message = append(message, 0x0a)
+		message = binary.AppendUvarint(message, uint64(len(service)))
+		message = append(message, service...)
+	}
+
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	frame = append(frame, message...)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+host+grpcHealthCheckPath, bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/grpc")
+	request.Header.Set("TE", "trailers")
+	request.Header.Set("User-Agent", healthCheckUserAgent)
+
+	response, err := clientConn.RoundTrip(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<16))
+	if err != nil {
+		return err
+	}
+
+	// A trailers-only response carries grpc-status in the headers.
+	status := response.Trailer.Get("Grpc-Status")
+	if status == "" {
+		status = response.Header.Get("Grpc-Status")
+	}
+	if status != "0" {
+		return fmt.Errorf("health check returned grpc status %q", status)
+	}
+
+	if serving := grpcServingStatus(body); serving != grpcHealthServing {
+		return fmt.Errorf("health check returned serving status %d", serving)
+	}
+
+	return nil
+}
+
+func grpcServingStatus(body []byte) uint64 {
+	if len(body) < 5 {
+		return 0
+	}
+
+	message := body[5:]
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		if n <= 0 || tag&0x7 != 0 {
+			return 0
+		}
+		message = message[n:]
+
+		value, n := binary.Uvarint(message)
+		if n <= 0 {
+			return 0
+		}
+		message = message[n:]
+
+		if tag>>3 == 1 {
+			return value
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,128 @@
+package sidecar
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+type staticDialer struct {
+	addr string
+}
+
+func (d staticDialer) Dial(ctx context.Context, _ string, _ string, _ string, _ int) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", d.addr)
+}
+
+func TestHealthCheckerMarksEndpointsByThresholds(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" || failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker := newHealthChecker(
+		discovery.NewServiceCache(nil),
+		staticDialer{addr: server.Listener.Addr().String()},
+		config.HealthCheckConfig{Timeout: time.Second, HealthyThreshold: 2, UnhealthyThreshold: 2},
+		15001,
+		false,
+		metrics.NewRecorder(),
+	)
+
+	endpoints := []domain.Endpoint{
+		{IP: "10.0.0.1", Port: 9080, ServiceName: "reviews"},
+		{IP: "10.0.0.2", Port: 9080, ServiceName: "reviews"},
+	}
+	target := domain.HealthCheckTarget{
+		Endpoint: endpoints[0],
+		Policy:   domain.HealthCheckPolicy{Type: domain.HealthCheckHTTP, Path: "/ready", Interval: time.Second},
+	}
+	key := endpointAddr(endpoints[0])
+	checker.states[key] = &healthState{target: target}
+
+	probe := func() {
+		checker.record(key, target, checker.check(context.Background(), target), time.Now())
+	}
+
+	failing.Store(true)
+	probe()
+	if got := checker.healthy(endpoints); len(got) != 2 {
+		t.Fatalf("healthy() after one failure = %v, want both endpoints", got)
+	}
+
+	probe()
+	if got := checker.healthy(endpoints); len(got) != 1 || got[0].IP != "10.0.0.2" {
+		t.Fatalf("healthy() after unhealthy threshold = %v, want only 10.0.0.2", got)
+	}
+
+	failing.Store(false)
+	probe()
+	if got := checker.healthy(endpoints); len(got) != 1 {
+		t.Fatalf("healthy() after one success = %v, endpoint must stay ejected", got)
+	}
+
+	probe()
+	if got := checker.healthy(endpoints); len(got) != 2 {
+		t.Fatalf("healthy() after healthy threshold = %v, want both endpoints", got)
+	}
+}
+
+func TestCheckGRPCReadsServingStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  byte
+		wantErr bool
+	}{
+		{name: "serving", status: 1},
+		{name: "not serving", status: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != grpcHealthCheckPath || r.Header.Get("Content-Type") != "application/grpc" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/grpc")
+				w.Header().Set("Trailer", "Grpc-Status")
+				_, _ = w.Write([]byte{0, 0, 0, 0, 2, 0x08, tt.status})
+				w.Header().Set("Grpc-Status", "0")
+			}))
+			server.Config.Protocols = new(http.Protocols)
+			server.Config.Protocols.SetUnencryptedHTTP2(true)
+			server.Start()
+			defer server.Close()
+
+			conn, err := net.Dial("tcp", server.Listener.Addr().String())
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			err = checkGRPC(ctx, conn, "reviews:9080", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkGRPC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package sidecar
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/accesslog"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+type accessLogMiddleware struct {
+	logger *accesslog.Logger
+}
+
+func newAccessLogMiddleware(logger *accesslog.Logger) *accessLogMiddleware {
+	return &accessLogMiddleware{logger: logger}
+}
+
+func (m *accessLogMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	started := time.Now()
+	err := next(ctx)
+
+	request, isRequest := ctx.Metadata[domain.MetadataHTTPRequest].(*http.Request)
+	if !isRequest && domain.Protocol(ctx.GetString(domain.MetadataProtocol)).IsHTTP() {
+		// Requests of an HTTP connection are logged one by one.
+		return err
+	}
+
+	service := ctx.GetString(domain.MetadataService)
+	if !m.logger.Enabled(service) {
+		return err
+	}
+
+	entry := accesslog.NewEntry(started)
+	entry.Type = "tcp"
+	entry.Listener = ctx.GetString(domain.MetadataListener)
+	entry.Direction = ctx.GetString(domain.MetadataDirection)
+	entry.OriginalDst = ctx.OriginalDst
+	entry.Upstream = ctx.GetString(domain.MetadataTargetAddr)
+	entry.Service = service
+	entry.PeerIdentity = ctx.GetString(domain.MetadataPeerIdentity)
+	entry.Protocol = ctx.GetString(domain.MetadataProtocol)
+	entry.BytesReceived = ctx.GetInt(domain.MetadataBytesReceived)
+	entry.BytesSent = ctx.GetInt(domain.MetadataBytesSent)
+	entry.RetryAttempts = ctx.GetInt(domain.MetadataRetryCount)
+	if isRequest {
+		entry.Type = "http"
+		entry.Protocol = request.Proto
+		entry.Method = request.Method
+		entry.Path = request.URL.Path
+		entry.ResponseCode = ctx.GetString(domain.MetadataStatusCode)
+		entry.GRPCStatus = ctx.GetString(domain.MetadataGRPCStatus)
+	}
+	if err != nil {
+		entry.ErrorKind = domain.NormalizeErrorType(err)
+	}
+
+	m.logger.Log(entry)
+	return err
+}
@@ -0,0 +1,118 @@
+package sidecar
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/policy"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+type authorizationMiddleware struct {
+	policies *policy.AuthorizationStore
+	recorder *metrics.Recorder
+}
+
+func newAuthorizationMiddleware(policies *policy.AuthorizationStore, recorder *metrics.Recorder) *authorizationMiddleware {
+	return &authorizationMiddleware{
+		policies: policies,
+		recorder: recorder,
+	}
+}
+
+func (m *authorizationMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	if ctx.GetString(domain.MetadataDirection) != string(domain.DirectionInbound) {
+		return next(ctx)
+	}
+
+	request := policy.AuthorizationRequest{
+		Principal: ctx.GetString(domain.MetadataPeerIdentity),
+		Port:      targetPort(ctx.GetString(domain.MetadataTargetAddr)),
+	}
+
+	if m.policies.RequiresHTTP() {
+		ctx.Set(domain.MetadataRequestAuthorizer, domain.RequestAuthorizer(func(httpRequest *http.Request) error {
+			current := request
+			if httpRequest != nil {
+				path, err := authorizationPath(httpRequest.URL)
+				if err != nil {
+					m.recorder.IncAuthorizationDenied("")
+					slog.Debug("inbound request with ambiguous path denied", slog.Any("error", err))
+					return domain.Wrap(domain.ErrorKindForbidden, err)
+				}
+
+				current.HTTP = true
+				current.Method = httpRequest.Method
+				current.Path = path
+			}
+
+			return m.authorize(current)
+		}))
+		return next(ctx)
+	}
+
+	if err := m.authorize(request); err != nil {
+		proxy.RejectForbidden(ctx.ClientConn)
+		return err
+	}
+
+	return next(ctx)
+}
+
+func (m *authorizationMiddleware) authorize(request policy.AuthorizationRequest) error {
+	decision := m.policies.Evaluate(request)
+	if decision.Allowed {
+		return nil
+	}
+
+	m.recorder.IncAuthorizationDenied(decision.Policy)
+	slog.Debug(
+		"inbound request denied by authorization policy",
+		slog.String("principal", request.Principal),
+		slog.Int("port", request.Port),
+		slog.String("method", request.Method),
+		slog.String("path", request.Path),
+		slog.String("policy", decision.Policy),
+	)
+
+	return domain.Wrap(domain.ErrorKindForbidden, fmt.Errorf("peer %q is not allowed to reach port %d", request.Principal, request.Port))
+}
+
+// authorizationPath returns the path rules are matched against. Dot segments
+// and repeated slashes are resolved, so /a/../admin/x still matches /admin/*;
+// encoded slashes and dots are refused because the application may decode them.
+func authorizationPath(requestURL *url.URL) (string, error) {
+	escaped := strings.ToLower(requestURL.EscapedPath())
+	if strings.Contains(escaped, "%2f") || strings.Contains(escaped, "%2e") {
+		return "", fmt.Errorf("path %q contains an encoded slash or dot", requestURL.EscapedPath())
+	}
+
+	cleaned := path.Clean("/" + requestURL.Path)
+	if strings.HasSuffix(requestURL.Path, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned, nil
+}
+
+func targetPort(targetAddr string) int {
+	_, rawPort, err := net.SplitHostPort(targetAddr)
+	if err != nil {
+		return 0
+	}
+
+	port, err := strconv.Atoi(rawPort)
+	if err != nil {
+		return 0
+	}
+
+	return port
+}
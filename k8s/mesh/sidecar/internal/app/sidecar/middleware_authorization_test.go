@@ -0,0 +1,46 @@
+package sidecar
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestAuthorizationPathResolvesAmbiguousPaths(t *testing.T) {
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{target: "/admin/x", want: "/admin/x"},
+		{target: "//admin/x", want: "/admin/x"},
+		{target: "/./admin/x", want: "/admin/x"},
+		{target: "/a/../admin/x", want: "/admin/x"},
+		{target: "/../admin/", want: "/admin/"},
+		{target: "/", want: "/"},
+		{target: "/admin%2Fx", want: ""},
+		{target: "/%2e%2e/admin/x", want: ""},
+	}
+
+	for _, tt := range tests {
+		requestURL, err := url.ParseRequestURI(tt.target)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.target, err)
+		}
+
+		got, err := authorizationPath(requestURL)
+		if tt.want == "" {
+			if err == nil {
+				t.Fatalf("authorizationPath(%q) = %q, want an error", tt.target, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("authorizationPath(%q) = %q, %v, want %q", tt.target, got, err, tt.want)
+		}
+	}
+
+	request, _ := http.NewRequest(http.MethodGet, "http://reviews:9080//admin/x", nil)
+	if got, _ := authorizationPath(request.URL); got != "/admin/x" {
+		t.Fatalf("authorizationPath(%q) = %q, want /admin/x", request.URL, got)
+	}
+}
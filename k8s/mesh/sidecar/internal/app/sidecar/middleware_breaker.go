@@ -3,10 +3,13 @@ package sidecar
 import (
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
 
@@ -24,31 +27,30 @@ type breakerEntry struct {
 }
 
 type breakerMiddleware struct {
-	failureThreshold uint32
-	recoveryTime     time.Duration
-	recorder         *metrics.Recorder
+	policies *trafficPolicies
+	recorder *metrics.Recorder
 
 	mu      sync.Mutex
 	entries map[string]*breakerEntry
 }
 
-func newBreakerMiddleware(failureThreshold uint32, recoveryTime time.Duration, recorder *metrics.Recorder) *breakerMiddleware {
+func newBreakerMiddleware(policies *trafficPolicies, recorder *metrics.Recorder) *breakerMiddleware {
 	return &breakerMiddleware{
-		failureThreshold: failureThreshold,
-		recoveryTime:     recoveryTime,
-		recorder:         recorder,
-		entries:          make(map[string]*breakerEntry),
+		policies: policies,
+		recorder: recorder,
+		entries:  make(map[string]*breakerEntry),
 	}
 }
 
 func (m *breakerMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	policy := m.policies.For(ctx).CircuitBreakerPolicy
 	key := ctx.GetString(domain.MetadataBreakerKey)
-	if key == "" {
+	if key == "" || policy.FailureThreshold == 0 {
 		return next(ctx)
 	}
 
 	service := ctx.GetString(domain.MetadataService)
-	if err := m.allow(key, service); err != nil {
+	if err := m.allow(key, service, policy); err != nil {
 		return err
 	}
 
@@ -59,13 +61,13 @@ func (m *breakerMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc
 	}
 
 	if domain.IsEstablishError(err) {
-		m.recordFailure(key, service)
+		m.recordFailure(key, service, policy)
 	}
 
 	return err
 }
 
-func (m *breakerMiddleware) allow(key string, service string) error {
+func (m *breakerMiddleware) allow(key string, service string, policy config.CircuitBreakerPolicy) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -74,7 +76,7 @@ func (m *breakerMiddleware) allow(key string, service string) error {
 
 	switch entry.state {
 	case breakerStateOpen:
-		if now.Sub(entry.openedAt) < m.recoveryTime {
+		if now.Sub(entry.openedAt) < policy.RecoveryTime {
 			slog.Warn("circuit breaker rejected request", slog.String("key", key), slog.Int("state", entry.state))
 			return domain.Wrap(domain.ErrorKindBreakerOpen, fmt.Errorf("circuit breaker is open for %s", key))
 		}
@@ -111,7 +113,7 @@ func (m *breakerMiddleware) recordSuccess(key string, service string) {
 	}
 }
 
-func (m *breakerMiddleware) recordFailure(key string, service string) {
+func (m *breakerMiddleware) recordFailure(key string, service string, policy config.CircuitBreakerPolicy) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -123,7 +125,7 @@ func (m *breakerMiddleware) recordFailure(key string, service string) {
 	case breakerStateHalf:
 		entry.state = breakerStateOpen
 		entry.openedAt = now
-		entry.failures = m.failureThreshold
+		entry.failures = policy.FailureThreshold
 		entry.trialInFlight = false
 		m.recorder.SetCircuitBreakerState(service, breakerStateOpen)
 		slog.Info("circuit breaker transition", slog.String("key", key), slog.Int("from", previousState), slog.Int("to", breakerStateOpen), slog.Uint64("failures", uint64(entry.failures)))
@@ -136,7 +138,7 @@ func (m *breakerMiddleware) recordFailure(key string, service string) {
 	}
 
 	entry.failures++
-	if entry.failures >= m.failureThreshold {
+	if entry.failures >= policy.FailureThreshold {
 		entry.state = breakerStateOpen
 		entry.openedAt = now
 		entry.trialInFlight = false
@@ -159,3 +161,40 @@ func (m *breakerMiddleware) getOrCreateEntry(key string, service string) *breake
 	m.recorder.SetCircuitBreakerState(service, breakerStateClosed)
 	return entry
 }
+
+type breakerStatus struct {
+	Key      string
+	State    string
+	Failures uint32
+	OpenedAt time.Time `json:",omitzero"`
+}
+
+func (m *breakerMiddleware) snapshot() []breakerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]breakerStatus, 0, len(m.entries))
+	for key, entry := range m.entries {
+		status := breakerStatus{Key: key, State: breakerStateName(entry.state), Failures: entry.failures}
+		if entry.state != breakerStateClosed {
+			status.OpenedAt = entry.openedAt
+		}
+		statuses = append(statuses, status)
+	}
+	slices.SortFunc(statuses, func(a, b breakerStatus) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return statuses
+}
+
+func breakerStateName(state int) string {
+	switch state {
+	case breakerStateOpen:
+		return "open"
+	case breakerStateHalf:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
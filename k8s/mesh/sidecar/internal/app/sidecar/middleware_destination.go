@@ -0,0 +1,28 @@
+package sidecar
+
+import (
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+// destinationMiddleware names the destination service before routing, so
+// timeout and retry can look up its traffic policy.
+type destinationMiddleware struct {
+	cache *discovery.ServiceCache
+}
+
+func newDestinationMiddleware(cache *discovery.ServiceCache) *destinationMiddleware {
+	return &destinationMiddleware{cache: cache}
+}
+
+func (m *destinationMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	if ctx.GetString(domain.MetadataDirection) != string(domain.DirectionOutbound) {
+		return next(ctx)
+	}
+
+	if endpoints := m.cache.GetEndpoints(ctx.OriginalDst); len(endpoints) > 0 {
+		ctx.Set(domain.MetadataService, endpoints[0].ServiceName)
+	}
+
+	return next(ctx)
+}
@@ -0,0 +1,230 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+// maxRetryAfter bounds how long a Retry-After header may delay a retry; a
+// longer one is passed to the client instead.
+const maxRetryAfter = 10 * time.Second
+
+// httpRetryMiddleware retries a single HTTP request. Routing and the breaker
+// run again for every attempt, so a retry goes to another endpoint.
+type httpRetryMiddleware struct {
+	policies *trafficPolicies
+	budget   *retryBudget
+	recorder *metrics.Recorder
+}
+
+func newHTTPRetryMiddleware(policies *trafficPolicies, budget *retryBudget, recorder *metrics.Recorder) *httpRetryMiddleware {
+	return &httpRetryMiddleware{
+		policies: policies,
+		budget:   budget,
+		recorder: recorder,
+	}
+}
+
+func (m *httpRetryMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	request, ok := ctx.Metadata[domain.MetadataHTTPRequest].(*http.Request)
+	policy := m.policies.For(ctx).RetryPolicy
+	if !ok || policy.Attempts <= 1 {
+		return next(ctx)
+	}
+
+	// Every request counts towards the budget, including those never retried.
+	destination := ctx.GetString(domain.MetadataService)
+	m.budget.recordRequest(destination, policy.Budget)
+	if !retriesRequest(policy, request) {
+		return next(ctx)
+	}
+
+	body := proxy.BufferRequestBody(request, policy.BodyBufferLimit)
+	if body != nil {
+		defer body.Close()
+	}
+	defer delete(ctx.Metadata, domain.MetadataResponseRetrier)
+
+	parent := ctx.Context
+	for attempt := 1; ; attempt++ {
+		var (
+			responded bool
+			retry     bool
+			wait      time.Duration
+			reason    string
+		)
+		ctx.Set(domain.MetadataResponseRetrier, domain.ResponseRetrier(func(response *http.Response) bool {
+			responded = true
+			if attempt == policy.Attempts || body != nil && !body.Replayable() || !retriableResponse(policy, request, response) {
+				return false
+			}
+
+			retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After"))
+			if ok && retryAfter > maxRetryAfter {
+				return false
+			}
+
+			wait = max(jitter(retryBackoff(policy, attempt)), retryAfter)
+			reason = strconv.Itoa(response.StatusCode)
+			retry = fitsDeadline(parent, wait) && m.withinBudget(ctx, destination, policy)
+			return retry
+		}))
+
+		err := m.attempt(ctx, policy.PerTryTimeout, next)
+		if !responded && err != nil && attempt < policy.Attempts && parent.Err() == nil &&
+			(body == nil || body.Replayable()) && retriableError(policy, request) {
+			wait = jitter(retryBackoff(policy, attempt))
+			reason = domain.NormalizeErrorType(err)
+			retry = fitsDeadline(parent, wait) && m.withinBudget(ctx, destination, policy)
+		}
+		if !retry {
+			return err
+		}
+
+		service := ctx.GetString(domain.MetadataService)
+		m.recorder.IncRetry(service)
+		ctx.Set(domain.MetadataRetryCount, ctx.GetInt(domain.MetadataRetryCount)+1)
+		slog.Debug(
+			"http retry scheduled",
+			slog.String("service", service),
+			slog.Int("attempt", attempt),
+			slog.Int("max_attempts", policy.Attempts),
+			slog.String("reason", reason),
+			slog.Duration("backoff", wait),
+		)
+
+		select {
+		case <-parent.Done():
+			return domain.Wrap(domain.ErrorKindTimeout, parent.Err())
+		case <-time.After(wait):
+		}
+
+		delete(ctx.Metadata, domain.MetadataStatusCode)
+		if body != nil {
+			request.Body = body.NewReader()
+		}
+	}
+}
+
+func (m *httpRetryMiddleware) withinBudget(ctx *domain.ConnContext, destination string, policy config.RetryPolicy) bool {
+	if m.budget.allowRetry(destination, policy.Budget) {
+		return true
+	}
+
+	service := ctx.GetString(domain.MetadataService)
+	m.recorder.IncRetryBudgetExhausted(service)
+	slog.Debug("retry budget exhausted, returning the response", slog.String("service", service))
+	return false
+}
+
+func (m *httpRetryMiddleware) attempt(ctx *domain.ConnContext, perTryTimeout time.Duration, next domain.NextFunc) error {
+	if perTryTimeout <= 0 {
+		return next(ctx)
+	}
+
+	tryCtx, cancel := context.WithTimeout(ctx.Context, perTryTimeout)
+	defer cancel()
+
+	parent := ctx.Context
+	ctx.Context = tryCtx
+	err := next(ctx)
+	ctx.Context = parent
+	if err != nil && tryCtx.Err() != nil && parent.Err() == nil {
+		return domain.Wrap(domain.ErrorKindTimeout, fmt.Errorf("request attempt timed out after %s", perTryTimeout))
+	}
+
+	return err
+}
+
+// retriesRequest reports whether the request may be sent twice: idempotent
+// methods and requests with an Idempotency-Key are replay-safe, other gRPC
+// calls are retried only on UNAVAILABLE.
+func retriesRequest(policy config.RetryPolicy, request *http.Request) bool {
+	return replaySafe(request) || proxy.IsGRPCRequest(request) && slices.Contains(policy.RetryOn, "unavailable")
+}
+
+func replaySafe(request *http.Request) bool {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return request.Header.Get("Idempotency-Key") != ""
+	}
+}
+
+func retriableResponse(policy config.RetryPolicy, request *http.Request, response *http.Response) bool {
+	safe := replaySafe(request)
+	for _, condition := range policy.RetryOn {
+		switch condition {
+		case "5xx":
+			if safe && response.StatusCode >= 500 {
+				return true
+			}
+		case "gateway-error":
+			switch response.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				if safe {
+					return true
+				}
+			}
+		case "retriable-status-codes":
+			if safe && slices.Contains(policy.RetriableStatusCodes, response.StatusCode) {
+				return true
+			}
+		case "unavailable":
+			if proxy.IsGRPCUnavailable(response) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// retriableError covers attempts that failed before response headers: dial
+// and TLS failures, resets, per-try timeouts and an open breaker.
+func retriableError(policy config.RetryPolicy, request *http.Request) bool {
+	return replaySafe(request) && slices.Contains(policy.RetryOn, "reset")
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+
+	return 0, false
+}
+
+// jitter spreads a backoff over [d/2, 3d/2), so clients that failed together
+// do not retry together.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+
+	return d/2 + rand.N(d)
+}
+
+func fitsDeadline(ctx context.Context, wait time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > wait
+}
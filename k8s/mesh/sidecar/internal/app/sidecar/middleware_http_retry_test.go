@@ -0,0 +1,201 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func TestHTTPRetryResendsBodyToAnotherEndpoint(t *testing.T) {
+	const serviceAddr = "10.96.0.11:9080"
+
+	var unavailableHits atomic.Int32
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		unavailableHits.Add(1)
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer healthy.Close()
+
+	endpoints := make([]domain.Endpoint, 0, 2)
+	for _, backend := range []*httptest.Server{unavailable, healthy} {
+		host, rawPort, _ := net.SplitHostPort(backend.Listener.Addr().String())
+		port, _ := strconv.Atoi(rawPort)
+		endpoints = append(endpoints, domain.Endpoint{IP: host, Port: port, ServiceName: "ratings.bookinfo.svc.cluster.local"})
+	}
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{{ServiceKey: serviceAddr, Endpoints: endpoints}})
+
+	recorder := metrics.NewRecorder()
+	policies := newTrafficPolicies(config.TrafficPolicy{
+		LoadBalancerAlgorithm: "none",
+		RetryPolicy: config.RetryPolicy{
+			Attempts:        3,
+			BackoffType:     "linear",
+			BaseInterval:    time.Millisecond,
+			RetryOn:         []string{"gateway-error"},
+			BodyBufferLimit: 1024,
+		},
+	}, recorder)
+	routing := newRoutingMiddleware(cache, "127.0.0.1:8080", nil, 15006, 15001, false, policies, "", 0, nil, nil)
+	forwarder := proxy.NewForwarder(nil, time.Second, proxy.CopyModeBuffered)
+	forwarder.RequestChain = domain.Chain(newHTTPRetryMiddleware(policies, newRetryBudget(), recorder), routing)
+	chain := domain.Chain(newProtocolMiddleware(cache), routing)
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		done <- chain.Handle(&domain.ConnContext{
+			Context:     context.Background(),
+			ClientConn:  server,
+			OriginalDst: serviceAddr,
+			Metadata: map[string]any{
+				domain.MetadataListener:  string(proxy.ProfileOutbound),
+				domain.MetadataDirection: string(domain.DirectionOutbound),
+			},
+		}, forwarder.Handle)
+	}()
+
+	reader := bufio.NewReader(client)
+	send := func(method string) (int, string) {
+		request, _ := http.NewRequest(method, "http://ratings:9080/ratings/1", strings.NewReader("stars=5"))
+		if err := request.Write(client); err != nil {
+			t.Fatalf("write request: %v", err)
+		}
+
+		response, err := http.ReadResponse(reader, request)
+		if err != nil {
+			t.Fatalf("read response: %v", err)
+		}
+		defer response.Body.Close()
+
+		body, _ := io.ReadAll(response.Body)
+		return response.StatusCode, string(body)
+	}
+
+	if status, body := send(http.MethodPut); status != http.StatusOK || body != "stars=5" {
+		t.Fatalf("PUT = %d %q, want 200 with the replayed body", status, body)
+	}
+	if hits := unavailableHits.Load(); hits != 1 {
+		t.Fatalf("unavailable endpoint hits = %d, want 1", hits)
+	}
+
+	if status, _ := send(http.MethodPost); status != http.StatusServiceUnavailable {
+		t.Fatalf("POST status = %d, want 503 without a retry", status)
+	}
+
+	_ = client.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+}
+
+type openBreaker struct{}
+
+func (openBreaker) Handle(*domain.ConnContext, domain.NextFunc) error {
+	return domain.Wrap(domain.ErrorKindBreakerOpen, errors.New("circuit breaker is open"))
+}
+
+func TestOpenBreakerAnswersHTTPRequestOnce(t *testing.T) {
+	const serviceAddr = "10.96.0.12:9080"
+
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{{
+		ServiceKey: serviceAddr,
+		Endpoints:  []domain.Endpoint{{IP: "10.0.0.1", Port: 9080, ServiceName: "ratings.bookinfo.svc.cluster.local"}},
+	}})
+
+	recorder := metrics.NewRecorder()
+	policies := newTrafficPolicies(config.TrafficPolicy{
+		LoadBalancerAlgorithm: "none",
+		RetryPolicy: config.RetryPolicy{
+			Attempts:     2,
+			BackoffType:  "linear",
+			BaseInterval: time.Millisecond,
+			RetryOn:      []string{"reset"},
+		},
+	}, recorder)
+	budget := newRetryBudget()
+	routing := newRoutingMiddleware(cache, "127.0.0.1:8080", nil, 15006, 15001, false, policies, "", 0, nil, nil)
+	forwarder := proxy.NewForwarder(nil, time.Second, proxy.CopyModeBuffered)
+	forwarder.RequestChain = domain.Chain(newHTTPRetryMiddleware(policies, budget, recorder), routing, openBreaker{})
+	chain := domain.Chain(newProtocolMiddleware(cache), newRetryMiddleware(policies, budget, recorder), routing)
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	var handled atomic.Int32
+	done := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		done <- chain.Handle(&domain.ConnContext{
+			Context:     context.Background(),
+			ClientConn:  server,
+			OriginalDst: serviceAddr,
+			Metadata: map[string]any{
+				domain.MetadataListener:  string(proxy.ProfileOutbound),
+				domain.MetadataDirection: string(domain.DirectionOutbound),
+			},
+		}, func(ctx *domain.ConnContext) error {
+			handled.Add(1)
+			return forwarder.Handle(ctx)
+		})
+	}()
+
+	request, _ := http.NewRequest(http.MethodGet, "http://ratings:9080/ratings/1", nil)
+	go func() {
+		_ = request.Write(client)
+	}()
+
+	reader := bufio.NewReader(client)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	_, _ = io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", response.StatusCode)
+	}
+
+	_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Fatalf("read after response = %v, want the connection closed", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Handle() error = %v, want the answered request not to fail the connection", err)
+	}
+	if calls := handled.Load(); calls != 1 {
+		t.Fatalf("forwarder calls = %d, want 1", calls)
+	}
+
+	scrape := httptest.NewRecorder()
+	recorder.Handler().ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `mesh_retry_attempts_total{service="ratings.bookinfo.svc.cluster.local"} 1`; !strings.Contains(scrape.Body.String(), want) {
+		t.Fatalf("metrics do not contain %q:\n%s", want, scrape.Body.String())
+	}
+}
@@ -0,0 +1,43 @@
+package sidecar
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+const portPrefaceTimeout = 200 * time.Millisecond
+
+type identityMiddleware struct {
+	handshakeTimeout time.Duration
+}
+
+func newIdentityMiddleware(handshakeTimeout time.Duration) *identityMiddleware {
+	return &identityMiddleware{handshakeTimeout: handshakeTimeout}
+}
+
+func (m *identityMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	tlsConn, ok := ctx.ClientConn.(*tls.Conn)
+	if !ok {
+		return next(ctx)
+	}
+
+	identity, err := proxy.HandshakePeer(ctx.Context, tlsConn, m.handshakeTimeout)
+	if err != nil {
+		slog.Warn("inbound peer authentication failed", slog.String("remote", tlsConn.RemoteAddr().String()), slog.Any("error", err))
+		return err
+	}
+
+	ctx.Set(domain.MetadataPeerIdentity, identity)
+
+	conn, port := proxy.ReadPortPreface(tlsConn, portPrefaceTimeout)
+	ctx.ClientConn = conn
+	if port > 0 {
+		ctx.Set(domain.MetadataDestinationPort, port)
+	}
+
+	return next(ctx)
+}
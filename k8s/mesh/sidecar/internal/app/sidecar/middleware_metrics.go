@@ -1,38 +1,183 @@
 package sidecar
 
 import (
+	"net"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
 	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
 
 type metricsMiddleware struct {
-	recorder *metrics.Recorder
+	recorder  *metrics.Recorder
+	workloads *workloadResolver
 }
 
-func newMetricsMiddleware(recorder *metrics.Recorder) *metricsMiddleware {
-	return &metricsMiddleware{recorder: recorder}
+type workloadResolver struct {
+	cache     *discovery.ServiceCache
+	podName   string
+	namespace string
+	principal string
+}
+
+func newMetricsMiddleware(recorder *metrics.Recorder, workloads *workloadResolver) *metricsMiddleware {
+	return &metricsMiddleware{recorder: recorder, workloads: workloads}
+}
+
+func newWorkloadResolver(cache *discovery.ServiceCache, podName, namespace, serviceAccount, trustDomain string) *workloadResolver {
+	return &workloadResolver{
+		cache:     cache,
+		podName:   podName,
+		namespace: namespace,
+		principal: "spiffe://" + trustDomain + "/ns/" + namespace + "/sa/" + serviceAccount,
+	}
 }
 
 func (m *metricsMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
 	started := time.Now()
 	err := next(ctx)
 
+	request, isRequest := ctx.Metadata[domain.MetadataHTTPRequest].(*http.Request)
+	if !isRequest && domain.Protocol(ctx.GetString(domain.MetadataProtocol)).IsHTTP() {
+		// Requests of an HTTP connection are recorded one by one.
+		return err
+	}
+
 	service := ctx.GetString(domain.MetadataService)
 	direction := ctx.GetString(domain.MetadataDirection)
-	statusCode := "200"
-	if err != nil {
-		statusCode = "500"
+	mtls := ctx.GetBool(domain.MetadataInMesh) ||
+		ctx.GetString(domain.MetadataListener) == string(proxy.ProfileInboundMTLS)
+
+	if isRequest {
+		statusCode := requestStatusCode(ctx.GetString(domain.MetadataStatusCode), err)
+		m.recorder.ObserveRequest(
+			service,
+			statusCode,
+			direction,
+			mtls,
+			request.Method,
+			request.URL.Path,
+			m.workloads.peers(ctx),
+			time.Since(started),
+		)
+		ctx.Set(domain.MetadataStatusCode, statusCode)
+	} else {
+		m.recorder.ObserveTCPConnection(
+			service,
+			direction,
+			mtls,
+			m.workloads.peers(ctx),
+			int64(ctx.GetInt(domain.MetadataBytesReceived)),
+			int64(ctx.GetInt(domain.MetadataBytesSent)),
+		)
 	}
 
-	m.recorder.ObserveRequest(service, statusCode, direction, time.Since(started))
 	if err != nil {
 		errorType := domain.NormalizeErrorType(err)
 		ctx.Set(domain.MetadataErrorType, errorType)
 		m.recorder.ObserveError(service, errorType)
 	}
 
-	ctx.Set(domain.MetadataStatusCode, statusCode)
 	return err
 }
+
+func requestStatusCode(statusCode string, err error) string {
+	switch {
+	case domain.IsKind(err, domain.ErrorKindForbidden):
+		return "403"
+	case statusCode != "":
+		return statusCode
+	case domain.IsKind(err, domain.ErrorKindTimeout):
+		return "504"
+	case err != nil:
+		// No upstream response: the sidecar answers or resets like an unavailable upstream.
+		return "503"
+	default:
+		return "200"
+	}
+}
+
+func (r *workloadResolver) peers(ctx *domain.ConnContext) metrics.Peers {
+	if r == nil {
+		return metrics.Peers{}
+	}
+
+	local := r.local()
+	peerIdentity := ctx.GetString(domain.MetadataPeerIdentity)
+
+	if ctx.GetString(domain.MetadataDirection) == string(domain.DirectionInbound) {
+		var remote string
+		if ctx.ClientConn != nil {
+			remote = ctx.ClientConn.RemoteAddr().String()
+		}
+		source := r.remoteWorkload(remote, peerIdentity)
+
+		return metrics.Peers{
+			SourceWorkload:       source.Name,
+			SourceNamespace:      source.Namespace,
+			SourcePrincipal:      peerIdentity,
+			DestinationWorkload:  local.Name,
+			DestinationNamespace: local.Namespace,
+			DestinationPrincipal: r.principal,
+		}
+	}
+
+	destination := r.remoteWorkload(ctx.GetString(domain.MetadataTargetAddr), peerIdentity)
+
+	return metrics.Peers{
+		SourceWorkload:       local.Name,
+		SourceNamespace:      local.Namespace,
+		SourcePrincipal:      r.principal,
+		DestinationWorkload:  destination.Name,
+		DestinationNamespace: destination.Namespace,
+		DestinationPrincipal: peerIdentity,
+	}
+}
+
+func (r *workloadResolver) local() domain.Workload {
+	if workload, ok := r.cache.WorkloadByPod(r.podName); ok {
+		return workload
+	}
+
+	return domain.Workload{Name: r.podName, Namespace: r.namespace}
+}
+
+func (r *workloadResolver) resource() map[string]string {
+	local := r.local()
+	return map[string]string{
+		"service.name":       local.Name + "." + local.Namespace,
+		"k8s.pod.name":       r.podName,
+		"k8s.namespace.name": r.namespace,
+	}
+}
+
+func (r *workloadResolver) remoteWorkload(addr string, peerIdentity string) domain.Workload {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if workload, ok := r.cache.WorkloadByIP(host); ok {
+			return workload
+		}
+	}
+
+	// Pods of other namespaces are not discovered, their certificate still names the namespace.
+	return domain.Workload{Namespace: spiffeNamespace(peerIdentity)}
+}
+
+func spiffeNamespace(identity string) string {
+	_, path, ok := strings.Cut(strings.TrimPrefix(identity, "spiffe://"), "/")
+	if !ok {
+		return ""
+	}
+
+	segments := strings.Split(path, "/")
+	for i := 0; i+1 < len(segments); i += 2 {
+		if segments[i] == "ns" {
+			return segments[i+1]
+		}
+	}
+
+	return ""
+}
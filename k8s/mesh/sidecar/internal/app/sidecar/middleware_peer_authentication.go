@@ -0,0 +1,45 @@
+package sidecar
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/policy"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+const tlsSniffTimeout = 200 * time.Millisecond
+
+type peerAuthenticationMiddleware struct {
+	modes     *policy.PeerAuthenticationStore
+	tlsConfig *tls.Config
+}
+
+func newPeerAuthenticationMiddleware(modes *policy.PeerAuthenticationStore, tlsConfig *tls.Config) *peerAuthenticationMiddleware {
+	return &peerAuthenticationMiddleware{
+		modes:     modes,
+		tlsConfig: tlsConfig,
+	}
+}
+
+func (m *peerAuthenticationMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	if ctx.GetString(domain.MetadataListener) != string(proxy.ProfileInboundPlain) || proxy.IsLocalConnection(ctx.ClientConn) {
+		return next(ctx)
+	}
+
+	conn, isMeshTLS := proxy.SniffMeshTLS(ctx.ClientConn, tlsSniffTimeout)
+	if isMeshTLS {
+		ctx.ClientConn = tls.Server(conn, m.tlsConfig)
+		ctx.Set(domain.MetadataListener, string(proxy.ProfileInboundMTLS))
+		return next(ctx)
+	}
+
+	if m.modes.Mode() == policy.MTLSModeStrict {
+		return domain.Wrap(domain.ErrorKindTLS, fmt.Errorf("non-mesh connection from %s rejected in STRICT mtls mode", conn.RemoteAddr()))
+	}
+
+	ctx.ClientConn = conn
+	return next(ctx)
+}
@@ -0,0 +1,75 @@
+package sidecar
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+type protocolMiddleware struct {
+	cache *discovery.ServiceCache
+}
+
+func newProtocolMiddleware(cache *discovery.ServiceCache) *protocolMiddleware {
+	return &protocolMiddleware{cache: cache}
+}
+
+func (m *protocolMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	if ctx.GetString(domain.MetadataDirection) == string(domain.DirectionInbound) {
+		// The forwarder sniffs inbound connections unless the local port is declared opaque.
+		switch declared := m.cache.GetEndpointProtocol(inboundEndpoint(ctx)); declared {
+		case domain.ProtocolTCP, domain.ProtocolTLS:
+			ctx.Set(domain.MetadataProtocol, string(declared))
+		}
+		return next(ctx)
+	}
+
+	if ctx.GetString(domain.MetadataDirection) != string(domain.DirectionOutbound) ||
+		len(m.cache.GetEndpoints(ctx.OriginalDst)) == 0 {
+		return next(ctx)
+	}
+
+	declared := m.cache.GetProtocol(ctx.OriginalDst)
+	switch declared {
+	case domain.ProtocolTCP, domain.ProtocolTLS:
+		ctx.Set(domain.MetadataProtocol, string(declared))
+		return next(ctx)
+	}
+
+	var (
+		conn     net.Conn
+		protocol domain.Protocol
+	)
+	if declared.IsHTTP() {
+		// HTTP clients speak first, so the version is read without the sniffing
+		// deadline. A port declared http2 may still get HTTP/1.1 clients.
+		conn, protocol = proxy.PeekHTTP(ctx.ClientConn)
+	} else {
+		conn, protocol = proxy.SniffHTTP(ctx.ClientConn)
+	}
+
+	ctx.ClientConn = conn
+	if protocol != "" {
+		ctx.Set(domain.MetadataProtocol, string(protocol))
+	}
+
+	return next(ctx)
+}
+
+// inboundEndpoint is the address of the local endpoint an inbound connection
+// targets: the pod IP with the port from the preface, if the peer sent one.
+func inboundEndpoint(ctx *domain.ConnContext) string {
+	host, port, err := net.SplitHostPort(ctx.OriginalDst)
+	if err != nil {
+		return ""
+	}
+
+	if preface := ctx.GetInt(domain.MetadataDestinationPort); preface > 0 {
+		port = strconv.Itoa(preface)
+	}
+
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,115 @@
+package sidecar
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"golang.org/x/net/http2"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func TestProtocolMarksDeclaredOpaqueInboundPorts(t *testing.T) {
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{
+		{
+			ServiceKey: "postgres.db.svc.cluster.local:5432",
+			Endpoints:  []domain.Endpoint{{IP: "10.0.0.5", Port: 5432}},
+			Protocol:   domain.ProtocolTCP,
+		},
+		{
+			ServiceKey: "reviews.bookinfo.svc.cluster.local:9080",
+			Endpoints:  []domain.Endpoint{{IP: "10.0.0.5", Port: 9080}},
+			Protocol:   domain.ProtocolHTTP,
+		},
+	})
+	protocol := newProtocolMiddleware(cache)
+
+	tests := []struct {
+		name        string
+		originalDst string
+		port        int
+		want        string
+	}{
+		{name: "plain opaque", originalDst: "10.0.0.5:5432", want: "tcp"},
+		{name: "preface opaque", originalDst: "10.0.0.5:15001", port: 5432, want: "tcp"},
+		{name: "declared http", originalDst: "10.0.0.5:9080"},
+		{name: "undeclared", originalDst: "10.0.0.5:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &domain.ConnContext{
+				Context:     context.Background(),
+				OriginalDst: tt.originalDst,
+				Metadata: map[string]any{
+					domain.MetadataDirection:       string(domain.DirectionInbound),
+					domain.MetadataDestinationPort: tt.port,
+				},
+			}
+
+			var got string
+			_ = protocol.Handle(ctx, func(ctx *domain.ConnContext) error {
+				got = ctx.GetString(domain.MetadataProtocol)
+				return nil
+			})
+			if got != tt.want {
+				t.Fatalf("protocol = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtocolReadsVersionOnDeclaredHTTP2Ports(t *testing.T) {
+	const serviceAddr = "10.96.0.10:9080"
+
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{{
+		ServiceKey: serviceAddr,
+		Endpoints:  []domain.Endpoint{{IP: "10.0.0.1", Port: 9080}},
+		Protocol:   domain.ProtocolHTTP2,
+	}})
+	protocol := newProtocolMiddleware(cache)
+
+	tests := []struct {
+		name string
+		data string
+		want domain.Protocol
+	}{
+		{name: "http2", data: http2.ClientPreface, want: domain.ProtocolHTTP2},
+		{name: "http1", data: "GET / HTTP/1.1\r\nHost: reviews\r\n\r\n", want: domain.ProtocolHTTP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			go func() {
+				_, _ = io.WriteString(client, tt.data)
+			}()
+
+			ctx := &domain.ConnContext{
+				Context:     context.Background(),
+				ClientConn:  server,
+				OriginalDst: serviceAddr,
+				Metadata: map[string]any{
+					domain.MetadataDirection: string(domain.DirectionOutbound),
+				},
+			}
+
+			var got string
+			_ = protocol.Handle(ctx, func(ctx *domain.ConnContext) error {
+				got = ctx.GetString(domain.MetadataProtocol)
+				return nil
+			})
+			if got != string(tt.want) {
+				t.Fatalf("protocol = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,32 +6,40 @@ import (
 	"time"
 
 	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
 
 type retryMiddleware struct {
-	attempts     int
-	backoffType  string
-	baseInterval time.Duration
-	recorder     *metrics.Recorder
+	policies *trafficPolicies
+	budget   *retryBudget
+	recorder *metrics.Recorder
 }
 
-func newRetryMiddleware(attempts int, backoffType string, baseInterval time.Duration, recorder *metrics.Recorder) *retryMiddleware {
+func newRetryMiddleware(policies *trafficPolicies, budget *retryBudget, recorder *metrics.Recorder) *retryMiddleware {
 	return &retryMiddleware{
-		attempts:     attempts,
-		backoffType:  backoffType,
-		baseInterval: baseInterval,
-		recorder:     recorder,
+		policies: policies,
+		budget:   budget,
+		recorder: recorder,
 	}
 }
 
 func (m *retryMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
-	if m.attempts <= 1 {
+	policy := m.policies.For(ctx).RetryPolicy
+	if policy.Attempts <= 1 {
 		return next(ctx)
 	}
 
+	// Requests of an HTTP connection are retried one by one by the forwarder.
+	if domain.Protocol(ctx.GetString(domain.MetadataProtocol)).IsHTTP() && ctx.Metadata[domain.MetadataHTTPRequest] == nil {
+		return next(ctx)
+	}
+
+	destination := ctx.GetString(domain.MetadataService)
+	m.budget.recordRequest(destination, policy.Budget)
+
 	var lastErr error
-	for attempt := 1; attempt <= m.attempts; attempt++ {
+	for attempt := 1; attempt <= policy.Attempts; attempt++ {
 		err := next(ctx)
 		if err == nil {
 			if attempt > 1 {
@@ -46,19 +54,26 @@ func (m *retryMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc)
 			return err
 		}
 
-		if attempt == m.attempts {
+		if attempt == policy.Attempts {
 			return err
 		}
 
 		service := ctx.GetString(domain.MetadataService)
+		if !m.budget.allowRetry(destination, policy.Budget) {
+			m.recorder.IncRetryBudgetExhausted(service)
+			slog.Warn("retry budget exhausted, skipping reconnect", slog.String("service", service), slog.Any("error", err))
+			return err
+		}
+
 		m.recorder.IncRetry(service)
+		ctx.Set(domain.MetadataRetryCount, attempt)
 
-		waitFor := m.backoffDuration(attempt)
+		waitFor := retryBackoff(policy, attempt)
 		slog.Warn(
 			"retry middleware scheduling reconnect",
 			slog.String("service", service),
 			slog.Int("attempt", attempt),
-			slog.Int("max_attempts", m.attempts),
+			slog.Int("max_attempts", policy.Attempts),
 			slog.Duration("backoff", waitFor),
 			slog.Any("error", err),
 		)
@@ -72,15 +87,15 @@ func (m *retryMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc)
 	return lastErr
 }
 
-func (m *retryMiddleware) backoffDuration(retryNumber int) time.Duration {
+func retryBackoff(policy config.RetryPolicy, retryNumber int) time.Duration {
 	if retryNumber < 1 {
 		retryNumber = 1
 	}
 
-	if m.backoffType != "exponential" {
-		return time.Duration(retryNumber) * m.baseInterval
+	if policy.BackoffType != "exponential" {
+		return time.Duration(retryNumber) * policy.BaseInterval
 	}
 
 	factor := math.Pow(2, float64(retryNumber-1))
-	return time.Duration(factor) * m.baseInterval
+	return time.Duration(factor) * policy.BaseInterval
 }
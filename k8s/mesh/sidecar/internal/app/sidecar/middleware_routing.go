@@ -1,8 +1,13 @@
 package sidecar
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
 	"net"
+	"net/http"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
@@ -15,41 +20,68 @@ import (
 type routingMiddleware struct {
 	cache              *discovery.ServiceCache
 	appTargetAddr      string
+	appPorts           []int
 	inboundPlainPort   int
 	inboundMTLSPort    int
 	mtlsEnabled        bool
-	loadBalancerPolicy string
+	policies           *trafficPolicies
+	zone               string
+	spilloverThreshold float64
+	outliers           *outlierDetector
+	health             *healthChecker
 
 	mu              sync.Mutex
 	roundRobinState map[string]int
-	rnd             *rand.Rand
+	inFlight        map[string]int
+	rings           map[string]*hashRing
+	rnd             *mathrand.Rand
 }
 
 func newRoutingMiddleware(
 	cache *discovery.ServiceCache,
 	appTargetAddr string,
+	appPorts []int,
 	inboundPlainPort int,
 	inboundMTLSPort int,
 	mtlsEnabled bool,
-	loadBalancerPolicy string,
+	policies *trafficPolicies,
+	zone string,
+	spilloverThreshold float64,
+	outliers *outlierDetector,
+	health *healthChecker,
 ) *routingMiddleware {
-	return &routingMiddleware{
+	m := &routingMiddleware{
 		cache:              cache,
 		appTargetAddr:      appTargetAddr,
+		appPorts:           appPorts,
 		inboundPlainPort:   inboundPlainPort,
 		inboundMTLSPort:    inboundMTLSPort,
 		mtlsEnabled:        mtlsEnabled,
-		loadBalancerPolicy: loadBalancerPolicy,
+		policies:           policies,
+		zone:               zone,
+		spilloverThreshold: spilloverThreshold,
+		outliers:           outliers,
+		health:             health,
 		roundRobinState:    make(map[string]int),
-		rnd:                rand.New(rand.NewSource(time.Now().UnixNano())),
+		inFlight:           make(map[string]int),
+		rings:              make(map[string]*hashRing),
+		rnd:                mathrand.New(mathrand.NewSource(time.Now().UnixNano())),
 	}
+	cache.OnReplace(m.syncRings)
+
+	return m
 }
 
 func (m *routingMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
 	listener := ctx.GetString(domain.MetadataListener)
 	switch listener {
 	case string(proxy.ProfileInboundPlain), string(proxy.ProfileInboundMTLS):
-		ctx.Set(domain.MetadataTargetAddr, m.appTargetAddr)
+		targetAddr, err := m.inboundTargetAddr(ctx)
+		if err != nil {
+			return err
+		}
+
+		ctx.Set(domain.MetadataTargetAddr, targetAddr)
 		ctx.Set(domain.MetadataService, "local-app")
 		ctx.Set(domain.MetadataInMesh, false)
 		ctx.Set(domain.MetadataServerName, "")
@@ -60,7 +92,7 @@ func (m *routingMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc
 	}
 }
 
-func (m *routingMiddleware) routeOutbound(ctx *domain.ConnContext, next domain.NextFunc) error {
+func (m *routingMiddleware) routeOutbound(ctx *domain.ConnContext, next domain.NextFunc) (err error) {
 	endpoints := m.cache.GetEndpoints(ctx.OriginalDst)
 	if len(endpoints) == 0 {
 		ctx.Set(domain.MetadataTargetAddr, ctx.OriginalDst)
@@ -71,43 +103,348 @@ func (m *routingMiddleware) routeOutbound(ctx *domain.ConnContext, next domain.N
 		return next(ctx)
 	}
 
-	selected := m.selectEndpoint(ctx.OriginalDst, endpoints)
-	targetPort := m.inboundPlainPort
-	if m.mtlsEnabled {
-		targetPort = m.inboundMTLSPort
+	if domain.Protocol(ctx.GetString(domain.MetadataProtocol)).IsHTTP() && ctx.Metadata[domain.MetadataHTTPRequest] == nil {
+		// The forwarder picks an endpoint for every request read from this connection.
+		ctx.Set(domain.MetadataTargetAddr, ctx.OriginalDst)
+		ctx.Set(domain.MetadataService, endpoints[0].ServiceName)
+		ctx.Set(domain.MetadataInMesh, false)
+		ctx.Set(domain.MetadataServerName, "")
+		ctx.Set(domain.MetadataBreakerKey, "")
+		return next(ctx)
 	}
-	targetAddr := net.JoinHostPort(selected.IP, strconv.Itoa(targetPort))
 
-	ctx.Set(domain.MetadataTargetAddr, targetAddr)
-	ctx.Set(domain.MetadataService, selected.ServiceName)
-	ctx.Set(domain.MetadataInMesh, m.mtlsEnabled)
-	if m.mtlsEnabled {
-		ctx.Set(domain.MetadataServerName, selected.ServiceName)
-	} else {
+	candidates := endpoints
+	if m.health != nil {
+		candidates = m.health.healthy(candidates)
+	}
+	if m.outliers != nil {
+		candidates = m.outliers.healthy(candidates)
+	}
+
+	candidates = untriedEndpoints(ctx, candidates)
+
+	selected := m.pickEndpoint(ctx, m.localEndpoints(candidates))
+	endpointKey := endpointAddr(selected)
+	tried, _ := ctx.Metadata[domain.MetadataTriedEndpoints].([]string)
+	ctx.Set(domain.MetadataTriedEndpoints, append(tried, endpointKey))
+	m.acquire(endpointKey)
+	ctx.Set(domain.MetadataEndpointRelease, domain.EndpointRelease(sync.OnceFunc(func() {
+		m.release(endpointKey)
+	})))
+	defer func() {
+		// A routed HTTP response keeps its slot until its body is closed.
+		if release, ok := ctx.Metadata[domain.MetadataEndpointRelease].(domain.EndpointRelease); ok {
+			delete(ctx.Metadata, domain.MetadataEndpointRelease)
+			release()
+		}
+	}()
+
+	if m.outliers != nil {
+		defer func() {
+			m.outliers.report(selected, len(endpoints), err, ctx.GetString(domain.MetadataStatusCode))
+		}()
+	}
+
+	// Without mTLS a meshed endpoint is dialed at its own port too: the peer's
+	// iptables redirects it and SO_ORIGINAL_DST keeps the application port.
+	if !selected.Meshed || !m.mtlsEnabled {
+		targetAddr := endpointKey
+
+		ctx.Set(domain.MetadataTargetAddr, targetAddr)
+		ctx.Set(domain.MetadataService, selected.ServiceName)
+		ctx.Set(domain.MetadataInMesh, false)
 		ctx.Set(domain.MetadataServerName, "")
+		ctx.Set(domain.MetadataBreakerKey, targetAddr)
+		return next(ctx)
 	}
+
+	targetAddr := net.JoinHostPort(selected.IP, strconv.Itoa(m.inboundMTLSPort))
+
+	ctx.Set(domain.MetadataTargetAddr, targetAddr)
+	ctx.Set(domain.MetadataService, selected.ServiceName)
+	ctx.Set(domain.MetadataInMesh, true)
+	ctx.Set(domain.MetadataServerName, selected.ServiceName)
+	ctx.Set(domain.MetadataExpectedIdentity, selected.Identity)
+	ctx.Set(domain.MetadataDestinationPort, selected.Port)
 	ctx.Set(domain.MetadataBreakerKey, targetAddr)
 
 	return next(ctx)
 }
 
-func (m *routingMiddleware) selectEndpoint(key string, endpoints []domain.Endpoint) domain.Endpoint {
-	if m.loadBalancerPolicy == "none" {
-		return endpoints[0]
+// inboundTargetAddr picks the local port an inbound connection is forwarded
+// to. The port comes from the peer's preface, so only application ports are
+// accepted: anything else would expose loopback-only sidecar listeners.
+func (m *routingMiddleware) inboundTargetAddr(ctx *domain.ConnContext) (string, error) {
+	port := ctx.GetInt(domain.MetadataDestinationPort)
+	if port == 0 {
+		_, rawPort, err := net.SplitHostPort(ctx.OriginalDst)
+		if err != nil {
+			return m.appTargetAddr, nil
+		}
+
+		port, err = strconv.Atoi(rawPort)
+		if err != nil {
+			return m.appTargetAddr, nil
+		}
 	}
 
-	if m.loadBalancerPolicy == "random" {
-		m.mu.Lock()
-		defer m.mu.Unlock()
-		return endpoints[m.rnd.Intn(len(endpoints))]
+	// Without SO_ORIGINAL_DST or a port preface the connection only reveals
+	// the sidecar listener port, so fall back to the configured app address.
+	if port <= 0 || port == m.inboundPlainPort || port == m.inboundMTLSPort {
+		return m.appTargetAddr, nil
+	}
+
+	if !slices.Contains(m.appPorts, port) {
+		return "", domain.Wrap(domain.ErrorKindForbidden, fmt.Errorf("inbound port %d is not an application port", port))
+	}
+
+	host, _, err := net.SplitHostPort(m.appTargetAddr)
+	if err != nil {
+		return m.appTargetAddr, nil
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// untriedEndpoints drops endpoints that earlier attempts of a retried
+// connection or request already used, unless no other endpoint is left.
+func untriedEndpoints(ctx *domain.ConnContext, endpoints []domain.Endpoint) []domain.Endpoint {
+	tried, _ := ctx.Metadata[domain.MetadataTriedEndpoints].([]string)
+	if len(tried) == 0 {
+		return endpoints
+	}
+
+	untried := make([]domain.Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if !slices.Contains(tried, endpointAddr(endpoint)) {
+			untried = append(untried, endpoint)
+		}
+	}
+
+	if len(untried) == 0 {
+		return endpoints
+	}
+
+	return untried
+}
+
+func (m *routingMiddleware) localEndpoints(endpoints []domain.Endpoint) []domain.Endpoint {
+	if m.zone == "" {
+		return endpoints
+	}
+
+	hinted := true
+	for _, endpoint := range endpoints {
+		if len(endpoint.ForZones) == 0 {
+			hinted = false
+			break
+		}
+	}
+
+	local := make([]domain.Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if hinted && slices.Contains(endpoint.ForZones, m.zone) || !hinted && endpoint.Zone == m.zone {
+			local = append(local, endpoint)
+		}
+	}
+
+	if len(local) == 0 {
+		return endpoints
+	}
+
+	// Topology hints already balance endpoints across zones, so only
+	// zone-label based selection needs the spillover check.
+	if !hinted && float64(len(local)) < m.spilloverThreshold*float64(len(endpoints)) {
+		return endpoints
+	}
+
+	return local
+}
+
+func (m *routingMiddleware) pickEndpoint(ctx *domain.ConnContext, endpoints []domain.Endpoint) domain.Endpoint {
+	traffic := m.policies.For(ctx)
+	algorithm := traffic.LoadBalancerAlgorithm
+	// Validate has already checked the key of a ringHash policy.
+	hash, _ := domain.ParseHashKey(traffic.LoadBalancerHashKey)
+
+	policy := m.cache.GetLoadBalancer(ctx.OriginalDst)
+	if policy.Algorithm != "" {
+		algorithm = policy.Algorithm
+		hash = policy.Hash
+	}
+
+	if algorithm == domain.LoadBalancerRingHash {
+		if key, ok := hashRequestKey(ctx, hash); ok {
+			return m.selectRingHash(ctx.OriginalDst, key, endpoints)
+		}
+
+		// Opaque TCP has no header or cookie to hash on.
+		algorithm = "roundRobin"
+	}
+
+	return m.selectEndpoint(algorithm, ctx.OriginalDst, endpoints)
+}
+
+func (m *routingMiddleware) selectEndpoint(algorithm string, key string, endpoints []domain.Endpoint) domain.Endpoint {
+	if algorithm == "none" {
+		return endpoints[0]
 	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	switch algorithm {
+	case "random":
+		return endpoints[m.rnd.Intn(len(endpoints))]
+	case "leastRequest":
+		return m.selectPowerOfTwoChoices(endpoints)
+	case "leastConnection":
+		return m.selectLeastConnection(key, endpoints)
+	}
+
 	idx := m.roundRobinState[key]
 	selected := endpoints[idx%len(endpoints)]
 	m.roundRobinState[key] = (idx + 1) % len(endpoints)
 
 	return selected
 }
+
+func (m *routingMiddleware) selectPowerOfTwoChoices(endpoints []domain.Endpoint) domain.Endpoint {
+	if len(endpoints) == 1 {
+		return endpoints[0]
+	}
+
+	first := m.rnd.Intn(len(endpoints))
+	second := m.rnd.Intn(len(endpoints) - 1)
+	if second >= first {
+		second++
+	}
+
+	if m.inFlight[endpointAddr(endpoints[second])] < m.inFlight[endpointAddr(endpoints[first])] {
+		return endpoints[second]
+	}
+
+	return endpoints[first]
+}
+
+func (m *routingMiddleware) selectLeastConnection(key string, endpoints []domain.Endpoint) domain.Endpoint {
+	// Scanning from the round-robin position spreads ties across endpoints.
+	start := m.roundRobinState[key] % len(endpoints)
+	m.roundRobinState[key] = (start + 1) % len(endpoints)
+
+	selected := endpoints[start]
+	least := m.inFlight[endpointAddr(selected)]
+	for offset := 1; offset < len(endpoints); offset++ {
+		candidate := endpoints[(start+offset)%len(endpoints)]
+		if active := m.inFlight[endpointAddr(candidate)]; active < least {
+			selected = candidate
+			least = active
+		}
+	}
+
+	return selected
+}
+
+func (m *routingMiddleware) selectRingHash(serviceKey string, key string, endpoints []domain.Endpoint) domain.Endpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ring, ok := m.rings[serviceKey]
+	if !ok {
+		// The ring holds every endpoint of the service and changes only with
+		// discovery; unhealthy or already tried endpoints are skipped on lookup.
+		ring = newHashRing()
+		ring.sync(m.cache.GetEndpoints(serviceKey))
+		m.rings[serviceKey] = ring
+	}
+
+	candidates := make(map[string]struct{}, len(endpoints))
+	for _, endpoint := range endpoints {
+		candidates[endpointAddr(endpoint)] = struct{}{}
+	}
+
+	selected, ok := ring.get(key, func(addr string) bool {
+		_, ok := candidates[addr]
+		return ok
+	})
+	if !ok {
+		return endpoints[0]
+	}
+
+	return selected
+}
+
+// syncRings applies a discovery update to the hash rings built so far and
+// drops the rings of services that are gone.
+func (m *routingMiddleware) syncRings(endpoints map[string][]domain.Endpoint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for serviceKey, ring := range m.rings {
+		current := endpoints[serviceKey]
+		if len(current) == 0 {
+			delete(m.rings, serviceKey)
+			continue
+		}
+
+		ring.sync(current)
+	}
+}
+
+func hashRequestKey(ctx *domain.ConnContext, policy domain.HashPolicy) (string, bool) {
+	if policy.Source == domain.HashSourceSourceIP || policy.Source == "" {
+		host, _, err := net.SplitHostPort(ctx.ClientConn.RemoteAddr().String())
+		if err != nil {
+			return "", false
+		}
+		return host, true
+	}
+
+	request, ok := ctx.Metadata[domain.MetadataHTTPRequest].(*http.Request)
+	if !ok {
+		return "", false
+	}
+
+	switch policy.Source {
+	case domain.HashSourceHeader:
+		value := request.Header.Get(policy.Name)
+		return value, value != ""
+	case domain.HashSourceCookie:
+		if cookie, err := request.Cookie(policy.Name); err == nil && cookie.Value != "" {
+			return cookie.Value, true
+		}
+
+		value := make([]byte, 16)
+		if _, err := rand.Read(value); err != nil {
+			return "", false
+		}
+
+		cookie := &http.Cookie{Name: policy.Name, Value: hex.EncodeToString(value), Path: "/", HttpOnly: true}
+		ctx.Set(domain.MetadataSetCookie, cookie)
+		return cookie.Value, true
+	default:
+		return "", false
+	}
+}
+
+func (m *routingMiddleware) acquire(endpoint string) {
+	m.mu.Lock()
+	m.inFlight[endpoint]++
+	m.mu.Unlock()
+}
+
+func (m *routingMiddleware) release(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inFlight[endpoint] <= 1 {
+		delete(m.inFlight, endpoint)
+		return
+	}
+
+	m.inFlight[endpoint]--
+}
+
+func endpointAddr(endpoint domain.Endpoint) string {
+	return net.JoinHostPort(endpoint.IP, strconv.Itoa(endpoint.Port))
+}
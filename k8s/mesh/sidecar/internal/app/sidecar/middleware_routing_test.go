@@ -0,0 +1,387 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func loadBalancing(algorithm string) *trafficPolicies {
+	return newTrafficPolicies(config.TrafficPolicy{LoadBalancerAlgorithm: algorithm}, nil)
+}
+
+func TestRoutingBalancesKeepAliveHTTPRequests(t *testing.T) {
+	const serviceAddr = "10.96.0.10:9080"
+
+	cache := discovery.NewServiceCache(nil)
+	endpoints := make([]domain.Endpoint, 0, 2)
+	for _, name := range []string{"reviews-v1", "reviews-v2"} {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = io.WriteString(w, name)
+		}))
+		defer backend.Close()
+
+		host, rawPort, _ := net.SplitHostPort(backend.Listener.Addr().String())
+		port, _ := strconv.Atoi(rawPort)
+		endpoints = append(endpoints, domain.Endpoint{IP: host, Port: port, ServiceName: "reviews.bookinfo.svc.cluster.local"})
+	}
+	cache.Replace([]discovery.CachedService{{ServiceKey: serviceAddr, Endpoints: endpoints}})
+
+	routing := newRoutingMiddleware(cache, "127.0.0.1:8080", nil, 15006, 15001, false, loadBalancing("roundRobin"), "", 0, nil, nil)
+	forwarder := proxy.NewForwarder(nil, time.Second, proxy.CopyModeBuffered)
+	forwarder.RequestChain = domain.Chain(routing)
+	chain := domain.Chain(newProtocolMiddleware(cache), routing)
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		defer server.Close()
+		done <- chain.Handle(&domain.ConnContext{
+			Context:     context.Background(),
+			ClientConn:  server,
+			OriginalDst: serviceAddr,
+			Metadata: map[string]any{
+				domain.MetadataListener:  string(proxy.ProfileOutbound),
+				domain.MetadataDirection: string(domain.DirectionOutbound),
+			},
+		}, forwarder.Handle)
+	}()
+
+	reader := bufio.NewReader(client)
+	served := make(map[string]bool)
+	for range 2 {
+		request, _ := http.NewRequest(http.MethodGet, "http://reviews:9080/reviews/1", nil)
+		if err := request.Write(client); err != nil {
+			t.Fatalf("write request: %v", err)
+		}
+
+		response, err := http.ReadResponse(reader, request)
+		if err != nil {
+			t.Fatalf("read response: %v", err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		_ = response.Body.Close()
+		served[string(body)] = true
+	}
+
+	_ = client.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	if !served["reviews-v1"] || !served["reviews-v2"] {
+		t.Fatalf("requests on one connection served by %v, want both endpoints", served)
+	}
+}
+
+func TestSelectEndpointPrefersLeastLoaded(t *testing.T) {
+	endpoints := []domain.Endpoint{
+		{IP: "10.0.0.1", Port: 9080},
+		{IP: "10.0.0.2", Port: 9080},
+	}
+
+	for _, algorithm := range []string{"leastRequest", "leastConnection"} {
+		t.Run(algorithm, func(t *testing.T) {
+			routing := newRoutingMiddleware(discovery.NewServiceCache(nil), "127.0.0.1:8080", nil, 15006, 15001, true, loadBalancing(algorithm), "", 0, nil, nil)
+			routing.acquire("10.0.0.1:9080")
+			routing.acquire("10.0.0.1:9080")
+
+			for range 10 {
+				if selected := routing.selectEndpoint(algorithm, "reviews:9080", endpoints); selected.IP != "10.0.0.2" {
+					t.Fatalf("selectEndpoint() = %s, want the endpoint without in-flight requests", selected.IP)
+				}
+			}
+
+			routing.release("10.0.0.1:9080")
+			routing.release("10.0.0.1:9080")
+			if len(routing.inFlight) != 0 {
+				t.Fatalf("inFlight = %v, want released endpoints to be removed", routing.inFlight)
+			}
+		})
+	}
+}
+
+func TestRingHashCookieAffinity(t *testing.T) {
+	const serviceAddr = "10.96.0.10:9080"
+
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{{
+		ServiceKey: serviceAddr,
+		Endpoints: []domain.Endpoint{
+			{IP: "10.0.0.1", Port: 9080},
+			{IP: "10.0.0.2", Port: 9080},
+			{IP: "10.0.0.3", Port: 9080},
+		},
+		LoadBalancer: domain.LoadBalancerPolicy{
+			Algorithm: domain.LoadBalancerRingHash,
+			Hash:      domain.HashPolicy{Source: domain.HashSourceCookie, Name: "mesh-session"},
+		},
+	}})
+	routing := newRoutingMiddleware(cache, "127.0.0.1:8080", nil, 15006, 15001, true, loadBalancing("roundRobin"), "", 0, nil, nil)
+
+	route := func(request *http.Request) *domain.ConnContext {
+		ctx := &domain.ConnContext{
+			Context:     context.Background(),
+			OriginalDst: serviceAddr,
+			Metadata: map[string]any{
+				domain.MetadataListener:    string(proxy.ProfileOutbound),
+				domain.MetadataDirection:   string(domain.DirectionOutbound),
+				domain.MetadataHTTPRequest: request,
+			},
+		}
+		if err := routing.Handle(ctx, func(*domain.ConnContext) error { return nil }); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+		return ctx
+	}
+
+	first := route(httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie, ok := first.Metadata[domain.MetadataSetCookie].(*http.Cookie)
+	if !ok {
+		t.Fatal("expected routing to generate an affinity cookie")
+	}
+
+	for range 10 {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(cookie)
+
+		next := route(request)
+		if next.GetString(domain.MetadataBreakerKey) != first.GetString(domain.MetadataBreakerKey) {
+			t.Fatalf("request with cookie routed to %s, want %s", next.GetString(domain.MetadataBreakerKey), first.GetString(domain.MetadataBreakerKey))
+		}
+		if _, generated := next.Metadata[domain.MetadataSetCookie]; generated {
+			t.Fatal("expected existing cookie to be reused")
+		}
+	}
+}
+
+func TestRingHashSkipsFilteredEndpointsWithoutRebuilding(t *testing.T) {
+	const serviceAddr = "10.96.0.10:9080"
+
+	endpoints := []domain.Endpoint{
+		{IP: "10.0.0.1", Port: 9080},
+		{IP: "10.0.0.2", Port: 9080},
+		{IP: "10.0.0.3", Port: 9080},
+	}
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{{ServiceKey: serviceAddr, Endpoints: endpoints}})
+	routing := newRoutingMiddleware(cache, "127.0.0.1:8080", nil, 15006, 15001, true, loadBalancing("ringHash"), "", 0, nil, nil)
+
+	owner := routing.selectRingHash(serviceAddr, "user-1", endpoints)
+	others := slices.DeleteFunc(slices.Clone(endpoints), func(endpoint domain.Endpoint) bool {
+		return endpoint.IP == owner.IP
+	})
+
+	if fallback := routing.selectRingHash(serviceAddr, "user-1", others); fallback.IP == owner.IP {
+		t.Fatalf("selectRingHash() = %s, want an endpoint other than the filtered owner", fallback.IP)
+	}
+	if len(routing.rings[serviceAddr].members) != len(endpoints) {
+		t.Fatalf("ring members = %d, want the ring kept with all %d endpoints", len(routing.rings[serviceAddr].members), len(endpoints))
+	}
+	if again := routing.selectRingHash(serviceAddr, "user-1", endpoints); again.IP != owner.IP {
+		t.Fatalf("selectRingHash() = %s, want the key back on its owner %s", again.IP, owner.IP)
+	}
+
+	cache.Replace([]discovery.CachedService{{ServiceKey: serviceAddr, Endpoints: others}})
+	if len(routing.rings[serviceAddr].members) != len(others) {
+		t.Fatalf("ring members = %d after discovery update, want %d", len(routing.rings[serviceAddr].members), len(others))
+	}
+
+	cache.Replace(nil)
+	if len(routing.rings) != 0 {
+		t.Fatalf("rings = %v, want the ring of a removed service dropped", routing.rings)
+	}
+}
+
+func TestLocalEndpointsPreferOwnZone(t *testing.T) {
+	zoneA1 := domain.Endpoint{IP: "10.0.0.1", Zone: "zone-a"}
+	zoneA2 := domain.Endpoint{IP: "10.0.0.2", Zone: "zone-a"}
+	zoneB1 := domain.Endpoint{IP: "10.0.1.1", Zone: "zone-b"}
+	zoneB2 := domain.Endpoint{IP: "10.0.1.2", Zone: "zone-b"}
+	zoneB3 := domain.Endpoint{IP: "10.0.1.3", Zone: "zone-b"}
+
+	tests := []struct {
+		name      string
+		endpoints []domain.Endpoint
+		want      []string
+	}{
+		{
+			name:      "same zone endpoints only",
+			endpoints: []domain.Endpoint{zoneA1, zoneB1, zoneA2},
+			want:      []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name:      "spill over below threshold",
+			endpoints: []domain.Endpoint{zoneA1, zoneB1, zoneB2, zoneB3},
+			want:      []string{"10.0.0.1", "10.0.1.1", "10.0.1.2", "10.0.1.3"},
+		},
+		{
+			name: "hints override zone labels",
+			endpoints: []domain.Endpoint{
+				{IP: "10.0.0.1", Zone: "zone-a", ForZones: []string{"zone-a"}},
+				{IP: "10.0.1.1", Zone: "zone-b", ForZones: []string{"zone-a"}},
+				{IP: "10.0.1.2", Zone: "zone-b", ForZones: []string{"zone-b"}},
+			},
+			want: []string{"10.0.0.1", "10.0.1.1"},
+		},
+		{
+			name:      "no local endpoints",
+			endpoints: []domain.Endpoint{zoneB1},
+			want:      []string{"10.0.1.1"},
+		},
+	}
+
+	routing := newRoutingMiddleware(discovery.NewServiceCache(nil), "127.0.0.1:8080", nil, 15006, 15001, true, loadBalancing("roundRobin"), "zone-a", 0.3, nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := routing.localEndpoints(tt.endpoints)
+			if len(got) != len(tt.want) {
+				t.Fatalf("localEndpoints() = %v, want %v", got, tt.want)
+			}
+			for i, endpoint := range got {
+				if endpoint.IP != tt.want[i] {
+					t.Fatalf("localEndpoints() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRoutingRejectsInboundPortsOutsideApplication(t *testing.T) {
+	routing := newRoutingMiddleware(discovery.NewServiceCache(nil), "127.0.0.1:9080", []int{9080, 9081}, 15006, 15001, true, loadBalancing("roundRobin"), "", 0, nil, nil)
+
+	for port, want := range map[int]string{0: "127.0.0.1:9080", 15001: "127.0.0.1:9080", 9081: "127.0.0.1:9081", 15000: "", 15021: "", 15002: ""} {
+		ctx := &domain.ConnContext{
+			Context:     context.Background(),
+			OriginalDst: "10.0.0.5:15001",
+			Metadata: map[string]any{
+				domain.MetadataListener:        string(proxy.ProfileInboundMTLS),
+				domain.MetadataDestinationPort: port,
+			},
+		}
+
+		var target string
+		err := routing.Handle(ctx, func(ctx *domain.ConnContext) error {
+			target = ctx.GetString(domain.MetadataTargetAddr)
+			return nil
+		})
+		if want == "" {
+			if !domain.IsKind(err, domain.ErrorKindForbidden) {
+				t.Fatalf("port %d: Handle() error = %v, want forbidden", port, err)
+			}
+			continue
+		}
+		if err != nil || target != want {
+			t.Fatalf("port %d: target = %q, error = %v, want %q", port, target, err, want)
+		}
+	}
+}
+
+func TestRoutingKeepsApplicationPortWithoutMTLS(t *testing.T) {
+	const serviceAddr = "10.96.0.10:9000"
+
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{{
+		ServiceKey: serviceAddr,
+		Endpoints:  []domain.Endpoint{{IP: "10.0.0.7", Port: 9000, ServiceName: "reviews.bookinfo.svc.cluster.local", Meshed: true}},
+	}})
+	client := newRoutingMiddleware(cache, "127.0.0.1:8080", []int{8080}, 15006, 15001, false, loadBalancing("roundRobin"), "", 0, nil, nil)
+
+	outbound := &domain.ConnContext{
+		Context:     context.Background(),
+		OriginalDst: serviceAddr,
+		Metadata: map[string]any{
+			domain.MetadataListener:  string(proxy.ProfileOutbound),
+			domain.MetadataDirection: string(domain.DirectionOutbound),
+		},
+	}
+	if err := client.Handle(outbound, func(*domain.ConnContext) error { return nil }); err != nil {
+		t.Fatalf("outbound Handle() error = %v", err)
+	}
+	if got := outbound.GetString(domain.MetadataTargetAddr); got != "10.0.0.7:9000" {
+		t.Fatalf("outbound target = %q, want 10.0.0.7:9000", got)
+	}
+	if outbound.GetBool(domain.MetadataInMesh) {
+		t.Fatal("outbound connection is marked as mTLS with mTLS disabled")
+	}
+
+	// The peer sees the connection on its plain listener with the dialed port as the original destination.
+	server := newRoutingMiddleware(discovery.NewServiceCache(nil), "127.0.0.1:8080", []int{8080, 9000}, 15006, 15001, false, loadBalancing("roundRobin"), "", 0, nil, nil)
+	inbound := &domain.ConnContext{
+		Context:     context.Background(),
+		OriginalDst: outbound.GetString(domain.MetadataTargetAddr),
+		Metadata: map[string]any{
+			domain.MetadataListener:  string(proxy.ProfileInboundPlain),
+			domain.MetadataDirection: string(domain.DirectionInbound),
+		},
+	}
+	if err := server.Handle(inbound, func(*domain.ConnContext) error { return nil }); err != nil {
+		t.Fatalf("inbound Handle() error = %v", err)
+	}
+	if got := inbound.GetString(domain.MetadataTargetAddr); got != "127.0.0.1:9000" {
+		t.Fatalf("inbound target = %q, want 127.0.0.1:9000", got)
+	}
+}
+
+func TestRoutingHoldsEndpointUntilReleased(t *testing.T) {
+	const serviceAddr = "10.96.0.10:9080"
+
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{{
+		ServiceKey: serviceAddr,
+		Endpoints:  []domain.Endpoint{{IP: "10.0.0.1", Port: 9080}},
+	}})
+	routing := newRoutingMiddleware(cache, "127.0.0.1:8080", nil, 15006, 15001, false, loadBalancing("leastRequest"), "", 0, nil, nil)
+
+	newCtx := func() *domain.ConnContext {
+		return &domain.ConnContext{
+			Context:     context.Background(),
+			OriginalDst: serviceAddr,
+			Metadata: map[string]any{
+				domain.MetadataListener:  string(proxy.ProfileOutbound),
+				domain.MetadataDirection: string(domain.DirectionOutbound),
+			},
+		}
+	}
+
+	// A forwarder that hands the response body to the client takes the release with it.
+	var release domain.EndpointRelease
+	err := routing.Handle(newCtx(), func(ctx *domain.ConnContext) error {
+		release, _ = ctx.Metadata[domain.MetadataEndpointRelease].(domain.EndpointRelease)
+		delete(ctx.Metadata, domain.MetadataEndpointRelease)
+		return nil
+	})
+	if err != nil || release == nil {
+		t.Fatalf("Handle() error = %v, release set = %t", err, release != nil)
+	}
+	if routing.inFlight["10.0.0.1:9080"] != 1 {
+		t.Fatalf("inFlight = %v, want the endpoint held until the body is closed", routing.inFlight)
+	}
+
+	release()
+	release()
+	if len(routing.inFlight) != 0 {
+		t.Fatalf("inFlight = %v, want the endpoint released once", routing.inFlight)
+	}
+
+	if err := routing.Handle(newCtx(), func(*domain.ConnContext) error { return nil }); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+	if len(routing.inFlight) != 0 {
+		t.Fatalf("inFlight = %v, want the endpoint released when next returns", routing.inFlight)
+	}
+}
@@ -3,31 +3,37 @@ package sidecar
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
 
 type timeoutMiddleware struct {
-	timeout time.Duration
+	policies *trafficPolicies
 }
 
-func newTimeoutMiddleware(timeout time.Duration) *timeoutMiddleware {
-	return &timeoutMiddleware{timeout: timeout}
+func newTimeoutMiddleware(policies *trafficPolicies) *timeoutMiddleware {
+	return &timeoutMiddleware{policies: policies}
 }
 
 func (m *timeoutMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
-	ctxWithTimeout, cancel := context.WithTimeout(ctx.Context, m.timeout)
+	timeout := m.policies.For(ctx).Timeout
+	if timeout <= 0 {
+		return next(ctx)
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx.Context, timeout)
 	defer cancel()
 
-	nextCtx := ctx.CloneWithContext(ctxWithTimeout)
-	err := next(nextCtx)
+	parent := ctx.Context
+	ctx.Context = ctxWithTimeout
+	err := next(ctx)
+	ctx.Context = parent
 	if err == nil {
 		return nil
 	}
 
 	if ctxWithTimeout.Err() != nil {
-		return domain.Wrap(domain.ErrorKindTimeout, fmt.Errorf("connection establish timed out after %s", m.timeout))
+		return domain.Wrap(domain.ErrorKindTimeout, fmt.Errorf("connection establish timed out after %s", timeout))
 	}
 
 	return err
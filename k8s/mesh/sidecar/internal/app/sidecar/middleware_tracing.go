@@ -0,0 +1,78 @@
+package sidecar
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/tracing"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+type tracingMiddleware struct {
+	tracer *tracing.Tracer
+}
+
+func newTracingMiddleware(tracer *tracing.Tracer) *tracingMiddleware {
+	return &tracingMiddleware{tracer: tracer}
+}
+
+func (m *tracingMiddleware) Handle(ctx *domain.ConnContext, next domain.NextFunc) error {
+	request, ok := ctx.Metadata[domain.MetadataHTTPRequest].(*http.Request)
+	if !ok {
+		return next(ctx)
+	}
+
+	direction := ctx.GetString(domain.MetadataDirection)
+	kind := tracing.SpanKindClient
+	if direction == string(domain.DirectionInbound) {
+		kind = tracing.SpanKindServer
+	}
+
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+
+	span := m.tracer.Start(request.Header, request.Method+" "+host, kind)
+	span.SetAttribute("http.request.method", request.Method)
+	span.SetAttribute("url.path", request.URL.Path)
+	span.SetAttribute("server.address", host)
+	span.SetAttribute("network.protocol.version", protocolVersion(request))
+	span.SetAttribute("mesh.direction", direction)
+
+	err := next(ctx)
+
+	span.SetAttribute("mesh.service", ctx.GetString(domain.MetadataService))
+	span.SetAttribute("mesh.retry_count", ctx.GetInt(domain.MetadataRetryCount))
+	if endpoint := ctx.GetString(domain.MetadataTargetAddr); endpoint != "" {
+		span.SetAttribute("mesh.upstream.address", endpoint)
+	}
+	if identity := ctx.GetString(domain.MetadataPeerIdentity); identity != "" {
+		span.SetAttribute("mesh.peer.identity", identity)
+	}
+	if statusCode, parseErr := strconv.Atoi(ctx.GetString(domain.MetadataStatusCode)); parseErr == nil {
+		span.SetAttribute("http.response.status_code", statusCode)
+		if statusCode >= 500 || (kind == tracing.SpanKindClient && statusCode >= 400) {
+			span.SetError(http.StatusText(statusCode))
+		}
+	}
+	if grpcStatus, parseErr := strconv.Atoi(ctx.GetString(domain.MetadataGRPCStatus)); parseErr == nil {
+		span.SetAttribute("rpc.grpc.status_code", grpcStatus)
+	}
+	if err != nil {
+		errorKind := domain.NormalizeErrorType(err)
+		span.SetAttribute("mesh.error.kind", errorKind)
+		span.SetError(errorKind)
+	}
+
+	m.tracer.Finish(span)
+	return err
+}
+
+func protocolVersion(request *http.Request) string {
+	if request.ProtoMajor == 2 {
+		return "2"
+	}
+
+	return strconv.Itoa(request.ProtoMajor) + "." + strconv.Itoa(request.ProtoMinor)
+}
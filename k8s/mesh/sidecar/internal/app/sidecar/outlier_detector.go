@@ -0,0 +1,165 @@
+package sidecar
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+type outlierEntry struct {
+	service             string
+	connectFailures     int
+	consecutive5xx      int
+	ejections           int
+	ejectedUntil        time.Time
+	lastEjectionEndedAt time.Time
+}
+
+type outlierDetector struct {
+	policy   config.OutlierDetectionPolicy
+	recorder *metrics.Recorder
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*outlierEntry
+}
+
+func newOutlierDetector(policy config.OutlierDetectionPolicy, recorder *metrics.Recorder) *outlierDetector {
+	return &outlierDetector{
+		policy:   policy,
+		recorder: recorder,
+		now:      time.Now,
+		entries:  make(map[string]*outlierEntry),
+	}
+}
+
+func (d *outlierDetector) healthy(endpoints []domain.Endpoint) []domain.Endpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	healthy := make([]domain.Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if !d.ejected(endpointAddr(endpoint), now) {
+			healthy = append(healthy, endpoint)
+		}
+	}
+
+	if len(endpoints) > 0 {
+		d.recorder.SetOutlierEjectedEndpoints(endpoints[0].ServiceName, len(endpoints)-len(healthy))
+	}
+
+	// Ejecting every endpoint would turn a partial outage into a full one.
+	if len(healthy) == 0 {
+		return endpoints
+	}
+
+	return healthy
+}
+
+func (d *outlierDetector) report(endpoint domain.Endpoint, total int, err error, statusCode string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := endpointAddr(endpoint)
+	entry, ok := d.entries[key]
+	if !ok {
+		entry = &outlierEntry{service: endpoint.ServiceName}
+		d.entries[key] = entry
+	}
+
+	now := d.now()
+	switch {
+	case err != nil && domain.IsEstablishError(err) && !domain.IsKind(err, domain.ErrorKindBreakerOpen):
+		entry.connectFailures++
+		if d.policy.ConsecutiveConnectFailures > 0 && entry.connectFailures >= d.policy.ConsecutiveConnectFailures {
+			d.eject(key, entry, total, now, "connect_failure")
+		}
+	case err == nil && strings.HasPrefix(statusCode, "5"):
+		entry.connectFailures = 0
+		entry.consecutive5xx++
+		if d.policy.Consecutive5xx > 0 && entry.consecutive5xx >= d.policy.Consecutive5xx {
+			d.eject(key, entry, total, now, "5xx")
+		}
+	case err == nil:
+		entry.connectFailures = 0
+		entry.consecutive5xx = 0
+		if entry.ejections > 0 && !entry.lastEjectionEndedAt.IsZero() && now.Sub(entry.lastEjectionEndedAt) >= d.policy.BaseEjectionTime {
+			entry.ejections = 0
+		}
+	}
+}
+
+func (d *outlierDetector) eject(key string, entry *outlierEntry, total int, now time.Time, reason string) {
+	if now.Before(entry.ejectedUntil) {
+		return
+	}
+
+	if !d.canEject(entry.service, total, now) {
+		slog.Debug("outlier ejection skipped: max ejection percent reached", slog.String("endpoint", key), slog.String("service", entry.service))
+		return
+	}
+
+	entry.ejections++
+	entry.connectFailures = 0
+	entry.consecutive5xx = 0
+
+	duration := d.policy.BaseEjectionTime << min(entry.ejections-1, 16)
+	if duration <= 0 || duration > d.policy.MaxEjectionTime {
+		duration = d.policy.MaxEjectionTime
+	}
+	entry.ejectedUntil = now.Add(duration)
+	entry.lastEjectionEndedAt = entry.ejectedUntil
+
+	d.recorder.IncOutlierEjection(entry.service, reason)
+	slog.Info(
+		"endpoint ejected",
+		slog.String("endpoint", key),
+		slog.String("service", entry.service),
+		slog.String("reason", reason),
+		slog.Duration("duration", duration),
+	)
+}
+
+func (d *outlierDetector) canEject(service string, total int, now time.Time) bool {
+	ejected := 0
+	for _, entry := range d.entries {
+		if entry.service == service && now.Before(entry.ejectedUntil) {
+			ejected++
+		}
+	}
+
+	// Like Envoy, at least one endpoint may be ejected regardless of the percentage.
+	allowed := max(total*d.policy.MaxEjectionPercent/100, 1)
+	return ejected < allowed
+}
+
+func (d *outlierDetector) ejected(key string, now time.Time) bool {
+	entry, ok := d.entries[key]
+	return ok && now.Before(entry.ejectedUntil)
+}
+
+// prune forgets endpoints that discovery no longer reports, so ejection state
+// does not pile up as pods come and go.
+func (d *outlierDetector) prune(endpoints map[string][]domain.Endpoint) {
+	current := make(map[string]struct{})
+	for _, serviceEndpoints := range endpoints {
+		for _, endpoint := range serviceEndpoints {
+			current[endpointAddr(endpoint)] = struct{}{}
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key := range d.entries {
+		if _, ok := current[key]; !ok {
+			delete(d.entries, key)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package sidecar
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func TestOutlierDetectorEjectsFailingEndpoint(t *testing.T) {
+	endpoints := []domain.Endpoint{
+		{IP: "10.0.0.1", Port: 9080, ServiceName: "reviews"},
+		{IP: "10.0.0.2", Port: 9080, ServiceName: "reviews"},
+	}
+
+	now := time.Unix(0, 0)
+	detector := newOutlierDetector(config.OutlierDetectionPolicy{
+		ConsecutiveConnectFailures: 2,
+		Consecutive5xx:             3,
+		BaseEjectionTime:           10 * time.Second,
+		MaxEjectionTime:            30 * time.Second,
+		MaxEjectionPercent:         50,
+	}, metrics.NewRecorder())
+	detector.now = func() time.Time { return now }
+
+	dialErr := domain.Wrap(domain.ErrorKindDial, errors.New("connection refused"))
+	failFor := func(endpoint domain.Endpoint) {
+		for range 2 {
+			detector.report(endpoint, len(endpoints), dialErr, "")
+		}
+	}
+
+	failFor(endpoints[0])
+	if got := detector.healthy(endpoints); len(got) != 1 || got[0].IP != "10.0.0.2" {
+		t.Fatalf("healthy() = %v, want only 10.0.0.2", got)
+	}
+
+	failFor(endpoints[1])
+	if got := detector.healthy(endpoints); len(got) != 1 {
+		t.Fatalf("healthy() = %v, max ejection percent must keep one endpoint", got)
+	}
+
+	now = now.Add(10 * time.Second)
+	if got := detector.healthy(endpoints); len(got) != 2 {
+		t.Fatalf("healthy() after base ejection time = %v, want both endpoints", got)
+	}
+
+	failFor(endpoints[0])
+	now = now.Add(10 * time.Second)
+	if got := detector.healthy(endpoints); len(got) != 1 {
+		t.Fatalf("healthy() = %v, second ejection must last twice as long", got)
+	}
+
+	now = now.Add(10 * time.Second)
+	for range 3 {
+		detector.report(endpoints[0], len(endpoints), nil, "503")
+	}
+	if got := detector.healthy(endpoints); len(got) != 1 || got[0].IP != "10.0.0.2" {
+		t.Fatalf("healthy() after consecutive 5xx = %v, want only 10.0.0.2", got)
+	}
+
+	now = now.Add(30 * time.Second)
+	detector.report(endpoints[0], len(endpoints), nil, "200")
+	if got := detector.healthy(endpoints); len(got) != 2 {
+		t.Fatalf("healthy() after max ejection time = %v, want both endpoints", got)
+	}
+}
+
+func TestOutlierDetectorForgetsRemovedEndpoints(t *testing.T) {
+	endpoints := []domain.Endpoint{
+		{IP: "10.0.0.1", Port: 9080, ServiceName: "reviews"},
+		{IP: "10.0.0.2", Port: 9080, ServiceName: "reviews"},
+	}
+
+	detector := newOutlierDetector(config.OutlierDetectionPolicy{
+		ConsecutiveConnectFailures: 1,
+		BaseEjectionTime:           10 * time.Second,
+		MaxEjectionTime:            30 * time.Second,
+		MaxEjectionPercent:         50,
+	}, metrics.NewRecorder())
+
+	dialErr := domain.Wrap(domain.ErrorKindDial, errors.New("connection refused"))
+	for _, endpoint := range endpoints {
+		detector.report(endpoint, len(endpoints), dialErr, "")
+	}
+
+	cache := discovery.NewServiceCache(nil)
+	cache.OnReplace(detector.prune)
+	cache.Replace([]discovery.CachedService{{ServiceKey: "reviews:9080", Endpoints: endpoints[1:]}})
+
+	if _, ok := detector.entries["10.0.0.1:9080"]; ok || len(detector.entries) != 1 {
+		t.Fatalf("entries = %v, want only the endpoint still in discovery", detector.entries)
+	}
+
+	cache.Replace(nil)
+	if len(detector.entries) != 0 {
+		t.Fatalf("entries = %v, want none after the service is removed", detector.entries)
+	}
+}
@@ -0,0 +1,129 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	readinessPath = "/healthz/ready"
+	drainPath     = "/drain"
+)
+
+type readiness struct {
+	ready     atomic.Bool
+	appProbes *appProber
+
+	drainOnce sync.Once
+	drain     chan struct{}
+}
+
+func newReadiness(appProbes *appProber) *readiness {
+	return &readiness{appProbes: appProbes, drain: make(chan struct{})}
+}
+
+func (r *readiness) set(ready bool) {
+	r.ready.Store(ready)
+}
+
+func (r *readiness) requestDrain() {
+	r.drainOnce.Do(func() { close(r.drain) })
+}
+
+func (r *readiness) drainRequested() <-chan struct{} {
+	return r.drain
+}
+
+func (r *readiness) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+readinessPath, func(w http.ResponseWriter, _ *http.Request) {
+		if !r.ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte("ready\n"))
+	})
+	if r.appProbes != nil {
+		mux.Handle("GET "+appHealthPath, r.appProbes)
+	}
+	// The status port is reachable from outside the pod, only the preStop hook may start a drain.
+	mux.HandleFunc("POST "+drainPath, func(w http.ResponseWriter, request *http.Request) {
+		host, _, err := net.SplitHostPort(request.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		r.requestDrain()
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	return mux
+}
+
+// WaitReady polls the local readiness endpoint; it backs the postStart hook
+// that holds application containers until the proxy is ready.
+func WaitReady(ctx context.Context, statusPort int, interval time.Duration) error {
+	url := "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(statusPort)) + readinessPath
+	client := &http.Client{Timeout: interval}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		response, err := client.Do(request)
+		if err == nil {
+			_ = response.Body.Close()
+			if response.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("sidecar is not ready: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain starts the drain of the local sidecar and waits for its duration;
+// it backs the preStop hook, so SIGTERM arrives once clients have moved away.
+func Drain(ctx context.Context, statusPort int, duration time.Duration) error {
+	url := "http://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(statusPort)) + drainPath
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("request drain: %w", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("request drain: unexpected status %d", response.StatusCode)
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
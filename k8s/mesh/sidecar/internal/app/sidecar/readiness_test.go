@@ -0,0 +1,36 @@
+package sidecar
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWaitReadyReturnsOnceProxyIsReady(t *testing.T) {
+	state := &readiness{}
+	server := httptest.NewServer(state.Handler())
+	defer server.Close()
+
+	_, portText, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("split server address: %v", err)
+	}
+	port, _ := strconv.Atoi(portText)
+
+	shortCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := WaitReady(shortCtx, port, 10*time.Millisecond); err == nil {
+		t.Fatal("WaitReady() succeeded before the proxy became ready")
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { state.set(true) })
+
+	ctx, cancelWait := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelWait()
+	if err := WaitReady(ctx, port, 10*time.Millisecond); err != nil {
+		t.Fatalf("WaitReady() error = %v", err)
+	}
+}
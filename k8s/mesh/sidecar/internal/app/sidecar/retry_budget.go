@@ -0,0 +1,98 @@
+package sidecar
+
+import (
+	"sync"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+)
+
+const retryBudgetBuckets = 10
+
+// retryBudget counts requests and retries per destination service over a
+// sliding window split into buckets. Connection and HTTP retries share it.
+type retryBudget struct {
+	mu       sync.Mutex
+	services map[string]*retryBudgetWindow
+	now      func() time.Time
+}
+
+type retryBudgetWindow struct {
+	width   time.Duration
+	buckets [retryBudgetBuckets]retryBudgetBucket
+}
+
+type retryBudgetBucket struct {
+	epoch    int64
+	requests int
+	retries  int
+}
+
+func newRetryBudget() *retryBudget {
+	return &retryBudget{
+		services: make(map[string]*retryBudgetWindow),
+		now:      time.Now,
+	}
+}
+
+// recordRequest counts a request or connection that may be retried later.
+func (b *retryBudget) recordRequest(service string, policy config.RetryBudget) {
+	if policy.Window <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bucket(service, policy).requests++
+}
+
+// allowRetry reports whether one more retry fits the budget and counts it.
+func (b *retryBudget) allowRetry(service string, policy config.RetryBudget) bool {
+	if policy.Window <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.bucket(service, policy)
+	window := b.services[service]
+	epoch := current.epoch
+
+	var requests, retries int
+	for _, bucket := range window.buckets {
+		if epoch-bucket.epoch < retryBudgetBuckets {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	allowed := max(float64(requests)*policy.Percent/100, float64(policy.MinRetriesPerSecond)*policy.Window.Seconds())
+	if float64(retries) >= allowed {
+		return false
+	}
+
+	current.retries++
+	return true
+}
+
+// bucket returns the current bucket of the service. The caller must hold b.mu.
+func (b *retryBudget) bucket(service string, policy config.RetryBudget) *retryBudgetBucket {
+	width := max(policy.Window/retryBudgetBuckets, time.Millisecond)
+
+	window, ok := b.services[service]
+	if !ok || window.width != width {
+		// A reload that changes the window starts the service from scratch.
+		window = &retryBudgetWindow{width: width}
+		b.services[service] = window
+	}
+
+	epoch := b.now().UnixNano() / int64(width)
+	bucket := &window.buckets[epoch%retryBudgetBuckets]
+	if bucket.epoch != epoch {
+		*bucket = retryBudgetBucket{epoch: epoch}
+	}
+
+	return bucket
+}
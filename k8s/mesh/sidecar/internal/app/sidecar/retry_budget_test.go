@@ -0,0 +1,47 @@
+package sidecar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+)
+
+func TestRetryBudgetLimitsRetriesOverSlidingWindow(t *testing.T) {
+	const service = "reviews.bookinfo.svc.cluster.local"
+
+	now := time.Unix(0, 0)
+	budget := newRetryBudget()
+	budget.now = func() time.Time { return now }
+	policy := config.RetryBudget{Percent: 20, Window: 10 * time.Second}
+
+	for range 10 {
+		budget.recordRequest(service, policy)
+	}
+	for i := range 2 {
+		if !budget.allowRetry(service, policy) {
+			t.Fatalf("allowRetry() #%d = false, want true within 20%% of 10 requests", i+1)
+		}
+	}
+	if budget.allowRetry(service, policy) {
+		t.Fatal("allowRetry() = true, want the budget exhausted")
+	}
+	if !budget.allowRetry("ratings.bookinfo.svc.cluster.local", config.RetryBudget{Percent: 20, MinRetriesPerSecond: 1, Window: 10 * time.Second}) {
+		t.Fatal("allowRetry() for another service = false, want the floor to allow it")
+	}
+
+	now = now.Add(11 * time.Second)
+	for range 5 {
+		budget.recordRequest(service, policy)
+	}
+	if !budget.allowRetry(service, policy) {
+		t.Fatal("allowRetry() after the window = false, want old retries expired")
+	}
+	if budget.allowRetry(service, policy) {
+		t.Fatal("allowRetry() = true, want old requests expired too")
+	}
+
+	if !budget.allowRetry(service, config.RetryBudget{Percent: 20}) {
+		t.Fatal("allowRetry() with a zero window = false, want the budget disabled")
+	}
+}
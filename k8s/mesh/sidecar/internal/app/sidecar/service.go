@@ -8,12 +8,18 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/accesslog"
 	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/meshconfig"
 	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/policy"
 	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/tracing"
 	"github.com/LLIEPJIOK/sidecar/internal/config"
 	"github.com/LLIEPJIOK/sidecar/internal/domain"
 )
@@ -22,11 +28,26 @@ type Service struct {
 	cfg             config.Config
 	discovery       *discovery.Controller
 	cache           *discovery.ServiceCache
+	policies        *policy.Controller
+	authorization   *policy.AuthorizationStore
+	peerAuth        *policy.PeerAuthenticationStore
+	traffic         *trafficPolicies
+	configWatcher   *meshconfig.Watcher
 	metricsRecorder *metrics.Recorder
+	logLevel        *slog.LevelVar
+	readiness       *readiness
 }
 
-func New(cfg config.Config) (*Service, error) {
-	metricsRecorder := metrics.NewRecorder()
+func New(cfg config.Config, logLevel *slog.LevelVar) (*Service, error) {
+	droppedLabels, err := cfg.DroppedMetricLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	metricsRecorder, err := metrics.NewRecorderWithDroppedLabels(droppedLabels)
+	if err != nil {
+		return nil, fmt.Errorf("initialize metrics: %w", err)
+	}
 	cache := discovery.NewServiceCache(metricsRecorder)
 
 	clientset, err := discovery.NewClientset(cfg.KubeConfigPath)
@@ -34,24 +55,62 @@ func New(cfg config.Config) (*Service, error) {
 		return nil, fmt.Errorf("initialize discovery client: %w", err)
 	}
 
+	dynamicClient, err := discovery.NewDynamicClient(cfg.KubeConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("initialize policy client: %w", err)
+	}
+
 	controller := discovery.NewController(clientset, cfg.Namespace, cache)
+	authorization := policy.NewAuthorizationStore()
+	peerAuth := policy.NewPeerAuthenticationStore(policy.MTLSMode(cfg.MTLSMode), policy.MTLSMode(cfg.WorkloadMTLSMode))
+	traffic := newTrafficPolicies(cfg.TrafficPolicy(), metricsRecorder)
+	policies := policy.NewController(clientset, dynamicClient, cfg.Namespace, cfg.PodName, authorization, peerAuth, traffic)
+
+	var configWatcher *meshconfig.Watcher
+	if cfg.ConfigMapName != "" {
+		configWatcher = meshconfig.NewWatcher(clientset, cfg.ConfigMapNamespace, cfg.ConfigMapName, traffic.apply)
+	}
 
 	return &Service{
 		cfg:             cfg,
 		discovery:       controller,
 		cache:           cache,
+		policies:        policies,
+		authorization:   authorization,
+		peerAuth:        peerAuth,
+		traffic:         traffic,
+		configWatcher:   configWatcher,
 		metricsRecorder: metricsRecorder,
+		logLevel:        logLevel,
+		readiness:       newReadiness(newAppProber(cfg.AppProbes)),
 	}, nil
 }
 
 func (s *Service) Run(ctx context.Context) error {
 	var (
-		tlsConfig *tls.Config
-		err       error
+		tlsConfig    *tls.Config
+		certificates *certificateManager
+		err          error
 	)
 
+	// Probes see 503 instead of a refused connection while the proxy bootstraps.
+	statusListener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.StatusPort))
+	if err != nil {
+		return fmt.Errorf("listen status port: %w", err)
+	}
+	statusServer := &http.Server{Handler: s.readiness.Handler()}
+	go func() {
+		if serveErr := statusServer.Serve(statusListener); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+			slog.Error("status server failed", slog.Any("error", serveErr))
+		}
+	}()
+	defer func() {
+		_ = shutdownServer(statusServer, s.cfg.ShutdownTimeout)
+	}()
+
 	if s.cfg.InboundMTLSPort > 0 {
-		tlsConfig, err = bootstrapTLSConfig(ctx, s.cfg)
+		certificates = newCertificateManager(s.cfg, s.metricsRecorder)
+		tlsConfig, err = certificates.Bootstrap(ctx)
 		if err != nil {
 			return fmt.Errorf("bootstrap tls config: %w", err)
 		}
@@ -61,57 +120,163 @@ func (s *Service) Run(ctx context.Context) error {
 		return fmt.Errorf("initial discovery sync failed: %w", err)
 	}
 
+	if err := s.policies.InitialSync(ctx); err != nil {
+		return fmt.Errorf("initial policy sync failed: %w", err)
+	}
+
+	if s.configWatcher != nil {
+		// The environment carries the same settings, so the sidecar can start without the ConfigMap.
+		if err := s.configWatcher.Sync(ctx); err != nil {
+			slog.Warn("initial sidecar config sync failed, using environment settings", slog.Any("error", err))
+		}
+	}
+
 	listeners, err := s.buildListeners(tlsConfig)
 	if err != nil {
 		return err
 	}
 	defer closeListeners(listeners)
 
+	connections := newConnectionCounter()
+	for _, listener := range listeners {
+		connections.add(string(listener.Profile()), 0)
+	}
+
+	zone := s.localZone(ctx)
+
 	forwarder := proxy.NewForwarder(tlsConfig, s.cfg.DialTimeout, proxy.CopyMode(s.cfg.CopyMode))
-	middlewares := []domain.Handler{
-		newMetricsMiddleware(s.metricsRecorder),
+
+	health := newHealthChecker(
+		s.cache,
+		forwarder,
+		s.cfg.HealthCheck,
+		s.cfg.InboundMTLSPort,
+		s.cfg.InboundMTLSPort > 0,
+		s.metricsRecorder,
+	)
+
+	var outliers *outlierDetector
+	if s.cfg.OutlierDetection.Enabled() {
+		outliers = newOutlierDetector(s.cfg.OutlierDetection, s.metricsRecorder)
+		s.cache.OnReplace(outliers.prune)
 	}
 
-	if s.cfg.Timeout > 0 {
-		middlewares = append(middlewares, newTimeoutMiddleware(s.cfg.Timeout))
+	routing := newRoutingMiddleware(
+		s.cache,
+		s.cfg.AppTargetAddr,
+		s.cfg.ApplicationPorts(),
+		s.cfg.InboundPlainPort,
+		s.cfg.InboundMTLSPort,
+		s.cfg.InboundMTLSPort > 0,
+		s.traffic,
+		zone,
+		s.cfg.LocalityRouting.SpilloverThreshold,
+		outliers,
+		health,
+	)
+
+	// Breaker, timeout and retry stay in the chains even when disabled: a reload may enable them.
+	breaker := newBreakerMiddleware(s.traffic, s.metricsRecorder)
+	timeout := newTimeoutMiddleware(s.traffic)
+	budget := newRetryBudget()
+
+	workloads := newWorkloadResolver(s.cache, s.cfg.PodName, s.cfg.Namespace, s.cfg.ServiceAccount, s.cfg.TrustDomain)
+
+	var accessLog []domain.Handler
+	if s.cfg.AccessLog.Enabled {
+		logger, err := accesslog.NewLogger(accesslog.Options{
+			Format:       s.cfg.AccessLog.Format,
+			Template:     s.cfg.AccessLog.Template,
+			Path:         s.cfg.AccessLog.Path,
+			MaxSizeMB:    s.cfg.AccessLog.MaxSizeMB,
+			MaxBackups:   s.cfg.AccessLog.MaxBackups,
+			SamplingRate: s.cfg.AccessLog.SamplingRate,
+			Services:     s.cfg.AccessLog.ServiceList(),
+		})
+		if err != nil {
+			return fmt.Errorf("initialize access log: %w", err)
+		}
+		defer logger.Close()
+
+		accessLog = append(accessLog, newAccessLogMiddleware(logger))
 	}
 
-	middlewares = append(middlewares, newRetryMiddleware(
-		s.cfg.RetryPolicy.Attempts,
-		s.cfg.RetryPolicy.BackoffType,
-		s.cfg.RetryPolicy.BaseInterval,
-		s.metricsRecorder,
-	),
-		newRoutingMiddleware(
-			s.cache,
-			s.cfg.AppTargetAddr,
-			s.cfg.InboundPlainPort,
-			s.cfg.InboundMTLSPort,
-			s.cfg.InboundMTLSPort > 0,
-			s.cfg.LoadBalancerConfig.Algorithm,
-		),
+	middlewares := append(slices.Clone(accessLog), newMetricsMiddleware(s.metricsRecorder, workloads))
+	if tlsConfig != nil {
+		middlewares = append(middlewares, newPeerAuthenticationMiddleware(s.peerAuth, tlsConfig))
+	}
+	middlewares = append(middlewares,
+		newIdentityMiddleware(s.cfg.DialTimeout),
+		newProtocolMiddleware(s.cache),
+		newDestinationMiddleware(s.cache),
+		timeout,
+		newRetryMiddleware(s.traffic, budget, s.metricsRecorder),
+		routing,
+		newAuthorizationMiddleware(s.authorization, s.metricsRecorder),
+		breaker,
 	)
 
-	if s.cfg.CircuitBreakerPolicy.FailureThreshold > 0 {
-		middlewares = append(middlewares, newBreakerMiddleware(
-			s.cfg.CircuitBreakerPolicy.FailureThreshold,
-			s.cfg.CircuitBreakerPolicy.RecoveryTime,
-			s.metricsRecorder,
-		))
+	var exporter *tracing.Exporter
+	requestMiddlewares := append(slices.Clone(accessLog), newMetricsMiddleware(s.metricsRecorder, workloads))
+	passthroughMiddlewares := append(slices.Clone(accessLog), newMetricsMiddleware(s.metricsRecorder, workloads))
+	if s.cfg.Tracing.Endpoint != "" {
+		exporter = tracing.NewExporter(s.cfg.Tracing.Endpoint, workloads.resource())
+		tracer := newTracingMiddleware(tracing.NewTracer(s.cfg.Tracing.SamplingRate, exporter))
+		requestMiddlewares = append(requestMiddlewares, tracer)
+		passthroughMiddlewares = append(passthroughMiddlewares, tracer)
 	}
+	requestMiddlewares = append(requestMiddlewares, timeout, newHTTPRetryMiddleware(s.traffic, budget, s.metricsRecorder), routing, breaker)
+	forwarder.RequestChain = domain.Chain(requestMiddlewares...)
+	forwarder.PassthroughChain = domain.Chain(passthroughMiddlewares...)
 
 	chain := domain.Chain(middlewares...)
 
 	runCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	errCh := make(chan error, len(listeners)+2)
+	errCh := make(chan error, len(listeners)+5)
 	go func() {
 		if runErr := s.discovery.Run(runCtx); runErr != nil && !errors.Is(runErr, context.Canceled) {
 			nonBlockingSend(errCh, fmt.Errorf("discovery watch loop failed: %w", runErr))
 		}
 	}()
 
+	go func() {
+		if runErr := s.policies.Run(runCtx); runErr != nil && !errors.Is(runErr, context.Canceled) {
+			nonBlockingSend(errCh, fmt.Errorf("policy watch loop failed: %w", runErr))
+		}
+	}()
+
+	go func() {
+		if runErr := health.Run(runCtx); runErr != nil && !errors.Is(runErr, context.Canceled) {
+			nonBlockingSend(errCh, fmt.Errorf("health check loop failed: %w", runErr))
+		}
+	}()
+
+	if exporter != nil {
+		go func() {
+			if runErr := exporter.Run(runCtx); runErr != nil && !errors.Is(runErr, context.Canceled) {
+				nonBlockingSend(errCh, fmt.Errorf("span exporter failed: %w", runErr))
+			}
+		}()
+	}
+
+	if s.configWatcher != nil {
+		go func() {
+			if runErr := s.configWatcher.Run(runCtx); runErr != nil && !errors.Is(runErr, context.Canceled) {
+				nonBlockingSend(errCh, fmt.Errorf("sidecar config watch loop failed: %w", runErr))
+			}
+		}()
+	}
+
+	if certificates != nil {
+		go func() {
+			if runErr := certificates.Run(runCtx); runErr != nil && !errors.Is(runErr, context.Canceled) {
+				nonBlockingSend(errCh, fmt.Errorf("certificate rotation loop failed: %w", runErr))
+			}
+		}()
+	}
+
 	var metricsServer *http.Server
 	if s.cfg.MonitoringEnabled {
 		metricsServer = &http.Server{
@@ -126,30 +291,98 @@ func (s *Service) Run(ctx context.Context) error {
 		}()
 	}
 
+	var adminHTTPServer *http.Server
+	if s.cfg.AdminPort > 0 {
+		admin := &adminServer{
+			cfg:          s.cfg,
+			cache:        s.cache,
+			breaker:      breaker,
+			traffic:      s.traffic,
+			certificates: certificates,
+			connections:  connections,
+			logLevel:     s.logLevel,
+		}
+		adminHTTPServer = &http.Server{
+			Addr:    net.JoinHostPort("127.0.0.1", strconv.Itoa(s.cfg.AdminPort)),
+			Handler: admin.Handler(),
+		}
+
+		go func() {
+			if runErr := adminHTTPServer.ListenAndServe(); runErr != nil && !errors.Is(runErr, http.ErrServerClosed) {
+				nonBlockingSend(errCh, fmt.Errorf("admin server failed: %w", runErr))
+			}
+		}()
+	}
+
 	var acceptWG sync.WaitGroup
 	var connectionWG sync.WaitGroup
 	for _, listener := range listeners {
 		acceptWG.Add(1)
-		go s.runListener(runCtx, &acceptWG, &connectionWG, listener, chain, forwarder.Handle)
+		go s.runListener(runCtx, &acceptWG, &connectionWG, connections, listener, chain, forwarder.Handle)
 	}
 
-	var runErr error
-	select {
-	case <-ctx.Done():
-		cancel()
-		runErr = ctx.Err()
-	case runErr = <-errCh:
-		cancel()
+	s.readiness.set(true)
+	slog.Info("sidecar is ready")
+
+	var (
+		runErr        error
+		drainDeadline time.Time
+	)
+	startDrain := func() {
+		if !drainDeadline.IsZero() {
+			return
+		}
+
+		// Listeners stay open: the pod may still get new connections until it
+		// is removed from the endpoints.
+		drainDeadline = time.Now().Add(s.cfg.DrainDuration)
+		s.readiness.set(false)
+		forwarder.Drain()
+		slog.Info("sidecar is draining", slog.Duration("drain_duration", s.cfg.DrainDuration))
+	}
+
+	drainRequested := s.readiness.drainRequested()
+wait:
+	for {
+		select {
+		case <-drainRequested:
+			drainRequested = nil
+			startDrain()
+		case <-ctx.Done():
+			runErr = ctx.Err()
+			break wait
+		case runErr = <-errCh:
+			break wait
+		}
 	}
 
+	if ctx.Err() != nil {
+		startDrain()
+
+		timer := time.NewTimer(time.Until(drainDeadline))
+		select {
+		case <-timer.C:
+		case err := <-errCh:
+			slog.Warn("drain interrupted", slog.Any("error", err))
+		}
+		timer.Stop()
+	}
+
+	cancel()
+	s.readiness.set(false)
+
 	closeListeners(listeners)
 
 	if metricsServer != nil {
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
-		if err := metricsServer.Shutdown(shutdownCtx); err != nil && runErr == nil {
+		if err := shutdownServer(metricsServer, s.cfg.ShutdownTimeout); err != nil && runErr == nil {
 			runErr = fmt.Errorf("shutdown metrics server: %w", err)
 		}
-		shutdownCancel()
+	}
+
+	if adminHTTPServer != nil {
+		if err := shutdownServer(adminHTTPServer, s.cfg.ShutdownTimeout); err != nil && runErr == nil {
+			runErr = fmt.Errorf("shutdown admin server: %w", err)
+		}
 	}
 
 	waitForAcceptLoops(&acceptWG, s.cfg.ShutdownTimeout)
@@ -160,6 +393,33 @@ func (s *Service) Run(ctx context.Context) error {
 	return runErr
 }
 
+func (s *Service) localZone(ctx context.Context) string {
+	if !s.cfg.LocalityRouting.Enabled {
+		return ""
+	}
+
+	if s.cfg.Zone != "" {
+		return s.cfg.Zone
+	}
+
+	if s.cfg.NodeName == "" {
+		slog.Warn("locality routing disabled: node name is unknown")
+		return ""
+	}
+
+	zone, err := s.discovery.NodeZone(ctx, s.cfg.NodeName)
+	if err != nil {
+		slog.Warn("locality routing disabled: failed to resolve node zone", slog.String("node", s.cfg.NodeName), slog.Any("error", err))
+		return ""
+	}
+
+	if zone == "" {
+		slog.Info("locality routing disabled: node has no zone label", slog.String("node", s.cfg.NodeName))
+	}
+
+	return zone
+}
+
 func (s *Service) buildListeners(tlsConfig *tls.Config) ([]*proxy.TransparentListener, error) {
 	inboundPlain, err := proxy.NewTCPListener(
 		fmt.Sprintf(":%d", s.cfg.InboundPlainPort),
@@ -207,6 +467,7 @@ func (s *Service) runListener(
 	ctx context.Context,
 	acceptWG *sync.WaitGroup,
 	connectionWG *sync.WaitGroup,
+	connections *connectionCounter,
 	listener *proxy.TransparentListener,
 	chain domain.Handler,
 	terminal domain.NextFunc,
@@ -225,8 +486,10 @@ func (s *Service) runListener(
 		}
 
 		connectionWG.Add(1)
+		connections.add(string(listener.Profile()), 1)
 		go func(connectionCtx *domain.ConnContext) {
 			defer connectionWG.Done()
+			defer connections.add(string(listener.Profile()), -1)
 			defer connectionCtx.ClientConn.Close()
 
 			if handleErr := chain.Handle(connectionCtx, terminal); handleErr != nil && !errors.Is(handleErr, context.Canceled) {
@@ -264,6 +527,13 @@ func waitForConnections(connectionWG *sync.WaitGroup, timeout time.Duration) err
 	}
 }
 
+func shutdownServer(server *http.Server, timeout time.Duration) error {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return server.Shutdown(shutdownCtx)
+}
+
 func closeListeners(listeners []*proxy.TransparentListener) {
 	for _, listener := range listeners {
 		_ = listener.Close()
@@ -0,0 +1,152 @@
+package sidecar
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/policy"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+const sidecarConfigKey = "sidecar.yaml"
+
+// trafficPolicies holds the retry, timeout, breaker and load balancer
+// settings. Middlewares load them per connection, so a reload only affects
+// new connections and requests.
+type trafficPolicies struct {
+	base     config.TrafficPolicy
+	recorder *metrics.Recorder
+
+	mu           sync.Mutex
+	global       config.TrafficPolicy
+	destinations []policy.DestinationPolicy
+	current      atomic.Pointer[trafficPolicySnapshot]
+}
+
+// trafficPolicySnapshot maps "service:port" and "service" keys to the
+// effective policy of a destination.
+type trafficPolicySnapshot struct {
+	global       config.TrafficPolicy
+	destinations map[string]config.TrafficPolicy
+}
+
+func newTrafficPolicies(base config.TrafficPolicy, recorder *metrics.Recorder) *trafficPolicies {
+	policies := &trafficPolicies{base: base, recorder: recorder, global: base}
+	policies.current.Store(&trafficPolicySnapshot{global: base})
+
+	return policies
+}
+
+// Load returns the sidecar-wide policy.
+func (p *trafficPolicies) Load() config.TrafficPolicy {
+	return p.current.Load().global
+}
+
+// For returns the policy of the destination in MetadataService, falling back
+// to the sidecar-wide one.
+func (p *trafficPolicies) For(ctx *domain.ConnContext) config.TrafficPolicy {
+	snapshot := p.current.Load()
+	service := ctx.GetString(domain.MetadataService)
+	if service == "" || len(snapshot.destinations) == 0 {
+		return snapshot.global
+	}
+
+	if _, port, err := net.SplitHostPort(ctx.OriginalDst); err == nil {
+		if policy, ok := snapshot.destinations[service+":"+port]; ok {
+			return policy
+		}
+	}
+
+	if policy, ok := snapshot.destinations[service]; ok {
+		return policy
+	}
+
+	return snapshot.global
+}
+
+// apply backs the ConfigMap watcher. Fields missing from the document fall
+// back to the environment; an invalid document keeps the last good policy.
+func (p *trafficPolicies) apply(version string, data map[string]string) {
+	global, err := p.parse(data)
+	if err != nil {
+		p.recorder.ObserveConfigReload("rejected")
+		slog.Warn("rejected sidecar config, keeping the last good one", slog.String("version", version), slog.Any("error", err))
+		return
+	}
+
+	p.mu.Lock()
+	p.global = global
+	p.publish()
+	p.mu.Unlock()
+
+	p.recorder.ObserveConfigReload("applied")
+	p.recorder.SetConfigVersion(version)
+	slog.Info("applied sidecar config", slog.String("version", version))
+}
+
+// ReplaceDestinationPolicies backs the TrafficPolicy controller.
+func (p *trafficPolicies) ReplaceDestinationPolicies(destinations []policy.DestinationPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The controller relists every few seconds; skip identical lists so
+	// invalid policies are not reported on every relist.
+	if slices.EqualFunc(p.destinations, destinations, equalDestinationPolicy) {
+		return
+	}
+
+	p.destinations = destinations
+	p.publish()
+}
+
+// publish resolves every destination policy on top of the sidecar-wide one.
+// The caller must hold p.mu.
+func (p *trafficPolicies) publish() {
+	snapshot := &trafficPolicySnapshot{
+		global:       p.global,
+		destinations: make(map[string]config.TrafficPolicy, len(p.destinations)),
+	}
+
+	for _, destination := range p.destinations {
+		key := destination.Service
+		if destination.Port > 0 {
+			key += ":" + strconv.Itoa(destination.Port)
+		}
+
+		if _, exists := snapshot.destinations[key]; exists {
+			slog.Warn("skipping conflicting traffic policy", slog.String("policy", destination.Name), slog.String("destination", key))
+			continue
+		}
+
+		resolved, err := config.ParseTrafficPolicy(destination.Spec, p.global)
+		if err != nil {
+			slog.Warn("skipping invalid traffic policy", slog.String("policy", destination.Name), slog.Any("error", err))
+			continue
+		}
+
+		snapshot.destinations[key] = resolved
+	}
+
+	p.current.Store(snapshot)
+}
+
+func (p *trafficPolicies) parse(data map[string]string) (config.TrafficPolicy, error) {
+	document, ok := data[sidecarConfigKey]
+	if !ok {
+		return config.TrafficPolicy{}, fmt.Errorf("key %s is missing", sidecarConfigKey)
+	}
+
+	return config.ParseTrafficPolicy([]byte(document), p.base)
+}
+
+func equalDestinationPolicy(a, b policy.DestinationPolicy) bool {
+	return a.Name == b.Name && a.Service == b.Service && a.Port == b.Port && bytes.Equal(a.Spec, b.Spec)
+}
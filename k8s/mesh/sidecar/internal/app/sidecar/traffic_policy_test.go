@@ -0,0 +1,160 @@
+package sidecar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/discovery"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/meshconfig"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/metrics"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/policy"
+	"github.com/LLIEPJIOK/sidecar/internal/adapters/proxy"
+	"github.com/LLIEPJIOK/sidecar/internal/config"
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+func TestTrafficPolicyReloadKeepsLastGoodConfig(t *testing.T) {
+	base := config.TrafficPolicy{
+		LoadBalancerAlgorithm: "roundRobin",
+		RetryPolicy:           config.RetryPolicy{Attempts: 3, BackoffType: "exponential", BaseInterval: 100 * time.Millisecond},
+		Timeout:               5 * time.Second,
+		CircuitBreakerPolicy:  config.CircuitBreakerPolicy{FailureThreshold: 5, RecoveryTime: 30 * time.Second},
+	}
+	traffic := newTrafficPolicies(base, metrics.NewRecorder())
+
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "mesh-sidecar-config", Namespace: "mesh-system", ResourceVersion: "1"},
+		Data: map[string]string{
+			sidecarConfigKey: "loadBalancerAlgorithm: leastRequest\nretryPolicy:\n  attempts: 5\ntimeout: 2s\ncopyMode: zero-copy\n",
+		},
+	})
+	watcher := meshconfig.NewWatcher(clientset, "mesh-system", "mesh-sidecar-config", traffic.apply)
+	if err := watcher.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	applied := traffic.Load()
+	if applied.LoadBalancerAlgorithm != "leastRequest" || applied.RetryPolicy.Attempts != 5 || applied.Timeout != 2*time.Second {
+		t.Fatalf("applied policy = %+v, want leastRequest, 5 attempts and 2s timeout", applied)
+	}
+	if applied.RetryPolicy.BaseInterval != base.RetryPolicy.BaseInterval || applied.CircuitBreakerPolicy != base.CircuitBreakerPolicy {
+		t.Fatalf("applied policy = %+v, want omitted fields from the environment", applied)
+	}
+
+	traffic.apply("2", map[string]string{sidecarConfigKey: "loadBalancerAlgorithm: fastest\n"})
+	if got := traffic.Load(); !reflect.DeepEqual(got, applied) {
+		t.Fatalf("policy after invalid reload = %+v, want the last good one %+v", got, applied)
+	}
+
+	traffic.apply("3", map[string]string{sidecarConfigKey: "circuitBreakerPolicy:\n  failureThreshold: 0\n"})
+	if got := traffic.Load(); got.CircuitBreakerPolicy.FailureThreshold != 0 || got.LoadBalancerAlgorithm != base.LoadBalancerAlgorithm {
+		t.Fatalf("policy after reload = %+v, want breaker disabled and environment load balancer", got)
+	}
+}
+
+func TestTrafficPolicyForDestination(t *testing.T) {
+	base := config.TrafficPolicy{
+		LoadBalancerAlgorithm: "roundRobin",
+		RetryPolicy:           config.RetryPolicy{Attempts: 3, BackoffType: "exponential", BaseInterval: 100 * time.Millisecond},
+		Timeout:               5 * time.Second,
+	}
+	traffic := newTrafficPolicies(base, metrics.NewRecorder())
+	traffic.ReplaceDestinationPolicies([]policy.DestinationPolicy{
+		{Name: "ratings", Service: "ratings.default.svc.cluster.local", Spec: []byte(`{"host":"ratings","timeout":"-1s"}`)},
+		{Name: "reviews", Service: "reviews.default.svc.cluster.local", Spec: []byte(`{"host":"reviews","timeout":"1s"}`)},
+		{Name: "reviews-http", Service: "reviews.default.svc.cluster.local", Port: 9080, Spec: []byte(`{"host":"reviews","port":9080,"retryPolicy":{"attempts":1}}`)},
+	})
+
+	lookup := func(service string, originalDst string) config.TrafficPolicy {
+		ctx := &domain.ConnContext{OriginalDst: originalDst, Metadata: map[string]any{domain.MetadataService: service}}
+		return traffic.For(ctx)
+	}
+
+	if got := lookup("reviews.default.svc.cluster.local", "10.0.0.1:8080"); got.Timeout != time.Second || got.RetryPolicy.Attempts != 3 {
+		t.Fatalf("service policy = %+v, want 1s timeout and default retries", got)
+	}
+	if got := lookup("reviews.default.svc.cluster.local", "10.0.0.1:9080"); got.RetryPolicy.Attempts != 1 || got.Timeout != base.Timeout {
+		t.Fatalf("port policy = %+v, want 1 attempt and default timeout", got)
+	}
+	if got := lookup("ratings.default.svc.cluster.local", "10.0.0.2:9080"); !reflect.DeepEqual(got, base) {
+		t.Fatalf("invalid policy = %+v, want the sidecar-wide one", got)
+	}
+	if got := lookup("", "10.0.0.3:80"); !reflect.DeepEqual(got, base) {
+		t.Fatalf("unknown destination policy = %+v, want the sidecar-wide one", got)
+	}
+
+	traffic.apply("1", map[string]string{sidecarConfigKey: "loadBalancerAlgorithm: random\n"})
+	if got := lookup("reviews.default.svc.cluster.local", "10.0.0.1:8080"); got.LoadBalancerAlgorithm != "random" || got.Timeout != time.Second {
+		t.Fatalf("service policy after reload = %+v, want reloaded load balancer and its own timeout", got)
+	}
+}
+
+func TestTrafficPolicyRingHashForDestination(t *testing.T) {
+	const (
+		serviceAddr = "10.96.0.10:9080"
+		service     = "reviews.default.svc.cluster.local"
+	)
+
+	base := config.TrafficPolicy{
+		LoadBalancerAlgorithm: "roundRobin",
+		RetryPolicy:           config.RetryPolicy{Attempts: 3, BackoffType: "exponential", BaseInterval: 100 * time.Millisecond},
+		Timeout:               5 * time.Second,
+	}
+	traffic := newTrafficPolicies(base, metrics.NewRecorder())
+	traffic.ReplaceDestinationPolicies([]policy.DestinationPolicy{
+		{Name: "ratings", Service: "ratings.default.svc.cluster.local", Spec: []byte(`{"host":"ratings","loadBalancerAlgorithm":"ringHash","loadBalancerHashKey":"cookie"}`)},
+		{Name: "reviews", Service: service, Spec: []byte(`{"host":"reviews","loadBalancerAlgorithm":"ringHash","loadBalancerHashKey":"header:x-user"}`)},
+	})
+
+	ratings := traffic.For(&domain.ConnContext{OriginalDst: "10.96.0.11:9080", Metadata: map[string]any{domain.MetadataService: "ratings.default.svc.cluster.local"}})
+	if ratings.LoadBalancerAlgorithm != base.LoadBalancerAlgorithm {
+		t.Fatalf("policy with a cookie hash key without a name = %+v, want the global one", ratings)
+	}
+
+	cache := discovery.NewServiceCache(nil)
+	cache.Replace([]discovery.CachedService{{
+		ServiceKey: serviceAddr,
+		Endpoints: []domain.Endpoint{
+			{IP: "10.0.0.1", Port: 9080, ServiceName: service},
+			{IP: "10.0.0.2", Port: 9080, ServiceName: service},
+			{IP: "10.0.0.3", Port: 9080, ServiceName: service},
+		},
+	}})
+	routing := newRoutingMiddleware(cache, "127.0.0.1:8080", nil, 15006, 15001, false, traffic, "", 0, nil, nil)
+
+	route := func(user string) string {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("X-User", user)
+		ctx := &domain.ConnContext{
+			Context:     context.Background(),
+			OriginalDst: serviceAddr,
+			Metadata: map[string]any{
+				domain.MetadataListener:    string(proxy.ProfileOutbound),
+				domain.MetadataDirection:   string(domain.DirectionOutbound),
+				domain.MetadataService:     service,
+				domain.MetadataHTTPRequest: request,
+			},
+		}
+		if err := routing.Handle(ctx, func(*domain.ConnContext) error { return nil }); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+		return ctx.GetString(domain.MetadataTargetAddr)
+	}
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		first := route(user)
+		for range 5 {
+			if got := route(user); got != first {
+				t.Fatalf("user %s routed to %s, want %s", user, got, first)
+			}
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -12,18 +16,33 @@ import (
 type Config struct {
 	PodName        string
 	Namespace      string
+	NodeName       string
+	Zone           string
 	ServiceAccount string
+	TrustDomain    string
 
 	InboundPlainPort   int
 	OutboundPort       int
 	InboundMTLSPort    int
 	MTLSEnabled        bool
+	MTLSMode           string
+	WorkloadMTLSMode   string
 	MetricsPort        int
+	MetricsDropLabels  string
 	MonitoringEnabled  bool
+	AdminPort          int
+	StatusPort         int
+	AdminPprofEnabled  bool
+	LogLevel           string
 	AppTargetAddr      string
 	ShutdownTimeout    time.Duration
+	DrainDuration      time.Duration
 	LoadBalancerConfig LoadBalancerConfig
+	LocalityRouting    LocalityRoutingConfig
 
+	// InboundPorts lists the application container ports, the only ones
+	// inbound traffic may reach.
+	InboundPorts        string
 	ExcludeInboundPorts string
 	ExcludeOutboundIPs  string
 
@@ -33,6 +52,14 @@ type Config struct {
 	CopyMode    string
 
 	CircuitBreakerPolicy CircuitBreakerPolicy
+	OutlierDetection     OutlierDetectionPolicy
+	HealthCheck          HealthCheckConfig
+	Tracing              TracingConfig
+	AccessLog            AccessLogConfig
+
+	// AppProbes maps status port paths to the application probes the
+	// injector moved there, keyed as /app-health/<container>/<probe>.
+	AppProbes map[string]AppProbe
 
 	CertFile                string
 	KeyFile                 string
@@ -40,18 +67,46 @@ type Config struct {
 	CertManagerSignURL      string
 	ServiceAccountTokenPath string
 	BootstrapCertificates   bool
+	CertRotationFraction    float64
+	CertRotationBackoff     time.Duration
+	CertRotationMaxBackoff  time.Duration
+
+	ConfigMapNamespace string
+	ConfigMapName      string
 
 	KubeConfigPath string
 }
 
 type LoadBalancerConfig struct {
 	Algorithm string
+	// HashKey is the ringHash key: sourceIP, header:<name> or cookie:<name>.
+	HashKey string
+}
+
+type LocalityRoutingConfig struct {
+	Enabled            bool
+	SpilloverThreshold float64
 }
 
 type RetryPolicy struct {
 	Attempts     int
 	BackoffType  string
 	BaseInterval time.Duration
+	// RetryOn, RetriableStatusCodes, PerTryTimeout and BodyBufferLimit apply
+	// to HTTP requests retried by the forwarder.
+	RetryOn              []string
+	RetriableStatusCodes []int
+	PerTryTimeout        time.Duration
+	BodyBufferLimit      int
+	Budget               RetryBudget
+}
+
+// RetryBudget caps retries to a destination at Percent of its requests over
+// Window, but always allows MinRetriesPerSecond. A zero Window disables it.
+type RetryBudget struct {
+	Percent             float64
+	MinRetriesPerSecond int
+	Window              time.Duration
 }
 
 type CircuitBreakerPolicy struct {
@@ -59,6 +114,67 @@ type CircuitBreakerPolicy struct {
 	RecoveryTime     time.Duration
 }
 
+type OutlierDetectionPolicy struct {
+	ConsecutiveConnectFailures int
+	Consecutive5xx             int
+	BaseEjectionTime           time.Duration
+	MaxEjectionTime            time.Duration
+	MaxEjectionPercent         int
+}
+
+type HealthCheckConfig struct {
+	Type               string
+	Path               string
+	Interval           time.Duration
+	Timeout            time.Duration
+	Jitter             float64
+	HealthyThreshold   int
+	UnhealthyThreshold int
+}
+
+type TracingConfig struct {
+	Endpoint     string
+	SamplingRate float64
+}
+
+type AccessLogConfig struct {
+	Enabled      bool
+	Format       string
+	Template     string
+	Path         string
+	MaxSizeMB    int
+	MaxBackups   int
+	SamplingRate float64
+	Services     string
+}
+
+// AppProbe is an httpGet or tcpSocket probe of an application container with
+// the port already resolved to a number.
+type AppProbe struct {
+	HTTPGet   *AppHTTPProbe `json:"httpGet,omitempty"`
+	TCPSocket *AppTCPProbe  `json:"tcpSocket,omitempty"`
+}
+
+type AppHTTPProbe struct {
+	Path    string           `json:"path,omitempty"`
+	Port    int              `json:"port"`
+	Scheme  string           `json:"scheme,omitempty"`
+	Headers []AppProbeHeader `json:"httpHeaders,omitempty"`
+}
+
+type AppProbeHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type AppTCPProbe struct {
+	Port int `json:"port"`
+}
+
+func (p OutlierDetectionPolicy) Enabled() bool {
+	return p.ConsecutiveConnectFailures > 0 || p.Consecutive5xx > 0
+}
+
 func LoadFromEnv() (Config, error) {
 	timeout := envDurationWithAliases(5*time.Second, "TIMEOUT", "SIDECAR_TIMEOUT")
 	dialTimeoutDefault := timeout
@@ -67,23 +183,50 @@ func LoadFromEnv() (Config, error) {
 	}
 	dialTimeout := envDurationWithAliases(dialTimeoutDefault, "DIAL_TIMEOUT", "SIDECAR_DIAL_TIMEOUT")
 
+	retriableStatusCodes, err := parseStatusCodes(envStringWithAliases("", "RETRY_STATUS_CODES", "SIDECAR_RETRY_STATUS_CODES"))
+	if err != nil {
+		return Config{}, fmt.Errorf("parse retry status codes: %w", err)
+	}
+
+	appProbes, err := parseAppProbes(envStringWithAliases("", "APP_PROBES", "SIDECAR_APP_PROBES"))
+	if err != nil {
+		return Config{}, fmt.Errorf("parse app probes: %w", err)
+	}
+
 	cfg := Config{
 		PodName:        envStringWithAliases("unknown-pod", "POD_NAME"),
 		Namespace:      envStringWithAliases("default", "POD_NAMESPACE"),
+		NodeName:       envStringWithAliases("", "NODE_NAME"),
+		Zone:           envStringWithAliases("", "ZONE", "SIDECAR_ZONE"),
 		ServiceAccount: envStringWithAliases("default", "SERVICE_ACCOUNT"),
+		TrustDomain:    envStringWithAliases("cluster.local", "TRUST_DOMAIN", "SIDECAR_TRUST_DOMAIN"),
 
 		InboundPlainPort:  envIntWithAliases(15006, "INBOUND_PLAIN_PORT", "SIDECAR_INBOUND_PLAIN_PORT"),
 		OutboundPort:      envIntWithAliases(15002, "OUTBOUND_PORT", "SIDECAR_OUTBOUND_PORT"),
 		InboundMTLSPort:   envIntWithAliases(15001, "INBOUND_MTLS_PORT", "SIDECAR_INBOUND_MTLS_PORT"),
 		MTLSEnabled:       envBoolWithAliases(true, "MTLS_ENABLED", "SIDECAR_MTLS_ENABLED"),
+		MTLSMode:          strings.ToUpper(envStringWithAliases("PERMISSIVE", "MTLS_MODE", "SIDECAR_MTLS_MODE")),
+		WorkloadMTLSMode:  strings.ToUpper(envStringWithAliases("", "WORKLOAD_MTLS_MODE", "SIDECAR_WORKLOAD_MTLS_MODE")),
 		MetricsPort:       envIntWithAliases(9090, "METRICS_PORT", "SIDECAR_METRICS_PORT"),
+		MetricsDropLabels: envStringWithAliases("", "METRICS_DROP_LABELS", "SIDECAR_METRICS_DROP_LABELS"),
 		MonitoringEnabled: envBoolWithAliases(true, "MONITORING_ENABLED", "SIDECAR_MONITORING_ENABLED"),
+		AdminPort:         envIntWithAliases(15000, "ADMIN_PORT", "SIDECAR_ADMIN_PORT"),
+		StatusPort:        envIntWithAliases(15021, "STATUS_PORT", "SIDECAR_STATUS_PORT"),
+		AdminPprofEnabled: envBoolWithAliases(false, "ADMIN_PPROF_ENABLED", "SIDECAR_ADMIN_PPROF_ENABLED"),
+		LogLevel:          envStringWithAliases("info", "LOG_LEVEL", "SIDECAR_LOG_LEVEL"),
 		AppTargetAddr:     envStringWithAliases("127.0.0.1:8080", "APP_TARGET_ADDR", "SIDECAR_APP_TARGET_ADDR"),
 		ShutdownTimeout:   envDurationWithAliases(30*time.Second, "SHUTDOWN_TIMEOUT", "SIDECAR_SHUTDOWN_TIMEOUT"),
+		DrainDuration:     envDurationWithAliases(5*time.Second, "DRAIN_DURATION", "SIDECAR_DRAIN_DURATION"),
 		LoadBalancerConfig: LoadBalancerConfig{
 			Algorithm: envStringWithAliases("roundRobin", "LOAD_BALANCER_ALGORITHM", "SIDECAR_LOAD_BALANCER_ALGORITHM"),
+			HashKey:   envStringWithAliases("", "LOAD_BALANCER_HASH_KEY", "SIDECAR_LOAD_BALANCER_HASH_KEY"),
+		},
+		LocalityRouting: LocalityRoutingConfig{
+			Enabled:            envBoolWithAliases(true, "LOCALITY_ROUTING_ENABLED", "SIDECAR_LOCALITY_ROUTING_ENABLED"),
+			SpilloverThreshold: envFloat64WithAliases(0.2, "LOCALITY_SPILLOVER_THRESHOLD", "SIDECAR_LOCALITY_SPILLOVER_THRESHOLD"),
 		},
 
+		InboundPorts:        envStringWithAliases("", "INBOUND_PORTS", "SIDECAR_INBOUND_PORTS"),
 		ExcludeInboundPorts: envStringWithAliases("9090", "EXCLUDE_INBOUND_PORTS", "SIDECAR_EXCLUDE_INBOUND_PORTS"),
 		ExcludeOutboundIPs:  envStringWithAliases("169.254.169.254/32", "EXCLUDE_OUTBOUND_IPS", "SIDECAR_EXCLUDE_OUTBOUND_IPS"),
 
@@ -91,6 +234,16 @@ func LoadFromEnv() (Config, error) {
 			Attempts:     envIntWithAliases(3, "RETRY_ATTEMPTS", "SIDECAR_RETRY_ATTEMPTS"),
 			BackoffType:  envStringWithAliases("exponential", "RETRY_BACKOFF_TYPE", "SIDECAR_RETRY_BACKOFF_TYPE"),
 			BaseInterval: envDurationWithAliases(100*time.Millisecond, "RETRY_BASE_INTERVAL", "SIDECAR_RETRY_BASE_INTERVAL"),
+
+			RetryOn:              splitList(envStringWithAliases("gateway-error,reset,unavailable", "RETRY_ON", "SIDECAR_RETRY_ON")),
+			RetriableStatusCodes: retriableStatusCodes,
+			PerTryTimeout:        envDurationWithAliases(0, "RETRY_PER_TRY_TIMEOUT", "SIDECAR_RETRY_PER_TRY_TIMEOUT"),
+			BodyBufferLimit:      envIntWithAliases(64*1024, "RETRY_BODY_BUFFER_LIMIT", "SIDECAR_RETRY_BODY_BUFFER_LIMIT"),
+			Budget: RetryBudget{
+				Percent:             envFloat64WithAliases(20, "RETRY_BUDGET_PERCENT", "SIDECAR_RETRY_BUDGET_PERCENT"),
+				MinRetriesPerSecond: envIntWithAliases(10, "RETRY_BUDGET_MIN_RETRIES_PER_SECOND", "SIDECAR_RETRY_BUDGET_MIN_RETRIES_PER_SECOND"),
+				Window:              envDurationWithAliases(10*time.Second, "RETRY_BUDGET_WINDOW", "SIDECAR_RETRY_BUDGET_WINDOW"),
+			},
 		},
 		Timeout:     timeout,
 		DialTimeout: dialTimeout,
@@ -99,6 +252,37 @@ func LoadFromEnv() (Config, error) {
 			FailureThreshold: envUint32WithAliases(5, "CIRCUIT_BREAKER_FAILURE_THRESHOLD", "SIDECAR_CIRCUIT_BREAKER_FAILURE_THRESHOLD"),
 			RecoveryTime:     envDurationWithAliases(30*time.Second, "CIRCUIT_BREAKER_RECOVERY_TIME", "SIDECAR_CIRCUIT_BREAKER_RECOVERY_TIME"),
 		},
+		OutlierDetection: OutlierDetectionPolicy{
+			ConsecutiveConnectFailures: envIntWithAliases(5, "OUTLIER_CONSECUTIVE_CONNECT_FAILURES", "SIDECAR_OUTLIER_CONSECUTIVE_CONNECT_FAILURES"),
+			Consecutive5xx:             envIntWithAliases(5, "OUTLIER_CONSECUTIVE_5XX", "SIDECAR_OUTLIER_CONSECUTIVE_5XX"),
+			BaseEjectionTime:           envDurationWithAliases(30*time.Second, "OUTLIER_BASE_EJECTION_TIME", "SIDECAR_OUTLIER_BASE_EJECTION_TIME"),
+			MaxEjectionTime:            envDurationWithAliases(300*time.Second, "OUTLIER_MAX_EJECTION_TIME", "SIDECAR_OUTLIER_MAX_EJECTION_TIME"),
+			MaxEjectionPercent:         envIntWithAliases(10, "OUTLIER_MAX_EJECTION_PERCENT", "SIDECAR_OUTLIER_MAX_EJECTION_PERCENT"),
+		},
+		HealthCheck: HealthCheckConfig{
+			Type:               envStringWithAliases("none", "HEALTH_CHECK_TYPE", "SIDECAR_HEALTH_CHECK_TYPE"),
+			Path:               envStringWithAliases("/healthz", "HEALTH_CHECK_PATH", "SIDECAR_HEALTH_CHECK_PATH"),
+			Interval:           envDurationWithAliases(10*time.Second, "HEALTH_CHECK_INTERVAL", "SIDECAR_HEALTH_CHECK_INTERVAL"),
+			Timeout:            envDurationWithAliases(time.Second, "HEALTH_CHECK_TIMEOUT", "SIDECAR_HEALTH_CHECK_TIMEOUT"),
+			Jitter:             envFloat64WithAliases(0.2, "HEALTH_CHECK_JITTER", "SIDECAR_HEALTH_CHECK_JITTER"),
+			HealthyThreshold:   envIntWithAliases(2, "HEALTH_CHECK_HEALTHY_THRESHOLD", "SIDECAR_HEALTH_CHECK_HEALTHY_THRESHOLD"),
+			UnhealthyThreshold: envIntWithAliases(3, "HEALTH_CHECK_UNHEALTHY_THRESHOLD", "SIDECAR_HEALTH_CHECK_UNHEALTHY_THRESHOLD"),
+		},
+		Tracing: TracingConfig{
+			Endpoint:     envStringWithAliases("", "TRACING_ENDPOINT", "SIDECAR_TRACING_ENDPOINT"),
+			SamplingRate: envFloat64WithAliases(0.01, "TRACING_SAMPLING_RATE", "SIDECAR_TRACING_SAMPLING_RATE"),
+		},
+		AccessLog: AccessLogConfig{
+			Enabled:      envBoolWithAliases(false, "ACCESS_LOG_ENABLED", "SIDECAR_ACCESS_LOG_ENABLED"),
+			Format:       envStringWithAliases("json", "ACCESS_LOG_FORMAT", "SIDECAR_ACCESS_LOG_FORMAT"),
+			Template:     envStringWithAliases("", "ACCESS_LOG_TEMPLATE", "SIDECAR_ACCESS_LOG_TEMPLATE"),
+			Path:         envStringWithAliases("/dev/stdout", "ACCESS_LOG_PATH", "SIDECAR_ACCESS_LOG_PATH"),
+			MaxSizeMB:    envIntWithAliases(100, "ACCESS_LOG_MAX_SIZE_MB", "SIDECAR_ACCESS_LOG_MAX_SIZE_MB"),
+			MaxBackups:   envIntWithAliases(3, "ACCESS_LOG_MAX_BACKUPS", "SIDECAR_ACCESS_LOG_MAX_BACKUPS"),
+			SamplingRate: envFloat64WithAliases(1, "ACCESS_LOG_SAMPLING_RATE", "SIDECAR_ACCESS_LOG_SAMPLING_RATE"),
+			Services:     envStringWithAliases("", "ACCESS_LOG_SERVICES", "SIDECAR_ACCESS_LOG_SERVICES"),
+		},
+		AppProbes: appProbes,
 
 		CertFile:                envStringWithAliases("", "CERT_FILE", "SIDECAR_CERT_FILE"),
 		KeyFile:                 envStringWithAliases("", "KEY_FILE", "SIDECAR_KEY_FILE"),
@@ -106,6 +290,12 @@ func LoadFromEnv() (Config, error) {
 		CertManagerSignURL:      envStringWithAliases("http://mesh-cert-manager.mesh-system.svc.cluster.local:8080/sign", "CERT_MANAGER_SIGN_URL", "SIDECAR_CERT_MANAGER_SIGN_URL"),
 		ServiceAccountTokenPath: envStringWithAliases("/var/run/secrets/kubernetes.io/serviceaccount/token", "SERVICE_ACCOUNT_TOKEN_PATH", "SIDECAR_SERVICE_ACCOUNT_TOKEN_PATH"),
 		BootstrapCertificates:   envBoolWithAliases(true, "BOOTSTRAP_CERTIFICATES", "SIDECAR_BOOTSTRAP_CERTIFICATES"),
+		CertRotationFraction:    envFloat64WithAliases(0.8, "CERT_ROTATION_FRACTION", "SIDECAR_CERT_ROTATION_FRACTION"),
+		CertRotationBackoff:     envDurationWithAliases(5*time.Second, "CERT_ROTATION_BACKOFF", "SIDECAR_CERT_ROTATION_BACKOFF"),
+		CertRotationMaxBackoff:  envDurationWithAliases(5*time.Minute, "CERT_ROTATION_MAX_BACKOFF", "SIDECAR_CERT_ROTATION_MAX_BACKOFF"),
+
+		ConfigMapNamespace: envStringWithAliases("mesh-system", "CONFIG_MAP_NAMESPACE", "SIDECAR_CONFIG_MAP_NAMESPACE"),
+		ConfigMapName:      envStringWithAliases("mesh-sidecar-config", "CONFIG_MAP_NAME", "SIDECAR_CONFIG_MAP_NAME"),
 
 		KubeConfigPath: envStringWithAliases("", "KUBECONFIG"),
 	}
@@ -122,8 +312,8 @@ func LoadFromEnv() (Config, error) {
 }
 
 func (c Config) Validate() error {
-	if c.InboundPlainPort <= 0 || c.OutboundPort <= 0 || c.MetricsPort <= 0 {
-		return fmt.Errorf("inbound plain, outbound and metrics ports must be positive")
+	if c.InboundPlainPort <= 0 || c.OutboundPort <= 0 || c.MetricsPort <= 0 || c.StatusPort <= 0 {
+		return fmt.Errorf("inbound plain, outbound, metrics and status ports must be positive")
 	}
 
 	if c.InboundMTLSPort < 0 {
@@ -134,22 +324,54 @@ func (c Config) Validate() error {
 		return fmt.Errorf("inbound mtls port must be positive when mTLS is enabled")
 	}
 
+	for _, mode := range []string{c.MTLSMode, c.WorkloadMTLSMode} {
+		switch mode {
+		case "", "PERMISSIVE":
+		case "STRICT":
+			if !c.MTLSEnabled {
+				return fmt.Errorf("STRICT mtls mode requires mTLS to be enabled")
+			}
+		default:
+			return fmt.Errorf("unsupported mtls mode %q", mode)
+		}
+	}
+
 	ports := map[int]string{
 		c.InboundPlainPort: "inbound plain",
 		c.OutboundPort:     "outbound",
 		c.MetricsPort:      "metrics",
+		c.StatusPort:       "status",
 	}
 
+	expectedPorts := 4
 	if c.InboundMTLSPort > 0 {
 		ports[c.InboundMTLSPort] = "inbound mtls"
+		expectedPorts++
+	}
+
+	if c.AdminPort < 0 {
+		return fmt.Errorf("admin port must be non-negative")
+	}
+
+	if c.AdminPort > 0 {
+		ports[c.AdminPort] = "admin"
+		expectedPorts++
 	}
 
-	if len(ports) != 3 && len(ports) != 4 {
+	if len(ports) != expectedPorts {
 		return fmt.Errorf("sidecar ports must be unique")
 	}
 
-	if c.Timeout < 0 {
-		return fmt.Errorf("timeout must be non-negative")
+	if _, err := c.SlogLevel(); err != nil {
+		return err
+	}
+
+	if _, err := c.DroppedMetricLabels(); err != nil {
+		return err
+	}
+
+	if c.DrainDuration < 0 {
+		return fmt.Errorf("drain duration must be non-negative")
 	}
 
 	if c.DialTimeout <= 0 {
@@ -166,34 +388,85 @@ func (c Config) Validate() error {
 		return fmt.Errorf("shutdown timeout must be positive")
 	}
 
-	if c.RetryPolicy.Attempts < 0 {
-		return fmt.Errorf("retry attempts must be non-negative")
+	if err := c.TrafficPolicy().Validate(); err != nil {
+		return err
 	}
 
-	if c.RetryPolicy.BaseInterval <= 0 {
-		return fmt.Errorf("retry base interval must be positive")
+	if c.LocalityRouting.SpilloverThreshold < 0 || c.LocalityRouting.SpilloverThreshold > 1 {
+		return fmt.Errorf("locality spillover threshold must be within [0, 1]")
 	}
 
-	switch c.RetryPolicy.BackoffType {
-	case "linear", "exponential":
-	default:
-		return fmt.Errorf("unsupported retry backoff type %q", c.RetryPolicy.BackoffType)
+	if c.OutlierDetection.ConsecutiveConnectFailures < 0 || c.OutlierDetection.Consecutive5xx < 0 {
+		return fmt.Errorf("outlier detection thresholds must be non-negative")
 	}
 
-	switch c.LoadBalancerConfig.Algorithm {
-	case "none", "roundRobin", "random":
+	if c.OutlierDetection.Enabled() {
+		if c.OutlierDetection.BaseEjectionTime <= 0 {
+			return fmt.Errorf("outlier base ejection time must be positive when outlier detection is enabled")
+		}
+
+		if c.OutlierDetection.MaxEjectionTime < c.OutlierDetection.BaseEjectionTime {
+			return fmt.Errorf("outlier max ejection time must not be less than base ejection time")
+		}
+
+		if c.OutlierDetection.MaxEjectionPercent < 0 || c.OutlierDetection.MaxEjectionPercent > 100 {
+			return fmt.Errorf("outlier max ejection percent must be within [0, 100]")
+		}
+	}
+
+	switch c.HealthCheck.Type {
+	case "none", "tcp", "http", "grpc":
 	default:
-		return fmt.Errorf("unsupported load balancer algorithm %q", c.LoadBalancerConfig.Algorithm)
+		return fmt.Errorf("unsupported health check type %q", c.HealthCheck.Type)
+	}
+
+	if c.HealthCheck.Interval <= 0 || c.HealthCheck.Timeout <= 0 {
+		return fmt.Errorf("health check interval and timeout must be positive")
+	}
+
+	if c.HealthCheck.Jitter < 0 || c.HealthCheck.Jitter >= 1 {
+		return fmt.Errorf("health check jitter must be within [0, 1)")
+	}
+
+	if c.HealthCheck.HealthyThreshold <= 0 || c.HealthCheck.UnhealthyThreshold <= 0 {
+		return fmt.Errorf("health check thresholds must be positive")
+	}
+
+	if c.Tracing.SamplingRate < 0 || c.Tracing.SamplingRate > 1 {
+		return fmt.Errorf("tracing sampling rate must be within [0, 1]")
+	}
+
+	if c.Tracing.Endpoint != "" {
+		endpoint, err := url.Parse(c.Tracing.Endpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return fmt.Errorf("tracing endpoint %q must be an http or https URL", c.Tracing.Endpoint)
+		}
 	}
 
-	if c.CircuitBreakerPolicy.FailureThreshold > 0 && c.CircuitBreakerPolicy.RecoveryTime <= 0 {
-		return fmt.Errorf("circuit breaker recovery time must be positive when circuit breaker is enabled")
+	if c.AccessLog.Enabled {
+		switch c.AccessLog.Format {
+		case "json", "text":
+		default:
+			return fmt.Errorf("unsupported access log format %q", c.AccessLog.Format)
+		}
+
+		if c.AccessLog.SamplingRate <= 0 || c.AccessLog.SamplingRate > 1 {
+			return fmt.Errorf("access log sampling rate must be within (0, 1]")
+		}
+
+		if c.AccessLog.MaxSizeMB <= 0 || c.AccessLog.MaxBackups < 0 {
+			return fmt.Errorf("access log max size must be positive and max backups non-negative")
+		}
 	}
 
 	if _, _, err := net.SplitHostPort(c.AppTargetAddr); err != nil {
 		return fmt.Errorf("invalid app target address %q: %w", c.AppTargetAddr, err)
 	}
 
+	if _, err := parsePorts(c.InboundPorts); err != nil {
+		return fmt.Errorf("parse inbound ports: %w", err)
+	}
+
 	if !containsPort(c.ExcludeInboundPorts, c.MetricsPort) {
 		return fmt.Errorf("metrics port %d must be in EXCLUDE_INBOUND_PORTS", c.MetricsPort)
 	}
@@ -216,9 +489,148 @@ func (c Config) Validate() error {
 		}
 	}
 
+	if mtlsEnabled {
+		if c.TrustDomain == "" || strings.ContainsAny(c.TrustDomain, "/:") {
+			return fmt.Errorf("trust domain must be a bare host name")
+		}
+
+		if c.CertRotationFraction <= 0 || c.CertRotationFraction >= 1 {
+			return fmt.Errorf("cert rotation fraction must be between 0 and 1")
+		}
+
+		if c.CertRotationBackoff <= 0 {
+			return fmt.Errorf("cert rotation backoff must be positive")
+		}
+
+		if c.CertRotationMaxBackoff < c.CertRotationBackoff {
+			return fmt.Errorf("cert rotation max backoff must not be less than cert rotation backoff")
+		}
+	}
+
 	return nil
 }
 
+func (c Config) DroppedMetricLabels() (map[string][]string, error) {
+	dropped := make(map[string][]string)
+	for _, entry := range strings.Split(c.MetricsDropLabels, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		metric, labels, ok := strings.Cut(entry, ":")
+		metric = strings.TrimSpace(metric)
+		if !ok || metric == "" {
+			return nil, fmt.Errorf("metrics drop labels entry %q must look like metric:label,label", entry)
+		}
+
+		for _, label := range strings.Split(labels, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				dropped[metric] = append(dropped[metric], label)
+			}
+		}
+	}
+
+	return dropped, nil
+}
+
+func (c Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", c.LogLevel, err)
+	}
+
+	return level, nil
+}
+
+func (c AccessLogConfig) ServiceList() []string {
+	var services []string
+	for _, service := range strings.Split(c.Services, ",") {
+		if service = strings.TrimSpace(service); service != "" {
+			services = append(services, service)
+		}
+	}
+
+	return services
+}
+
+func splitList(csv string) []string {
+	var values []string
+	for _, value := range strings.Split(csv, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func parseStatusCodes(csv string) ([]int, error) {
+	var codes []int
+	for _, value := range splitList(csv) {
+		code, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q", value)
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+func parseAppProbes(raw string) (map[string]AppProbe, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var probes map[string]AppProbe
+	if err := json.Unmarshal([]byte(raw), &probes); err != nil {
+		return nil, err
+	}
+
+	for path, probe := range probes {
+		switch {
+		case probe.HTTPGet != nil && probe.TCPSocket == nil:
+			if probe.HTTPGet.Port <= 0 || probe.HTTPGet.Port > 65535 {
+				return nil, fmt.Errorf("probe %q: invalid port %d", path, probe.HTTPGet.Port)
+			}
+		case probe.TCPSocket != nil && probe.HTTPGet == nil:
+			if probe.TCPSocket.Port <= 0 || probe.TCPSocket.Port > 65535 {
+				return nil, fmt.Errorf("probe %q: invalid port %d", path, probe.TCPSocket.Port)
+			}
+		default:
+			return nil, fmt.Errorf("probe %q must set exactly one of httpGet and tcpSocket", path)
+		}
+	}
+
+	return probes, nil
+}
+
+// ApplicationPorts returns InboundPorts without the ports of the sidecar itself.
+func (c Config) ApplicationPorts() []int {
+	ports, _ := parsePorts(c.InboundPorts)
+	sidecarPorts := []int{c.InboundPlainPort, c.InboundMTLSPort, c.OutboundPort, c.MetricsPort, c.AdminPort, c.StatusPort}
+
+	return slices.DeleteFunc(ports, func(port int) bool {
+		return slices.Contains(sidecarPorts, port)
+	})
+}
+
+func parsePorts(csv string) ([]int, error) {
+	var ports []int
+	for _, value := range splitList(csv) {
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", value)
+		}
+
+		ports = append(ports, port)
+	}
+
+	return ports, nil
+}
+
 func containsPort(csv string, port int) bool {
 	if csv == "" {
 		return false
@@ -286,6 +698,22 @@ func envUint32WithAliases(fallback uint32, keys ...string) uint32 {
 	return fallback
 }
 
+func envFloat64WithAliases(fallback float64, keys ...string) float64 {
+	for _, key := range keys {
+		value := strings.TrimSpace(os.Getenv(key))
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return parsed
+		}
+	}
+
+	return fallback
+}
+
 func envBoolWithAliases(fallback bool, keys ...string) bool {
 	for _, key := range keys {
 		value := strings.TrimSpace(os.Getenv(key))
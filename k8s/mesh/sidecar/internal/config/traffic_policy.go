@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/LLIEPJIOK/sidecar/internal/domain"
+)
+
+// TrafficPolicy is the part of the configuration that can be reloaded at
+// runtime from the mesh-sidecar-config ConfigMap.
+type TrafficPolicy struct {
+	LoadBalancerAlgorithm string
+	LoadBalancerHashKey   string
+	RetryPolicy           RetryPolicy
+	Timeout               time.Duration
+	CircuitBreakerPolicy  CircuitBreakerPolicy
+}
+
+// trafficPolicyDocument mirrors the sidecar.yaml layout written by the installer.
+type trafficPolicyDocument struct {
+	LoadBalancerAlgorithm string `json:"loadBalancerAlgorithm"`
+	LoadBalancerHashKey   string `json:"loadBalancerHashKey"`
+	RetryPolicy           struct {
+		Attempts int `json:"attempts"`
+		Backoff  struct {
+			Type         string `json:"type"`
+			BaseInterval string `json:"baseInterval"`
+		} `json:"backoff"`
+		RetryOn              []string `json:"retryOn"`
+		RetriableStatusCodes []int    `json:"retriableStatusCodes"`
+		PerTryTimeout        string   `json:"perTryTimeout"`
+		BodyBufferLimit      int      `json:"bodyBufferLimit"`
+		Budget               struct {
+			Percent             float64 `json:"percent"`
+			MinRetriesPerSecond int     `json:"minRetriesPerSecond"`
+			Window              string  `json:"window"`
+		} `json:"budget"`
+	} `json:"retryPolicy"`
+	Timeout              string `json:"timeout"`
+	CircuitBreakerPolicy struct {
+		FailureThreshold uint32 `json:"failureThreshold"`
+		RecoveryTime     string `json:"recoveryTime"`
+	} `json:"circuitBreakerPolicy"`
+}
+
+func (c Config) TrafficPolicy() TrafficPolicy {
+	return TrafficPolicy{
+		LoadBalancerAlgorithm: c.LoadBalancerConfig.Algorithm,
+		LoadBalancerHashKey:   c.LoadBalancerConfig.HashKey,
+		RetryPolicy:           c.RetryPolicy,
+		Timeout:               c.Timeout,
+		CircuitBreakerPolicy:  c.CircuitBreakerPolicy,
+	}
+}
+
+// WithTrafficPolicy returns the config with a reloaded traffic policy applied.
+func (c Config) WithTrafficPolicy(policy TrafficPolicy) Config {
+	c.LoadBalancerConfig.Algorithm = policy.LoadBalancerAlgorithm
+	c.LoadBalancerConfig.HashKey = policy.LoadBalancerHashKey
+	c.RetryPolicy = policy.RetryPolicy
+	c.Timeout = policy.Timeout
+	c.CircuitBreakerPolicy = policy.CircuitBreakerPolicy
+
+	return c
+}
+
+// ParseTrafficPolicy reads sidecar.yaml on top of base, so omitted fields
+// keep their environment values. Other keys of the document are ignored.
+func ParseTrafficPolicy(data []byte, base TrafficPolicy) (TrafficPolicy, error) {
+	var document trafficPolicyDocument
+	document.LoadBalancerAlgorithm = base.LoadBalancerAlgorithm
+	document.LoadBalancerHashKey = base.LoadBalancerHashKey
+	document.RetryPolicy.Attempts = base.RetryPolicy.Attempts
+	document.RetryPolicy.Backoff.Type = base.RetryPolicy.BackoffType
+	document.RetryPolicy.Backoff.BaseInterval = base.RetryPolicy.BaseInterval.String()
+	document.RetryPolicy.RetryOn = base.RetryPolicy.RetryOn
+	document.RetryPolicy.RetriableStatusCodes = base.RetryPolicy.RetriableStatusCodes
+	document.RetryPolicy.PerTryTimeout = base.RetryPolicy.PerTryTimeout.String()
+	document.RetryPolicy.BodyBufferLimit = base.RetryPolicy.BodyBufferLimit
+	document.RetryPolicy.Budget.Percent = base.RetryPolicy.Budget.Percent
+	document.RetryPolicy.Budget.MinRetriesPerSecond = base.RetryPolicy.Budget.MinRetriesPerSecond
+	document.RetryPolicy.Budget.Window = base.RetryPolicy.Budget.Window.String()
+	document.Timeout = base.Timeout.String()
+	document.CircuitBreakerPolicy.FailureThreshold = base.CircuitBreakerPolicy.FailureThreshold
+	document.CircuitBreakerPolicy.RecoveryTime = base.CircuitBreakerPolicy.RecoveryTime.String()
+
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return TrafficPolicy{}, fmt.Errorf("decode traffic policy: %w", err)
+	}
+
+	baseInterval, err := time.ParseDuration(document.RetryPolicy.Backoff.BaseInterval)
+	if err != nil {
+		return TrafficPolicy{}, fmt.Errorf("parse retryPolicy.backoff.baseInterval: %w", err)
+	}
+
+	perTryTimeout, err := time.ParseDuration(document.RetryPolicy.PerTryTimeout)
+	if err != nil {
+		return TrafficPolicy{}, fmt.Errorf("parse retryPolicy.perTryTimeout: %w", err)
+	}
+
+	budgetWindow, err := time.ParseDuration(document.RetryPolicy.Budget.Window)
+	if err != nil {
+		return TrafficPolicy{}, fmt.Errorf("parse retryPolicy.budget.window: %w", err)
+	}
+
+	timeout, err := time.ParseDuration(document.Timeout)
+	if err != nil {
+		return TrafficPolicy{}, fmt.Errorf("parse timeout: %w", err)
+	}
+
+	recoveryTime, err := time.ParseDuration(document.CircuitBreakerPolicy.RecoveryTime)
+	if err != nil {
+		return TrafficPolicy{}, fmt.Errorf("parse circuitBreakerPolicy.recoveryTime: %w", err)
+	}
+
+	policy := TrafficPolicy{
+		LoadBalancerAlgorithm: document.LoadBalancerAlgorithm,
+		LoadBalancerHashKey:   document.LoadBalancerHashKey,
+		RetryPolicy: RetryPolicy{
+			Attempts:     document.RetryPolicy.Attempts,
+			BackoffType:  document.RetryPolicy.Backoff.Type,
+			BaseInterval: baseInterval,
+
+			RetryOn:              document.RetryPolicy.RetryOn,
+			RetriableStatusCodes: document.RetryPolicy.RetriableStatusCodes,
+			PerTryTimeout:        perTryTimeout,
+			BodyBufferLimit:      document.RetryPolicy.BodyBufferLimit,
+			Budget: RetryBudget{
+				Percent:             document.RetryPolicy.Budget.Percent,
+				MinRetriesPerSecond: document.RetryPolicy.Budget.MinRetriesPerSecond,
+				Window:              budgetWindow,
+			},
+		},
+		Timeout: timeout,
+		CircuitBreakerPolicy: CircuitBreakerPolicy{
+			FailureThreshold: document.CircuitBreakerPolicy.FailureThreshold,
+			RecoveryTime:     recoveryTime,
+		},
+	}
+
+	if err := policy.Validate(); err != nil {
+		return TrafficPolicy{}, err
+	}
+
+	return policy, nil
+}
+
+func (p TrafficPolicy) Validate() error {
+	if p.Timeout < 0 {
+		return fmt.Errorf("timeout must be non-negative")
+	}
+
+	if p.RetryPolicy.Attempts < 0 {
+		return fmt.Errorf("retry attempts must be non-negative")
+	}
+
+	if p.RetryPolicy.BaseInterval <= 0 {
+		return fmt.Errorf("retry base interval must be positive")
+	}
+
+	switch p.RetryPolicy.BackoffType {
+	case "linear", "exponential":
+	default:
+		return fmt.Errorf("unsupported retry backoff type %q", p.RetryPolicy.BackoffType)
+	}
+
+	for _, condition := range p.RetryPolicy.RetryOn {
+		switch condition {
+		case "5xx", "gateway-error", "reset", "retriable-status-codes", "unavailable":
+		default:
+			return fmt.Errorf("unsupported retry condition %q", condition)
+		}
+	}
+
+	for _, code := range p.RetryPolicy.RetriableStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("retriable status code %d is out of range", code)
+		}
+	}
+
+	if p.RetryPolicy.PerTryTimeout < 0 {
+		return fmt.Errorf("retry per-try timeout must be non-negative")
+	}
+
+	if p.RetryPolicy.BodyBufferLimit < 0 {
+		return fmt.Errorf("retry body buffer limit must be non-negative")
+	}
+
+	if p.RetryPolicy.Budget.Percent < 0 || p.RetryPolicy.Budget.MinRetriesPerSecond < 0 || p.RetryPolicy.Budget.Window < 0 {
+		return fmt.Errorf("retry budget settings must be non-negative")
+	}
+
+	switch p.LoadBalancerAlgorithm {
+	case "none", "roundRobin", "random", "leastRequest", "leastConnection":
+	case domain.LoadBalancerRingHash:
+		if _, err := domain.ParseHashKey(p.LoadBalancerHashKey); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported load balancer algorithm %q", p.LoadBalancerAlgorithm)
+	}
+
+	if p.CircuitBreakerPolicy.FailureThreshold > 0 && p.CircuitBreakerPolicy.RecoveryTime <= 0 {
+		return fmt.Errorf("circuit breaker recovery time must be positive when circuit breaker is enabled")
+	}
+
+	return nil
+}
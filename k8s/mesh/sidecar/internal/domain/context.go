@@ -12,6 +12,19 @@ const (
 	DirectionOutbound Direction = "outbound"
 )
 
+type Protocol string
+
+const (
+	ProtocolHTTP  Protocol = "http"
+	ProtocolHTTP2 Protocol = "http2"
+	ProtocolTCP   Protocol = "tcp"
+	ProtocolTLS   Protocol = "tls"
+)
+
+func (p Protocol) IsHTTP() bool {
+	return p == ProtocolHTTP || p == ProtocolHTTP2
+}
+
 type ConnContext struct {
 	Context     context.Context
 	ClientConn  net.Conn
@@ -23,6 +36,18 @@ type Endpoint struct {
 	IP          string
 	Port        int
 	ServiceName string
+	Meshed      bool
+	// Identity is the SPIFFE ID path of the pod's service account,
+	// /ns/<namespace>/sa/<name>; a meshed peer must present it.
+	Identity string
+	Zone     string
+	NodeName string
+	ForZones []string
+}
+
+type Workload struct {
+	Name      string
+	Namespace string
 }
 
 func (c *ConnContext) CloneWithContext(ctx context.Context) *ConnContext {
@@ -82,3 +107,21 @@ func (c *ConnContext) GetBool(key string) bool {
 
 	return boolValue
 }
+
+func (c *ConnContext) GetInt(key string) int {
+	if c.Metadata == nil {
+		return 0
+	}
+
+	value, ok := c.Metadata[key]
+	if !ok {
+		return 0
+	}
+
+	intValue, ok := value.(int)
+	if !ok {
+		return 0
+	}
+
+	return intValue
+}
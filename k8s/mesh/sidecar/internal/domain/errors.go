@@ -17,6 +17,7 @@ const (
 	ErrorKindProxy       ErrorKind = "proxy"
 	ErrorKindDiscovery   ErrorKind = "discovery"
 	ErrorKindBreakerOpen ErrorKind = "breaker_open"
+	ErrorKindForbidden   ErrorKind = "forbidden"
 )
 
 type SidecarError struct {
@@ -78,6 +79,8 @@ func NormalizeErrorType(err error) string {
 		return string(ErrorKindDiscovery)
 	case IsKind(err, ErrorKindBreakerOpen):
 		return string(ErrorKindBreakerOpen)
+	case IsKind(err, ErrorKindForbidden):
+		return string(ErrorKindForbidden)
 	case IsKind(err, ErrorKindDial):
 		return string(ErrorKindDial)
 	case IsKind(err, ErrorKindProxy):
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+type HealthCheckType string
+
+const (
+	HealthCheckNone HealthCheckType = "none"
+	HealthCheckTCP  HealthCheckType = "tcp"
+	HealthCheckHTTP HealthCheckType = "http"
+	HealthCheckGRPC HealthCheckType = "grpc"
+)
+
+type HealthCheckPolicy struct {
+	Type        HealthCheckType
+	Path        string
+	GRPCService string
+	Port        int
+	Interval    time.Duration
+}
+
+type HealthCheckTarget struct {
+	Endpoint Endpoint
+	Policy   HealthCheckPolicy
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+const LoadBalancerRingHash = "ringHash"
+
+type HashSource string
+
+const (
+	HashSourceSourceIP HashSource = "sourceIP"
+	HashSourceHeader   HashSource = "header"
+	HashSourceCookie   HashSource = "cookie"
+)
+
+type HashPolicy struct {
+	Source HashSource
+	Name   string
+}
+
+// ParseHashKey reads a ringHash key: sourceIP (the default), header:<name>
+// or cookie:<name>.
+func ParseHashKey(value string) (HashPolicy, error) {
+	value = strings.TrimSpace(value)
+	source, name, _ := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+
+	switch HashSource(source) {
+	case "", HashSourceSourceIP:
+		return HashPolicy{Source: HashSourceSourceIP}, nil
+	case HashSourceHeader, HashSourceCookie:
+		if name == "" {
+			return HashPolicy{}, fmt.Errorf("hash key %q requires a name", value)
+		}
+		return HashPolicy{Source: HashSource(source), Name: name}, nil
+	default:
+		return HashPolicy{}, fmt.Errorf("unsupported hash key %q", value)
+	}
+}
+
+type LoadBalancerPolicy struct {
+	Algorithm string
+	Hash      HashPolicy
+}
@@ -1,13 +1,27 @@
 package domain
 
 const (
-	MetadataListener   = "listener"
-	MetadataDirection  = "direction"
-	MetadataTargetAddr = "target_addr"
-	MetadataServerName = "server_name"
-	MetadataService    = "service"
-	MetadataInMesh     = "in_mesh"
-	MetadataBreakerKey = "breaker_key"
-	MetadataStatusCode = "status_code"
-	MetadataErrorType  = "error_type"
+	MetadataListener          = "listener"
+	MetadataDirection         = "direction"
+	MetadataTargetAddr        = "target_addr"
+	MetadataServerName        = "server_name"
+	MetadataService           = "service"
+	MetadataInMesh            = "in_mesh"
+	MetadataBreakerKey        = "breaker_key"
+	MetadataStatusCode        = "status_code"
+	MetadataErrorType         = "error_type"
+	MetadataPeerIdentity      = "peer_identity"
+	MetadataExpectedIdentity  = "expected_identity"
+	MetadataRequestAuthorizer = "request_authorizer"
+	MetadataDestinationPort   = "destination_port"
+	MetadataProtocol          = "protocol"
+	MetadataHTTPRequest       = "http_request"
+	MetadataSetCookie         = "set_cookie"
+	MetadataGRPCStatus        = "grpc_status"
+	MetadataBytesReceived     = "bytes_received"
+	MetadataBytesSent         = "bytes_sent"
+	MetadataRetryCount        = "retry_count"
+	MetadataResponseRetrier   = "response_retrier"
+	MetadataTriedEndpoints    = "tried_endpoints"
+	MetadataEndpointRelease   = "endpoint_release"
 )
@@ -1,7 +1,19 @@
 package domain
 
+import "net/http"
+
 type NextFunc func(*ConnContext) error
 
+type RequestAuthorizer func(request *http.Request) error
+
+// ResponseRetrier reports whether the forwarder should discard the response
+// instead of writing it, because the request is going to be retried.
+type ResponseRetrier func(response *http.Response) bool
+
+// EndpointRelease frees the in-flight slot routing took for an endpoint. The
+// forwarder takes it over with the response and calls it once the body is closed.
+type EndpointRelease func()
+
 type Handler interface {
 	Handle(ctx *ConnContext, next NextFunc) error
 }